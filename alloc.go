@@ -0,0 +1,77 @@
+package memmapfs
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// allocHeaderSize is the size of the allocation header that AllocAligned
+// keeps at the start of the segment: one 8-byte high-water mark.
+const allocHeaderSize = 8
+
+// AllocAligned reserves size bytes of the shared segment whose address is a
+// multiple of align, which must be a power of two no larger than the page
+// size, and returns their offset relative to the segment. Offsets are never
+// reused; it is a bump allocator for laying out long-lived shared
+// structures, such as the 8-byte words used by AddUint64 and friends, that
+// stay correctly aligned even on strict-alignment platforms.
+//
+// The high-water mark lives in the first 8 bytes of the segment, which must
+// be zero in a fresh region and must not be used for anything else once
+// AllocAligned is in use. It is updated atomically, so processes sharing
+// the region may allocate concurrently. When the segment is exhausted
+// AllocAligned returns ErrSharedMemoryFull.
+func (sm *SharedMemory) AllocAligned(size, align int64) (int64, error) {
+	if size <= 0 {
+		return 0, fmt.Errorf("allocation size %d must be positive", size)
+	}
+	if align <= 0 || align&(align-1) != 0 || align > int64(os.Getpagesize()) {
+		return 0, fmt.Errorf("alignment %d must be a power of two no larger than the page size", align)
+	}
+	if sm.data == nil {
+		return 0, ErrNotMapped
+	}
+
+	// Align addresses, not offsets: the segment may start at any offset
+	base := int64(uintptr(unsafe.Pointer(&sm.data[0])))
+
+	for {
+		mark, err := sm.LoadUint64(0)
+		if err != nil {
+			return 0, err
+		}
+
+		start := int64(mark)
+		if start < allocHeaderSize {
+			start = allocHeaderSize
+		}
+
+		off := (base+start+align-1)&^(align-1) - base
+		end := off + size
+		if end > sm.size || end < off {
+			return 0, ErrSharedMemoryFull
+		}
+
+		swapped, err := sm.CompareAndSwapUint64(0, mark, uint64(end))
+		if err != nil {
+			return 0, err
+		}
+		if swapped {
+			return off, nil
+		}
+	}
+}
+
+// AllocatedBytes returns the high-water mark kept by AllocAligned: the
+// offset, relative to the segment, just past the last allocation.
+func (sm *SharedMemory) AllocatedBytes() (int64, error) {
+	mark, err := sm.LoadUint64(0)
+	if err != nil {
+		return 0, err
+	}
+	if mark < allocHeaderSize {
+		return allocHeaderSize, nil
+	}
+	return int64(mark), nil
+}
@@ -0,0 +1,190 @@
+package memmapfs
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// uint64At returns a pointer to the 8-byte word at file offset off, sliding
+// the window if necessary. The caller must hold the write lock for windowed
+// mappings and at least the read lock otherwise.
+func (mf *MappedFile) uint64At(off int64) (*uint64, error) {
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	if off < 0 || off+8 > mf.size {
+		return nil, ErrInvalidOffset
+	}
+
+	// For windowed mapping, ensure window contains offset
+	if mf.windowSize > 0 {
+		if err := mf.ensureInWindow(off); err != nil {
+			return nil, err
+		}
+	}
+
+	windowOff := mf.fileOffsetToWindowOffset(off)
+	if windowOff+8 > int64(len(mf.data)) {
+		// The word straddles the end of the current window
+		return nil, ErrInvalidOffset
+	}
+
+	ptr := unsafe.Pointer(&mf.data[windowOff])
+	if uintptr(ptr)%8 != 0 {
+		return nil, ErrUnaligned
+	}
+
+	return (*uint64)(ptr), nil
+}
+
+// LoadUint64 atomically loads the 8-byte word at offset off.
+// off must be 8-byte aligned.
+func (mf *MappedFile) LoadUint64(off int64) (uint64, error) {
+	// For windowing, we need write lock to potentially slide window
+	exclusive, err := mf.lockForAccess()
+	if err != nil {
+		return 0, err
+	}
+	defer mf.unlockAccess(exclusive)
+
+	ptr, err := mf.uint64At(off)
+	if err != nil {
+		return 0, err
+	}
+
+	return atomic.LoadUint64(ptr), nil
+}
+
+// StoreUint64 atomically stores val at offset off.
+// off must be 8-byte aligned and the mapping writable.
+func (mf *MappedFile) StoreUint64(off int64, val uint64) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	ptr, err := mf.atomicWriteTarget(off)
+	if err != nil {
+		return err
+	}
+
+	atomic.StoreUint64(ptr, val)
+	mf.modified = true
+	mf.noteDirty(8)
+	return nil
+}
+
+// AddUint64 atomically adds delta to the 8-byte word at offset off and
+// returns the new value. off must be 8-byte aligned and the mapping writable.
+func (mf *MappedFile) AddUint64(off int64, delta uint64) (uint64, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	ptr, err := mf.atomicWriteTarget(off)
+	if err != nil {
+		return 0, err
+	}
+
+	val := atomic.AddUint64(ptr, delta)
+	mf.modified = true
+	mf.noteDirty(8)
+	return val, nil
+}
+
+// CompareAndSwapUint64 atomically replaces the 8-byte word at offset off
+// with new if it currently equals old, and reports whether it did.
+// off must be 8-byte aligned and the mapping writable.
+func (mf *MappedFile) CompareAndSwapUint64(off int64, old, new uint64) (bool, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	ptr, err := mf.atomicWriteTarget(off)
+	if err != nil {
+		return false, err
+	}
+
+	swapped := atomic.CompareAndSwapUint64(ptr, old, new)
+	if swapped {
+		mf.modified = true
+		mf.noteDirty(8)
+	}
+	return swapped, nil
+}
+
+// atomicWriteTarget is uint64At for mutating operations, which additionally
+// require a writable mapping, remapping a drained file first. The caller
+// must hold the write lock.
+func (mf *MappedFile) atomicWriteTarget(off int64) (*uint64, error) {
+	if err := mf.undrainLocked(); err != nil {
+		return nil, err
+	}
+	if mf.data != nil && !mf.config.Mode.isWritable() {
+		return nil, ErrWriteToReadOnlyMap
+	}
+	return mf.uint64At(off)
+}
+
+// wordOffset returns the file offset of the 8-byte word at segment offset
+// off. The word must lie inside the segment: the file may extend past it,
+// and reaching there would slide the window away from the segment.
+func (sm *SharedMemory) wordOffset(off int64) (int64, error) {
+	if off < 0 || off+8 > sm.size {
+		return 0, ErrInvalidOffset
+	}
+	return sm.offset + off, nil
+}
+
+// LoadUint64 atomically loads the 8-byte word at offset off, relative to
+// the start of the shared segment. See MappedFile.LoadUint64.
+func (sm *SharedMemory) LoadUint64(off int64) (uint64, error) {
+	mf := sm.MappedFile()
+	if mf == nil {
+		return 0, ErrNotMapped
+	}
+	fileOff, err := sm.wordOffset(off)
+	if err != nil {
+		return 0, err
+	}
+	return mf.LoadUint64(fileOff)
+}
+
+// StoreUint64 atomically stores val at offset off.
+// See MappedFile.StoreUint64.
+func (sm *SharedMemory) StoreUint64(off int64, val uint64) error {
+	mf := sm.MappedFile()
+	if mf == nil {
+		return ErrNotMapped
+	}
+	fileOff, err := sm.wordOffset(off)
+	if err != nil {
+		return err
+	}
+	return mf.StoreUint64(fileOff, val)
+}
+
+// AddUint64 atomically adds delta to the word at offset off.
+// See MappedFile.AddUint64.
+func (sm *SharedMemory) AddUint64(off int64, delta uint64) (uint64, error) {
+	mf := sm.MappedFile()
+	if mf == nil {
+		return 0, ErrNotMapped
+	}
+	fileOff, err := sm.wordOffset(off)
+	if err != nil {
+		return 0, err
+	}
+	return mf.AddUint64(fileOff, delta)
+}
+
+// CompareAndSwapUint64 atomically swaps the word at offset off if it equals old.
+// See MappedFile.CompareAndSwapUint64.
+func (sm *SharedMemory) CompareAndSwapUint64(off int64, old, new uint64) (bool, error) {
+	mf := sm.MappedFile()
+	if mf == nil {
+		return false, ErrNotMapped
+	}
+	fileOff, err := sm.wordOffset(off)
+	if err != nil {
+		return false, err
+	}
+	return mf.CompareAndSwapUint64(fileOff, old, new)
+}
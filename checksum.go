@@ -0,0 +1,50 @@
+package memmapfs
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+// windowChecksum is the CRC of a window recorded when it was unmapped
+// (Config.VerifyChecksums).
+type windowChecksum struct {
+	length int
+	crc    uint32
+}
+
+// recordWindowChecksum remembers the CRC of the current window before it is
+// unmapped. The caller must hold the write lock.
+func (mf *MappedFile) recordWindowChecksum() {
+	if !mf.config.VerifyChecksums || mf.data == nil || mf.mismatch {
+		return
+	}
+
+	if mf.checksums == nil {
+		mf.checksums = make(map[int64]windowChecksum)
+	}
+	mf.checksums[mf.windowOffset] = windowChecksum{
+		length: len(mf.data),
+		crc:    crc32.ChecksumIEEE(mf.data),
+	}
+}
+
+// verifyWindowChecksum compares the newly mapped window with the CRC
+// recorded when it was last unmapped, if any. Windows whose length has
+// changed since, e.g. through AdaptiveWindow, are not compared. A window
+// that does not match is marked so that no access uses it and the next one
+// maps and verifies it again, and its recorded CRC is kept. The caller must
+// hold the write lock.
+func (mf *MappedFile) verifyWindowChecksum() error {
+	mf.mismatch = false
+
+	sum, ok := mf.checksums[mf.windowOffset]
+	if !ok || sum.length != len(mf.data) {
+		return nil
+	}
+
+	if crc := crc32.ChecksumIEEE(mf.data); crc != sum.crc {
+		mf.mismatch = true
+		return fmt.Errorf("window at offset %d: crc %08x, recorded %08x: %w", mf.windowOffset, crc, sum.crc, ErrChecksumMismatch)
+	}
+	return nil
+}
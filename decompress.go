@@ -0,0 +1,294 @@
+package memmapfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// Decompressor wraps a reader over compressed bytes and returns a reader of
+// the decompressed stream, e.g.
+//
+//	func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+type Decompressor func(io.Reader) (io.Reader, error)
+
+// decompressChunk is how much decompressed data is produced per fill step.
+const decompressChunk = 64 * 1024
+
+// DecompressingMappedFile serves the decompressed contents of a compressed,
+// read-only mapped file. Data is decompressed lazily, only as far as reads
+// require, into anonymous memory owned by the DecompressingMappedFile, so
+// large outputs do not weigh on the Go heap; Close releases it.
+//
+// Stat, Name and directory methods describe the underlying compressed file.
+type DecompressingMappedFile struct {
+	file         absfs.File // Underlying (usually mapped) compressed file
+	decompressor Decompressor
+
+	r    io.Reader // Decompressed stream, created on first read
+	buf  []byte    // Decompressed bytes produced so far, in an anonymous mapping of cap(buf) bytes
+	done bool      // Whether the stream has been fully decompressed
+	err  error     // Sticky decompression error
+
+	position int64
+	mu       sync.Mutex
+}
+
+// newDecompressingFile wraps file so that reads see decompressed data.
+// Directories are returned unchanged.
+func newDecompressingFile(file absfs.File, decompressor Decompressor) (absfs.File, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		return file, nil
+	}
+
+	return &DecompressingMappedFile{
+		file:         file,
+		decompressor: decompressor,
+	}, nil
+}
+
+// fill decompresses until at least upto bytes are available, the stream
+// ends, or an error occurs. Pass -1 to decompress everything.
+// The caller must hold the lock.
+func (df *DecompressingMappedFile) fill(upto int64) error {
+	if df.r == nil && !df.done && df.err == nil {
+		var size int64
+		if fi, err := df.file.Stat(); err == nil {
+			size = fi.Size()
+		}
+
+		r, err := df.decompressor(io.NewSectionReader(df.file, 0, size))
+		switch {
+		case err == io.EOF:
+			// Empty input decompresses to nothing
+			df.done = true
+		case err != nil:
+			df.err = err
+		default:
+			df.r = r
+		}
+	}
+
+	for !df.done && df.err == nil && (upto < 0 || int64(len(df.buf)) < upto) {
+		if cap(df.buf)-len(df.buf) < decompressChunk {
+			grown, err := growAnonymous(df.buf, 2*cap(df.buf)+decompressChunk)
+			if err != nil {
+				df.err = err
+				break
+			}
+			df.buf = grown
+		}
+
+		n, err := df.r.Read(df.buf[len(df.buf):cap(df.buf)])
+		df.buf = df.buf[:len(df.buf)+n]
+
+		if err == io.EOF {
+			df.done = true
+		} else if err != nil {
+			df.err = err
+		}
+	}
+
+	return df.err
+}
+
+// growAnonymous returns an anonymous mapping of size bytes holding the
+// bytes of buf, which must be nil or come from mapPrivateAnonymous and is
+// released.
+// The result has buf's length.
+func growAnonymous(buf []byte, size int) ([]byte, error) {
+	n := len(buf)
+	if buf != nil {
+		if grown, ok := remapAnonymous(buf[:cap(buf)], size); ok {
+			return grown[:n], nil
+		}
+	}
+
+	grown, err := mapPrivateAnonymous(size)
+	if err != nil {
+		return nil, fmt.Errorf("anonymous mmap failed: %w", err)
+	}
+	if buf != nil {
+		copy(grown, buf)
+		unmapAnonymous(buf[:cap(buf)])
+	}
+	return grown[:n], nil
+}
+
+// ReadAt reads decompressed data at offset off.
+func (df *DecompressingMappedFile) ReadAt(p []byte, off int64) (int, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	return df.readAtLocked(p, off)
+}
+
+// readAtLocked implements ReadAt. The caller must hold the lock.
+func (df *DecompressingMappedFile) readAtLocked(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	err := df.fill(off + int64(len(p)))
+
+	if off >= int64(len(df.buf)) {
+		if err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, df.buf[off:])
+	if n < len(p) {
+		if err != nil {
+			return n, err
+		}
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Read reads decompressed data from the current position.
+func (df *DecompressingMappedFile) Read(p []byte) (int, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	n, err := df.readAtLocked(p, df.position)
+	df.position += int64(n)
+
+	// EOF will be returned on the next call when nothing is left
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek sets the position in the decompressed stream. Seeking relative to
+// the end decompresses the whole file to learn its size.
+func (df *DecompressingMappedFile) Seek(offset int64, whence int) (int64, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	var newPos int64
+
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = df.position + offset
+	case io.SeekEnd:
+		if err := df.fill(-1); err != nil {
+			return 0, err
+		}
+		newPos = int64(len(df.buf)) + offset
+	default:
+		return 0, ErrInvalidWhence
+	}
+
+	if newPos < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	df.position = newPos
+	return newPos, nil
+}
+
+// Size returns the decompressed size, decompressing the whole file if needed.
+func (df *DecompressingMappedFile) Size() (int64, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	if err := df.fill(-1); err != nil {
+		return 0, err
+	}
+	return int64(len(df.buf)), nil
+}
+
+// ReadAll returns a copy of the entire decompressed contents.
+func (df *DecompressingMappedFile) ReadAll() ([]byte, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	if err := df.fill(-1); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), df.buf...), nil
+}
+
+// Write is not supported; decompressed files are read-only.
+func (df *DecompressingMappedFile) Write(p []byte) (int, error) {
+	return 0, ErrWriteToReadOnlyMap
+}
+
+// WriteAt is not supported; decompressed files are read-only.
+func (df *DecompressingMappedFile) WriteAt(p []byte, off int64) (int, error) {
+	return 0, ErrWriteToReadOnlyMap
+}
+
+// WriteString is not supported; decompressed files are read-only.
+func (df *DecompressingMappedFile) WriteString(s string) (int, error) {
+	return 0, ErrWriteToReadOnlyMap
+}
+
+// Truncate is not supported; decompressed files are read-only.
+func (df *DecompressingMappedFile) Truncate(size int64) error {
+	return ErrWriteToReadOnlyMap
+}
+
+// Sync is a no-op; decompressed data is never written back.
+func (df *DecompressingMappedFile) Sync() error {
+	return nil
+}
+
+// Close releases the decompressed data and closes the underlying file.
+func (df *DecompressingMappedFile) Close() error {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	if df.buf != nil {
+		unmapAnonymous(df.buf[:cap(df.buf)])
+	}
+	df.buf = nil
+	df.r = nil
+	df.done = true
+	return df.file.Close()
+}
+
+// Stat returns file info for the underlying compressed file.
+func (df *DecompressingMappedFile) Stat() (fs.FileInfo, error) {
+	return df.file.Stat()
+}
+
+// Name returns the name of the underlying file.
+func (df *DecompressingMappedFile) Name() string {
+	return df.file.Name()
+}
+
+// Readdir reads directory contents.
+func (df *DecompressingMappedFile) Readdir(n int) ([]os.FileInfo, error) {
+	return df.file.Readdir(n)
+}
+
+// Readdirnames reads directory entry names.
+func (df *DecompressingMappedFile) Readdirnames(n int) ([]string, error) {
+	return df.file.Readdirnames(n)
+}
+
+// ReadDir reads the contents of the directory and returns a slice of DirEntry values.
+func (df *DecompressingMappedFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return df.file.ReadDir(n)
+}
+
+// Ensure DecompressingMappedFile implements absfs.File
+var _ absfs.File = (*DecompressingMappedFile)(nil)
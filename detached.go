@@ -0,0 +1,50 @@
+package memmapfs
+
+import (
+	"io/fs"
+	"os"
+
+	"github.com/absfs/absfs"
+)
+
+// detachedFile stands in for an underlying file without an open descriptor:
+// one closed after mapping (Config.CloseFdAfterMap) or a remote file
+// (OpenRemote). It remembers the name and file info so that Name and Stat
+// keep working; all I/O fails with os.ErrClosed.
+type detachedFile struct {
+	name string
+	info fs.FileInfo
+}
+
+// detach closes file and returns a detachedFile describing it.
+func detach(file absfs.File) (*detachedFile, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &detachedFile{name: file.Name(), info: fi}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *detachedFile) Name() string                       { return d.name }
+func (d *detachedFile) Stat() (fs.FileInfo, error)         { return d.info, nil }
+func (d *detachedFile) Close() error                       { return nil }
+func (d *detachedFile) Sync() error                        { return nil }
+func (d *detachedFile) Read([]byte) (int, error)           { return 0, os.ErrClosed }
+func (d *detachedFile) ReadAt([]byte, int64) (int, error)  { return 0, os.ErrClosed }
+func (d *detachedFile) Write([]byte) (int, error)          { return 0, os.ErrClosed }
+func (d *detachedFile) WriteAt([]byte, int64) (int, error) { return 0, os.ErrClosed }
+func (d *detachedFile) WriteString(string) (int, error)    { return 0, os.ErrClosed }
+func (d *detachedFile) Seek(int64, int) (int64, error)     { return 0, os.ErrClosed }
+func (d *detachedFile) Truncate(int64) error               { return os.ErrClosed }
+func (d *detachedFile) Readdir(int) ([]os.FileInfo, error) { return nil, os.ErrClosed }
+func (d *detachedFile) Readdirnames(int) ([]string, error) { return nil, os.ErrClosed }
+func (d *detachedFile) ReadDir(int) ([]fs.DirEntry, error) { return nil, os.ErrClosed }
+
+// Ensure detachedFile implements absfs.File
+var _ absfs.File = (*detachedFile)(nil)
@@ -0,0 +1,92 @@
+package memmapfs
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// eventWord returns the 32-bit event counter at offset off of the shared
+// segment.
+func (sm *SharedMemory) eventWord(off int64) (*uint32, error) {
+	if sm.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	if off < 0 || off+4 > int64(len(sm.data)) {
+		return nil, ErrInvalidOffset
+	}
+
+	ptr := unsafe.Pointer(&sm.data[off])
+	if uintptr(ptr)%4 != 0 {
+		return nil, ErrUnaligned
+	}
+
+	return (*uint32)(ptr), nil
+}
+
+// EventSeq returns the current value of the event counter at offset off,
+// to be passed to Wait. Reading it before checking the shared data for work
+// ensures that a Notify racing with the check is not missed.
+func (sm *SharedMemory) EventSeq(off int64) (uint32, error) {
+	word, err := sm.eventWord(off)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.LoadUint32(word), nil
+}
+
+// Notify increments the 32-bit event counter at offset off (4-byte aligned,
+// relative to the segment) and wakes every process blocked in Wait on it.
+// The region must be writable.
+func (sm *SharedMemory) Notify(off int64) error {
+	if mf := sm.MappedFile(); mf == nil || !mf.config.Mode.isWritable() {
+		return ErrWriteToReadOnlyMap
+	}
+
+	word, err := sm.eventWord(off)
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint32(word, 1)
+	return sm.wakeEvent(word, off)
+}
+
+// Wait blocks until the event counter at offset off no longer equals seq,
+// i.e. until a Notify since seq was read with EventSeq, or until timeout
+// elapses, in which case it returns ErrWaitTimeout. A timeout <= 0 waits
+// indefinitely.
+//
+// Waiting uses a futex on Linux and a named event object on Windows; other
+// platforms poll the counter.
+//
+//	seq, _ := sm.EventSeq(0)
+//	for !ready(sm.Data()) {
+//		sm.Wait(0, seq, time.Second)
+//		seq, _ = sm.EventSeq(0)
+//	}
+func (sm *SharedMemory) Wait(off int64, seq uint32, timeout time.Duration) error {
+	word, err := sm.eventWord(off)
+	if err != nil {
+		return err
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	return sm.waitEvent(word, off, seq, deadline)
+}
+
+// remaining returns the time left until deadline, or -1 for no deadline.
+func remaining(deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return -1
+	}
+	if d := time.Until(deadline); d > 0 {
+		return d
+	}
+	return 0
+}
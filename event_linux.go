@@ -0,0 +1,52 @@
+//go:build linux
+
+package memmapfs
+
+import (
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Shared (non-private) futex operations work across processes mapping
+	// the same file.
+	futexWait = 0
+	futexWake = 1
+)
+
+// wakeEvent wakes all futex waiters on word.
+func (sm *SharedMemory) wakeEvent(word *uint32, off int64) error {
+	_, _, errno := unix.Syscall6(unix.SYS_FUTEX, uintptr(unsafe.Pointer(word)), futexWake, uintptr(^uint32(0)>>1), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// waitEvent blocks on the futex word until it differs from seq.
+func (sm *SharedMemory) waitEvent(word *uint32, off int64, seq uint32, deadline time.Time) error {
+	for atomic.LoadUint32(word) == seq {
+		var ts *unix.Timespec
+		if d := remaining(deadline); d == 0 {
+			return ErrWaitTimeout
+		} else if d > 0 {
+			t := unix.NsecToTimespec(d.Nanoseconds())
+			ts = &t
+		}
+
+		_, _, errno := unix.Syscall6(unix.SYS_FUTEX, uintptr(unsafe.Pointer(word)), futexWait, uintptr(seq), uintptr(unsafe.Pointer(ts)), 0, 0)
+		switch errno {
+		case 0, unix.EAGAIN, unix.EINTR:
+			// Woken, value already changed, or interrupted: recheck
+		case unix.ETIMEDOUT:
+			return ErrWaitTimeout
+		default:
+			return errno
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build !linux && !windows
+
+package memmapfs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// eventPollInterval is the longest delay between checks of the counter on
+// platforms without a cross-process wait primitive.
+const eventPollInterval = 10 * time.Millisecond
+
+// wakeEvent is a no-op; waiters poll the counter.
+func (sm *SharedMemory) wakeEvent(word *uint32, off int64) error {
+	return nil
+}
+
+// waitEvent polls the counter with backoff until it differs from seq.
+func (sm *SharedMemory) waitEvent(word *uint32, off int64, seq uint32, deadline time.Time) error {
+	delay := 50 * time.Microsecond
+	for atomic.LoadUint32(word) == seq {
+		d := remaining(deadline)
+		if d == 0 {
+			return ErrWaitTimeout
+		}
+		if d > 0 && d < delay {
+			delay = d
+		}
+
+		time.Sleep(delay)
+		if delay *= 2; delay > eventPollInterval {
+			delay = eventPollInterval
+		}
+	}
+
+	return nil
+}
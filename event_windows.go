@@ -0,0 +1,77 @@
+//go:build windows
+
+package memmapfs
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// eventPollInterval bounds each wait on the event object, so that a waiter
+// rechecks the counter even if it missed a SetEvent while not waiting.
+const eventPollInterval = 10 * time.Millisecond
+
+// openEvent opens (creating if needed) the named manual-reset event shared
+// by every process using the event counter at off of this file.
+func (sm *SharedMemory) openEvent(off int64) (windows.Handle, error) {
+	path, err := filepath.Abs(sm.path)
+	if err != nil {
+		path = sm.path
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(path)))
+	name, err := windows.UTF16PtrFromString(fmt.Sprintf(`Local\memmapfs-%x-%d`, h.Sum64(), sm.offset+off))
+	if err != nil {
+		return 0, err
+	}
+
+	event, err := windows.CreateEvent(nil, 1, 0, name)
+	if err != nil && err != windows.ERROR_ALREADY_EXISTS {
+		return 0, fmt.Errorf("CreateEvent failed: %w", err)
+	}
+	return event, nil
+}
+
+// wakeEvent signals the named event for the counter.
+func (sm *SharedMemory) wakeEvent(word *uint32, off int64) error {
+	event, err := sm.openEvent(off)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(event)
+
+	return windows.SetEvent(event)
+}
+
+// waitEvent waits on the named event until the counter differs from seq.
+func (sm *SharedMemory) waitEvent(word *uint32, off int64, seq uint32, deadline time.Time) error {
+	event, err := sm.openEvent(off)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(event)
+
+	for atomic.LoadUint32(word) == seq {
+		wait := eventPollInterval
+		if d := remaining(deadline); d == 0 {
+			return ErrWaitTimeout
+		} else if d > 0 && d < wait {
+			wait = d
+		}
+
+		if _, err := windows.WaitForSingleObject(event, uint32(wait.Milliseconds())); err != nil {
+			return fmt.Errorf("WaitForSingleObject failed: %w", err)
+		}
+	}
+
+	// Rearm for the next Notify; waiters that miss the signal recheck on their next interval
+	windows.ResetEvent(event)
+	return nil
+}
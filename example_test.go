@@ -144,6 +144,25 @@ func ExampleMappedFile_Data() {
 	// Output: Direct access: Direct memory access!
 }
 
+// ExampleMemMapFS_ReadFileMapped demonstrates mapping a whole file in one call.
+func ExampleMemMapFS_ReadFileMapped() {
+	tmpDir, _ := os.MkdirTemp("", "memmapfs-example")
+	defer os.RemoveAll(tmpDir)
+
+	tmpFile := filepath.Join(tmpDir, "data.txt")
+	os.WriteFile(tmpFile, []byte("Mapped in one call!"), 0644)
+
+	osFS, _ := osfs.NewFS()
+	mfs := memmapfs.New(osFS, memmapfs.DefaultConfig())
+
+	// Zero-copy contents plus a Closer that unmaps them
+	data, closer, _ := mfs.ReadFileMapped(tmpFile)
+	defer closer.Close()
+
+	fmt.Printf("Contents: %s\n", string(data))
+	// Output: Contents: Mapped in one call!
+}
+
 // ExampleNew_windowedMapping demonstrates using windowed mapping for large files.
 func ExampleNew_windowedMapping() {
 	// Create a large test file
@@ -0,0 +1,87 @@
+package memmapfs
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// fdAccessor extracts the descriptor from a wrapped file of one concrete type.
+type fdAccessor func(file interface{}) (uintptr, bool)
+
+// fdAccessors caches an fdAccessor per concrete file type, so the reflective
+// field search runs once per type instead of on every Open.
+var fdAccessors sync.Map // map[reflect.Type]fdAccessor
+
+var (
+	osFileType   = reflect.TypeOf((*os.File)(nil))
+	fdGetterType = reflect.TypeOf((*interface{ Fd() uintptr })(nil)).Elem()
+)
+
+// wrappedFD returns the descriptor of an *os.File wrapped inside file, e.g.
+// in an unexported field of a filesystem's file type.
+func wrappedFD(file interface{}) (uintptr, bool) {
+	t := reflect.TypeOf(file)
+	if a, ok := fdAccessors.Load(t); ok {
+		return a.(fdAccessor)(file)
+	}
+
+	a := findFDAccessor(t)
+	fdAccessors.Store(t, a)
+	return a(file)
+}
+
+// findFDAccessor builds an fdAccessor for values of type t, a pointer to a
+// struct with a field that is an *os.File or has an Fd method, checked in
+// field order. Interface fields are checked on each call since their
+// dynamic type may vary.
+func findFDAccessor(t reflect.Type) fdAccessor {
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return func(interface{}) (uintptr, bool) { return 0, false }
+	}
+
+	var fields []int
+	st := t.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		ft := st.Field(i).Type
+		if ft == osFileType || ft.Implements(fdGetterType) || ft.Kind() == reflect.Interface {
+			fields = append(fields, i)
+		}
+	}
+
+	return func(file interface{}) (uintptr, bool) {
+		v := reflect.ValueOf(file)
+		if v.IsNil() {
+			return 0, false
+		}
+		v = v.Elem()
+
+		for _, i := range fields {
+			field := v.Field(i)
+
+			// Unexported fields need unsafe to be read
+			if !field.CanInterface() {
+				field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+			}
+
+			if field.Type() == osFileType {
+				if osFile := field.Interface().(*os.File); osFile != nil {
+					return osFile.Fd(), true
+				}
+				continue
+			}
+
+			if field.Kind() == reflect.Interface || field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					continue
+				}
+			}
+			if fg, ok := field.Interface().(interface{ Fd() uintptr }); ok {
+				return fg.Fd(), true
+			}
+		}
+
+		return 0, false
+	}
+}
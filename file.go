@@ -7,6 +7,9 @@ import (
 	"io/fs"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 
 	"github.com/absfs/absfs"
 )
@@ -23,26 +26,72 @@ type MappedFile struct {
 	position int64  // Current read/write position
 
 	// Windowing (for large files)
-	windowSize   int64 // Size of the mapping window (0 = full file)
-	windowOffset int64 // File offset where current window starts
+	windowed     bool    // Whether windowing is used (set at open, or on growth past one window under the write lock)
+	windowSize   int64   // Size of the mapping window (0 = full file)
+	windowOffset int64   // File offset where current window starts
 	fd           uintptr // File descriptor (needed for remapping)
 
+	// Adaptive window sizing
+	slides     uint64    // Total number of window slides
+	slideBurst int       // Slides since burstStart
+	burstStart time.Time // Start of the current slide-rate measurement period
+
+	// Remote files are fetched into anonymous memory instead of mapped
+	remote RemoteBackend
+
+	// Files that map themselves (see Mmapper)
+	mapper Mmapper
+
+	// Opens the file's path again for Reopen (nil if unsupported)
+	reopen func() (absfs.File, error)
+
+	// CRC of each window when it was last unmapped (Config.VerifyChecksums)
+	checksums map[int64]windowChecksum
+	mismatch  bool // The current window failed verification and must be remapped
+
+	// Cached stat of the underlying file (Config.StatCacheInterval)
+	statInfo fs.FileInfo
+	statTime time.Time
+	statMu   sync.Mutex
+
 	// Configuration
 	config      *Config
 	syncManager *syncManager // For periodic sync
+	open        *openFiles   // Owning filesystem's set, for CloseAll
 
 	// State
-	modified bool         // Track if writes occurred
-	mu       sync.RWMutex // Protect concurrent access
+	modified     bool          // Track if writes occurred
+	drained      atomic.Bool   // Unmapped by Drain, remapped on next access
+	pins         int           // Outstanding Pin calls; the mapping must stay put
+	unpinned     *sync.Cond    // Signalled when pins drops to zero (uses mu)
+	dirty        int64         // Bytes written since the last sync
+	lastSync     time.Time     // Time of the last successful sync
+	flushPending bool          // Whether an early flush has been requested
+	closed       bool          // Set by Close; the sync manager skips closed files
+	done         chan struct{} // Closed on Close to stop watchers (created lazily)
+	mu           sync.RWMutex  // Protect concurrent access
 }
 
 const (
 	// DefaultWindowSize is the default window size for large files (1 GB)
 	DefaultWindowSize = 1 << 30 // 1 GB
+
+	// DefaultMmapRetryBackoff is the default delay before retrying a failed mapping
+	DefaultMmapRetryBackoff = time.Millisecond
 )
 
-// newMappedFile creates a new memory-mapped file.
-func newMappedFile(file absfs.File, config *Config, size int64, syncManager *syncManager) (*MappedFile, error) {
+// zeroFillChunk is the largest write used to zero-fill grown regions.
+const zeroFillChunk = 64 * 1024
+
+const (
+	// adaptiveSlideThreshold is the number of slides per adaptiveSlidePeriod
+	// above which an AdaptiveWindow mapping doubles its window size.
+	adaptiveSlideThreshold = 16
+	adaptiveSlidePeriod    = time.Second
+)
+
+// allocMappedFile returns an unmapped MappedFile with windowing configured.
+func allocMappedFile(file absfs.File, config *Config, size int64, syncManager *syncManager) *MappedFile {
 	mf := &MappedFile{
 		file:         file,
 		size:         size,
@@ -53,28 +102,76 @@ func newMappedFile(file absfs.File, config *Config, size int64, syncManager *syn
 		windowSize:   0,
 		windowOffset: 0,
 	}
+	mf.unpinned = sync.NewCond(&mf.mu)
+
+	if mapper, ok := file.(Mmapper); ok {
+		mf.mapper = mapper
+	}
 
 	// Determine if we should use windowing
-	if !config.MapFullFile {
-		// Use windowing for large files
-		windowSize := config.WindowSize
-		if windowSize == 0 {
-			windowSize = DefaultWindowSize
-		}
-		mf.windowSize = windowSize
+	if mf.shouldWindow(size) {
+		mf.windowed = true
+		mf.windowSize = mf.baseWindowSize()
 		mf.windowOffset = 0
 	}
 
+	return mf
+}
+
+// newMappedFile creates a new memory-mapped file.
+func newMappedFile(file absfs.File, config *Config, size int64, syncManager *syncManager) (*MappedFile, error) {
+	mf := allocMappedFile(file, config, size, syncManager)
+	if err := mf.mapInitial(); err != nil {
+		return nil, err
+	}
+	return mf, nil
+}
+
+// mapInitial maps a file set up by allocMappedFile and applies the
+// configured hints, preloads and sync registration.
+func (mf *MappedFile) mapInitial() error {
+	file, config, size, syncManager := mf.file, mf.config, mf.size, mf.syncManager
+
 	// Perform platform-specific mmap
 	if err := mf.mmap(); err != nil {
-		return nil, err
+		return err
+	}
+
+	// Some special files report a size but map to nothing
+	if len(mf.data) == 0 {
+		mf.munmap()
+		return fmt.Errorf("%s: %d-byte file mapped to an empty region: %w", file.Name(), size, ErrShortMapping)
+	}
+
+	// The mapping stays valid without the descriptor; windows need it to slide
+	if config.CloseFdAfterMap && !mf.windowed {
+		detached, err := detach(file)
+		if err != nil {
+			mf.munmap()
+			return err
+		}
+		mf.file = detached
 	}
 
 	// Apply preload hints if requested
 	if config.Preload {
 		if err := mf.preload(); err != nil {
 			// Preload is a hint, don't fail on error
-			_ = err
+			config.warn("memmapfs: preload failed", "name", file.Name(), "error", err)
+		}
+	}
+
+	for _, h := range config.Hints {
+		if err := mf.Hint(h); err != nil {
+			config.warn("memmapfs: cache hint failed", "name", file.Name(), "hint", h, "error", err)
+		}
+	}
+
+	// Unlike the hints, a synchronous preload is a guarantee
+	if config.PreloadSync {
+		if err := mf.preloadSync(); err != nil {
+			mf.munmap()
+			return err
 		}
 	}
 
@@ -83,26 +180,39 @@ func newMappedFile(file absfs.File, config *Config, size int64, syncManager *syn
 		syncManager.register(mf)
 	}
 
-	return mf, nil
+	return nil
 }
 
 // Read reads data from the mapped memory.
+// By default a read that reaches the end of the file returns (n, nil) and
+// the following call returns (0, io.EOF). With Config.EOFOnLastRead set,
+// io.EOF is returned together with the final bytes instead, as io.Reader allows.
+// Read advances the shared file position, so it always takes the write
+// lock; concurrent readers that need parallelism should use ReadAt.
 func (mf *MappedFile) Read(p []byte) (int, error) {
-	// For windowing, we need write lock to potentially slide window
-	if mf.windowSize > 0 {
-		mf.mu.Lock()
-		defer mf.mu.Unlock()
-	} else {
-		mf.mu.RLock()
-		defer mf.mu.RUnlock()
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
 	}
 
+	return mf.readLocked(p)
+}
+
+// readLocked implements Read. The caller must hold the write lock.
+func (mf *MappedFile) readLocked(p []byte) (int, error) {
 	if mf.data == nil {
 		return mf.file.Read(p)
 	}
 
+	limit, err := mf.readLimit()
+	if err != nil {
+		return 0, err
+	}
+
 	// Check if we're at EOF
-	if mf.position >= mf.size {
+	if mf.position >= limit {
 		return 0, io.EOF
 	}
 
@@ -117,33 +227,180 @@ func (mf *MappedFile) Read(p []byte) (int, error) {
 	windowPos := mf.fileOffsetToWindowOffset(mf.position)
 
 	// Copy from mapped memory to user buffer
-	n := copy(p, mf.data[windowPos:])
+	window, err := mf.windowUpTo(windowPos, limit)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, window)
 	mf.position += int64(n)
 
+	// Optionally report EOF together with the final bytes
+	if mf.config.EOFOnLastRead && mf.position >= limit {
+		return n, io.EOF
+	}
+
 	// Return the number of bytes read
 	// EOF will be returned on the next call when position >= size
 	return n, nil
 }
 
+// ReadNext returns up to max bytes from the current position as a slice of
+// the mapping itself, without copying, and advances the position past them.
+// If max <= 0 the rest of the mapped region is returned. For windowed
+// mappings the slice stops at the end of the current window.
+//
+// The slice aliases mapped memory: it must not be modified for read-only
+// mappings and is invalid after Close, Drain or a window slide (use Pin to
+// hold it). At the end of the file ReadNext returns (nil, io.EOF), or
+// io.EOF with the final bytes if Config.EOFOnLastRead is set.
+func (mf *MappedFile) ReadNext(max int) ([]byte, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return nil, err
+	}
+
+	limit, err := mf.readLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	if mf.position >= limit {
+		return nil, io.EOF
+	}
+
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	// For windowed mapping, ensure window contains position
+	if mf.windowSize > 0 {
+		if err := mf.ensureInWindow(mf.position); err != nil {
+			return nil, err
+		}
+	}
+
+	start := mf.fileOffsetToWindowOffset(mf.position)
+	window, err := mf.windowUpTo(start, limit)
+	if err != nil {
+		return nil, err
+	}
+	end := start + int64(len(window))
+	if max > 0 && start+int64(max) < end {
+		end = start + int64(max)
+	}
+
+	p := mf.data[start:end:end]
+	mf.position += int64(len(p))
+
+	if mf.config.EOFOnLastRead && mf.position >= limit {
+		return p, io.EOF
+	}
+	return p, nil
+}
+
+// ReadByte reads the byte at the current position straight from the
+// mapping and advances the position, so byte-oriented parsers need no
+// bufio.Reader on top. It returns io.EOF at the end of the file.
+func (mf *MappedFile) ReadByte() (byte, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
+	}
+
+	limit, err := mf.readLimit()
+	if err != nil {
+		return 0, err
+	}
+
+	if mf.position >= limit {
+		return 0, io.EOF
+	}
+
+	if mf.data == nil {
+		return 0, ErrNotMapped
+	}
+
+	// For windowed mapping, ensure window contains position
+	if mf.windowSize > 0 {
+		if err := mf.ensureInWindow(mf.position); err != nil {
+			return 0, err
+		}
+	}
+
+	window, err := mf.windowUpTo(mf.fileOffsetToWindowOffset(mf.position), limit)
+	if err != nil {
+		return 0, err
+	}
+	mf.position++
+	return window[0], nil
+}
+
+// UnreadByte moves the position back one byte. Like strings.Reader, it may
+// follow any read or seek, not only ReadByte; at the start of the file it
+// fails with ErrInvalidOffset.
+func (mf *MappedFile) UnreadByte() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.position <= 0 {
+		return ErrInvalidOffset
+	}
+
+	mf.position--
+	return nil
+}
+
 // ReadAt reads data at a specific offset without changing the file position.
+// Reading at exactly the end of the file returns (0, io.EOF); offsets past
+// the end or negative fail with ErrInvalidOffset.
+// For windowed mappings, reads within the current window share the read lock
+// so concurrent readers proceed in parallel; only a read that must slide the
+// window takes the write lock.
 func (mf *MappedFile) ReadAt(p []byte, off int64) (int, error) {
-	// For windowing, we need write lock to potentially slide window
-	if mf.windowSize > 0 {
-		mf.mu.Lock()
-		defer mf.mu.Unlock()
-	} else {
-		mf.mu.RLock()
+	if err := mf.rlockMapped(); err != nil {
+		return 0, err
+	}
+	if !mf.windowed || mf.inWindow(off) {
 		defer mf.mu.RUnlock()
+		return mf.readAtLocked(p, off)
+	}
+	mf.mu.RUnlock()
+
+	// Need write lock to slide window
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
 	}
 
+	return mf.readAtLocked(p, off)
+}
+
+// readAtLocked implements ReadAt. The caller must hold the read lock, or the
+// write lock if off may lie outside the current window.
+func (mf *MappedFile) readAtLocked(p []byte, off int64) (int, error) {
 	if mf.data == nil {
 		return mf.file.ReadAt(p, off)
 	}
 
-	if off < 0 || off >= mf.size {
+	if off < 0 || off > mf.size {
 		return 0, ErrInvalidOffset
 	}
 
+	limit, err := mf.readLimit()
+	if err != nil {
+		return 0, err
+	}
+
+	// Reading exactly at the end is EOF, as io.ReaderAt expects
+	if off >= limit {
+		return 0, io.EOF
+	}
+
 	// For windowed mapping, ensure window contains offset
 	if mf.windowSize > 0 {
 		if err := mf.ensureInWindow(off); err != nil {
@@ -155,7 +412,11 @@ func (mf *MappedFile) ReadAt(p []byte, off int64) (int, error) {
 	windowOff := mf.fileOffsetToWindowOffset(off)
 
 	// Copy from mapped memory at offset
-	n := copy(p, mf.data[windowOff:])
+	window, err := mf.windowUpTo(windowOff, limit)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, window)
 
 	// ReadAt should return EOF if we can't read len(p) bytes
 	if n < len(p) {
@@ -165,24 +426,202 @@ func (mf *MappedFile) ReadAt(p []byte, off int64) (int, error) {
 	return n, nil
 }
 
+// ReadAtZeroCopy returns length bytes at off as a slice of the mapping
+// itself, so that large reads avoid the copy ReadAt makes. If the range
+// extends past the end of the file, the available bytes are returned with
+// io.EOF, as with ReadAt. For windowed mappings the range must lie within
+// one window; a range crossing the end of the window fails with
+// ErrCrossesWindow, and ReadAt or ReadAtv should be used instead.
+//
+// The slice aliases mapped memory: it must not be modified for read-only
+// mappings, reflects later writes to the file, and is invalid after Close,
+// Drain or a window slide (use Pin to hold it).
+func (mf *MappedFile) ReadAtZeroCopy(off, length int64) ([]byte, error) {
+	// For windowing, we need write lock to potentially slide window
+	exclusive, err := mf.lockForAccess()
+	if err != nil {
+		return nil, err
+	}
+	defer mf.unlockAccess(exclusive)
+
+	if off < 0 || length < 0 || off > mf.size {
+		return nil, ErrInvalidOffset
+	}
+
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	limit, err := mf.readLimit()
+	if err != nil {
+		return nil, err
+	}
+	if off >= limit {
+		return nil, io.EOF
+	}
+
+	var eof error
+	if length > limit-off {
+		length = limit - off
+		eof = io.EOF
+	}
+
+	// For windowed mapping, ensure window contains offset
+	if mf.windowSize > 0 {
+		if err := mf.ensureInWindow(off); err != nil {
+			return nil, err
+		}
+	}
+
+	start := mf.fileOffsetToWindowOffset(off)
+	if end := start + length; end > int64(len(mf.data)) {
+		return nil, fmt.Errorf("read of %d bytes at offset %d crosses the end of window [%d, %d): %w",
+			length, off, mf.windowOffset, mf.windowOffset+int64(len(mf.data)), ErrCrossesWindow)
+	}
+
+	return mf.data[start : start+length : start+length], eof
+}
+
+// ReadAtv fills the buffers in order from the contiguous file range starting
+// at off, like preadv(2) but as plain memory copies. The range may span
+// multiple windows. If the buffers extend past the end of the file, the
+// available bytes are copied and io.EOF is returned.
+func (mf *MappedFile) ReadAtv(bufs [][]byte, off int64) (int, error) {
+	// For windowing, we need write lock to potentially slide window
+	exclusive, err := mf.lockForAccess()
+	if err != nil {
+		return 0, err
+	}
+	defer mf.unlockAccess(exclusive)
+
+	if mf.data == nil {
+		n := 0
+		for _, p := range bufs {
+			k, err := mf.file.ReadAt(p, off+int64(n))
+			n += k
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	}
+
+	if off < 0 || off > mf.size {
+		return 0, ErrInvalidOffset
+	}
+
+	limit, err := mf.readLimit()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, p := range bufs {
+		for len(p) > 0 {
+			cur := off + int64(n)
+			if cur >= limit {
+				return n, io.EOF
+			}
+
+			// For windowed mapping, ensure window contains offset
+			if mf.windowSize > 0 {
+				if err := mf.ensureInWindow(cur); err != nil {
+					return n, err
+				}
+			}
+
+			// Copy as much as the current window holds
+			window, err := mf.windowUpTo(mf.fileOffsetToWindowOffset(cur), limit)
+			if err != nil {
+				return n, err
+			}
+			k := copy(p, window)
+			p = p[k:]
+			n += k
+		}
+	}
+
+	return n, nil
+}
+
+// ReadAll returns a copy of the entire file contents, independent of the
+// current position. Windowed mappings are copied one window at a time.
+// Unlike Data, the returned slice is ordinary heap memory and remains valid
+// after Close.
+func (mf *MappedFile) ReadAll() ([]byte, error) {
+	// For windowing, we need write lock to potentially slide window
+	exclusive, err := mf.lockForAccess()
+	if err != nil {
+		return nil, err
+	}
+	defer mf.unlockAccess(exclusive)
+
+	limit, err := mf.readLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, limit)
+
+	if mf.data == nil {
+		n, err := mf.file.ReadAt(buf, 0)
+		if err == io.EOF && int64(n) == mf.size {
+			err = nil
+		}
+		return buf[:n], err
+	}
+
+	var off int64
+	for off < limit {
+		if mf.windowSize > 0 {
+			if err := mf.ensureInWindow(off); err != nil {
+				return nil, err
+			}
+		}
+
+		window, err := mf.windowUpTo(mf.fileOffsetToWindowOffset(off), limit)
+		if err != nil {
+			return nil, err
+		}
+		n := copy(buf[off:], window)
+		if n == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		off += int64(n)
+	}
+
+	return buf, nil
+}
+
 // Write writes data to the mapped memory.
 func (mf *MappedFile) Write(p []byte) (int, error) {
 	mf.mu.Lock()
 	defer mf.mu.Unlock()
 
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
+	}
+
 	// If not mapped, delegate to underlying file
 	if mf.data == nil {
 		return mf.file.Write(p)
 	}
 
 	// Check if read-only
-	if mf.config.Mode == ModeReadOnly {
+	if !mf.config.Mode.isWritable() {
 		return 0, ErrWriteToReadOnlyMap
 	}
 
+	// Extend the file to fit the write if configured
+	if end := mf.position + int64(len(p)); end > mf.size && mf.config.GrowOnWriteAt {
+		if err := mf.growLocked(end); err != nil {
+			return 0, err
+		}
+	}
+
 	// Check if write would exceed file size
 	if mf.position+int64(len(p)) > mf.size {
-		return 0, io.ErrShortWrite
+		return 0, mf.errPastEOF(len(p), mf.position)
 	}
 
 	// For windowed mapping, ensure window contains position
@@ -197,13 +636,14 @@ func (mf *MappedFile) Write(p []byte) (int, error) {
 
 	// Check if write would exceed current window
 	if windowPos+int64(len(p)) > int64(len(mf.data)) {
-		return 0, io.ErrShortWrite
+		return 0, mf.errPastWindow(len(p), mf.position)
 	}
 
 	// Direct memory copy to mapped region
 	n := copy(mf.data[windowPos:], p)
 	mf.position += int64(n)
 	mf.modified = true
+	mf.noteDirty(n)
 
 	// Sync based on mode
 	if mf.config.SyncMode == SyncImmediate {
@@ -216,20 +656,53 @@ func (mf *MappedFile) Write(p []byte) (int, error) {
 }
 
 // WriteAt writes data at a specific offset.
+// With SyncImmediate the mapping is synced before returning. If that sync
+// fails, WriteAt returns (len(p), err): the bytes are in the mapping and
+// visible to readers, but err means they may not be on disk. Use
+// DurableWriteAt to learn how many of them are.
 func (mf *MappedFile) WriteAt(p []byte, off int64) (int, error) {
 	mf.mu.Lock()
 	defer mf.mu.Unlock()
 
+	n, err := mf.writeAtLocked(p, off)
+	if err != nil {
+		return n, err
+	}
+
+	// Sync based on mode
+	if mf.config.SyncMode == SyncImmediate {
+		if err := mf.syncLocked(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// writeAtLocked is WriteAt without the sync. The caller must hold the
+// write lock.
+func (mf *MappedFile) writeAtLocked(p []byte, off int64) (int, error) {
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
+	}
+
 	// If not mapped, delegate to underlying file
 	if mf.data == nil {
 		return mf.file.WriteAt(p, off)
 	}
 
 	// Check if read-only
-	if mf.config.Mode == ModeReadOnly {
+	if !mf.config.Mode.isWritable() {
 		return 0, ErrWriteToReadOnlyMap
 	}
 
+	// Extend the file to fit the write if configured
+	if end := off + int64(len(p)); off >= 0 && end > mf.size && mf.config.GrowOnWriteAt {
+		if err := mf.growLocked(end); err != nil {
+			return 0, err
+		}
+	}
+
 	// Validate offset
 	if off < 0 || off >= mf.size {
 		return 0, ErrInvalidOffset
@@ -237,7 +710,7 @@ func (mf *MappedFile) WriteAt(p []byte, off int64) (int, error) {
 
 	// Check if write would exceed file size
 	if off+int64(len(p)) > mf.size {
-		return 0, io.ErrShortWrite
+		return 0, mf.errPastEOF(len(p), off)
 	}
 
 	// For windowed mapping, ensure window contains offset
@@ -252,43 +725,124 @@ func (mf *MappedFile) WriteAt(p []byte, off int64) (int, error) {
 
 	// Check if write would exceed current window
 	if windowOff+int64(len(p)) > int64(len(mf.data)) {
-		return 0, io.ErrShortWrite
+		return 0, mf.errPastWindow(len(p), off)
 	}
 
 	// Direct memory copy to mapped region at offset
 	n := copy(mf.data[windowOff:], p)
 	mf.modified = true
-
-	// Sync based on mode
-	if mf.config.SyncMode == SyncImmediate {
-		if err := mf.syncLocked(); err != nil {
-			return n, err
-		}
-	}
-
+	mf.noteDirty(n)
 	return n, nil
 }
 
-// Seek sets the file position for the next Read or Write.
-func (mf *MappedFile) Seek(offset int64, whence int) (int64, error) {
+// WriteAtv writes the buffers back to back starting at off, as if they had
+// been joined and passed to WriteAt, but without the intermediate allocation.
+// Unlike WriteAt, the combined write may span multiple windows.
+func (mf *MappedFile) WriteAtv(bufs [][]byte, off int64) (int, error) {
 	mf.mu.Lock()
 	defer mf.mu.Unlock()
 
-	if mf.data == nil {
-		return mf.file.Seek(offset, whence)
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
 	}
 
-	var newPos int64
-
-	switch whence {
-	case io.SeekStart:
-		newPos = offset
-	case io.SeekCurrent:
-		newPos = mf.position + offset
-	case io.SeekEnd:
-		newPos = mf.size + offset
-	default:
-		return 0, ErrInvalidWhence
+	// If not mapped, delegate to underlying file
+	if mf.data == nil {
+		n := 0
+		for _, p := range bufs {
+			k, err := mf.file.WriteAt(p, off+int64(n))
+			n += k
+			if err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	}
+
+	// Check if read-only
+	if !mf.config.Mode.isWritable() {
+		return 0, ErrWriteToReadOnlyMap
+	}
+
+	var total int64
+	for _, p := range bufs {
+		total += int64(len(p))
+	}
+
+	// Extend the file to fit the write if configured
+	if end := off + total; off >= 0 && end > mf.size && mf.config.GrowOnWriteAt {
+		if err := mf.growLocked(end); err != nil {
+			return 0, err
+		}
+	}
+
+	// Validate offset
+	if off < 0 || off >= mf.size {
+		return 0, ErrInvalidOffset
+	}
+
+	// Check if the combined write would exceed file size
+	if off+total > mf.size {
+		return 0, mf.errPastEOF(int(total), off)
+	}
+
+	n := 0
+	for _, p := range bufs {
+		for len(p) > 0 {
+			cur := off + int64(n)
+
+			// For windowed mapping, ensure window contains offset
+			if mf.windowSize > 0 {
+				if err := mf.ensureInWindow(cur); err != nil {
+					return n, err
+				}
+			}
+
+			// Copy as much as fits in the current window
+			k := copy(mf.data[mf.fileOffsetToWindowOffset(cur):], p)
+			p = p[k:]
+			n += k
+			mf.modified = true
+		}
+	}
+	mf.noteDirty(n)
+
+	// Sync based on mode
+	if mf.config.SyncMode == SyncImmediate {
+		if err := mf.syncLocked(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// Seek sets the file position for the next Read or Write.
+// io.SeekEnd is relative to the current size, read under the lock, so it
+// reflects growth picked up by Remap.
+func (mf *MappedFile) Seek(offset int64, whence int) (int64, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
+	}
+
+	if mf.data == nil {
+		return mf.file.Seek(offset, whence)
+	}
+
+	var newPos int64
+
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = mf.position + offset
+	case io.SeekEnd:
+		newPos = mf.size + offset
+	default:
+		return 0, ErrInvalidWhence
 	}
 
 	if newPos < 0 {
@@ -299,20 +853,63 @@ func (mf *MappedFile) Seek(offset int64, whence int) (int64, error) {
 	return newPos, nil
 }
 
+// Reset prepares the file for reuse, e.g. from a sync.Pool, without
+// remapping. It is equivalent to ResetTo(0).
+func (mf *MappedFile) Reset() error {
+	return mf.ResetTo(0)
+}
+
+// ResetTo syncs any pending writes, clears the modified state and moves the
+// position to off. The mapping and its contents are left intact: Reset does
+// not zero or reload data, so a reused file sees whatever was last written.
+func (mf *MappedFile) ResetTo(off int64) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if off < 0 {
+		return ErrInvalidOffset
+	}
+
+	if mf.modified && mf.data != nil {
+		if err := mf.syncLocked(); err != nil {
+			return err
+		}
+	}
+
+	mf.modified = false
+	mf.position = off
+	return nil
+}
+
 // Close unmaps the memory and closes the underlying file.
+// It blocks until every slice handed out by Pin has been released with Unpin.
 func (mf *MappedFile) Close() error {
 	mf.mu.Lock()
 	defer mf.mu.Unlock()
 
+	for mf.pins > 0 {
+		mf.unpinned.Wait()
+	}
+	mf.closed = true
+
 	var err error
 
 	// Unregister from sync manager
 	if mf.syncManager != nil {
 		mf.syncManager.unregister(mf)
 	}
+	if mf.open != nil {
+		mf.open.remove(mf)
+	}
+
+	// Stop any watchers
+	if mf.done != nil {
+		close(mf.done)
+		mf.done = nil
+	}
 
 	// Sync if modified
-	if mf.modified && mf.data != nil {
+	if mf.modified && mf.data != nil && !mf.config.NoSyncOnClose {
 		if syncErr := mf.syncLocked(); syncErr != nil {
 			err = syncErr
 		}
@@ -345,41 +942,826 @@ func (mf *MappedFile) Stat() (fs.FileInfo, error) {
 
 // Sync synchronizes the file's in-memory state with storage.
 // For mapped files, this syncs dirty pages to disk.
+// If a SyncImmediate msync fails, the mapped region is written back through
+// the underlying file instead; should that also fail part way, a
+// *PartialSyncError reports how far the region is durable.
+// If the backing file has been removed while mapped, pending writes cannot
+// reach any path and ErrBackingRemoved is returned; reads keep working.
 func (mf *MappedFile) Sync() error {
 	mf.mu.Lock()
 	defer mf.mu.Unlock()
 
+	return mf.syncCheckedLocked()
+}
+
+// Flush writes the pages covering the file range [off, off+length) to disk
+// and waits for them, whatever the SyncMode, without touching the rest of
+// the mapping; on Windows the view is flushed for just that range. The
+// range must lie within the mapped region (the current window, if
+// windowed). Read-only and copy-on-write mappings have nothing to flush.
+// Unlike Sync, Flush does not reset DirtyBytes, since other ranges may
+// still be unsynced.
+func (mf *MappedFile) Flush(off, length int64) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return err
+	}
+
+	span, err := mf.adviseSpan(off, length)
+	if err != nil {
+		return err
+	}
+
+	if !mf.config.Mode.isWritable() || mf.config.Mode == ModeCopyOnWrite {
+		return nil
+	}
+
+	if mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
+	return mf.msyncRange(span)
+}
+
+// syncIfOpen is Sync for the sync manager, which may still hold a file
+// that was closed after it took its snapshot. It reports false, without
+// syncing, if the file is closed. It also returns the file's config as of
+// the sync, since Freeze may replace it once the lock is released.
+func (mf *MappedFile) syncIfOpen() (bool, *Config, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.closed {
+		return false, mf.config, nil
+	}
+
+	return true, mf.config, mf.syncCheckedLocked()
+}
+
+// syncCheckedLocked is syncLocked, failing with ErrBackingRemoved instead
+// if the backing file is gone. The caller must hold the write lock.
+func (mf *MappedFile) syncCheckedLocked() error {
+	if mf.data != nil && mf.modified && mf.config.Mode.isWritable() && mf.backingRemoved() {
+		return ErrBackingRemoved
+	}
+
 	return mf.syncLocked()
 }
 
 // syncLocked performs sync without acquiring the lock (caller must hold lock).
 func (mf *MappedFile) syncLocked() error {
+	return mf.syncRecoverLocked(mf.config.SyncMode == SyncImmediate)
+}
+
+// syncRecoverLocked is syncLocked, writing the mapping back through the
+// file if msync fails when recover is set (see recoverSync). The caller
+// must hold the write lock.
+func (mf *MappedFile) syncRecoverLocked(recover bool) error {
 	if mf.data == nil {
 		return mf.file.Sync()
 	}
 
 	// For read-only mappings, no sync needed
-	if mf.config.Mode == ModeReadOnly {
+	if !mf.config.Mode.isWritable() {
 		return nil
 	}
 
 	// Only sync if modified
 	if !mf.modified {
+		mf.lastSync = time.Now()
 		return nil
 	}
 
 	// Platform-specific sync implementation
-	return mf.msync()
+	if err := mf.msync(); err != nil {
+		// Find out how much can still be made durable, e.g. on ENOSPC
+		if !recover || mf.config.Mode == ModeCopyOnWrite {
+			return err
+		}
+		if err := mf.recoverSync(err); err != nil {
+			return err
+		}
+	}
+
+	mf.dirty = 0
+	mf.flushPending = false
+	mf.lastSync = time.Now()
+	return nil
+}
+
+// noteDirty records n bytes written and, once Config.SyncDirtyThreshold is
+// exceeded, asks the sync manager to flush the file before its next tick.
+// The caller must hold the write lock.
+func (mf *MappedFile) noteDirty(n int) {
+	mf.dirty += int64(n)
+
+	threshold := mf.config.SyncDirtyThreshold
+	if threshold <= 0 || mf.dirty <= threshold || mf.flushPending {
+		return
+	}
+
+	if mf.syncManager != nil && mf.config.SyncMode == SyncPeriodic {
+		mf.flushPending = mf.syncManager.requestFlush(mf)
+	}
+}
+
+// AdviseRemove discards the contents of the currently mapped region, which
+// reads as zeros afterwards on every platform. Unlike AdviseDontNeed this is
+// destructive: for shared writable mappings the zeros reach the file.
+//
+// The backing storage is deallocated where the platform can punch holes
+// (fallocate on Linux, FSCTL_SET_ZERO_DATA on sparse Windows files);
+// elsewhere, and for copy-on-write mappings, the region is zero-filled.
+func (mf *MappedFile) AdviseRemove() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return err
+	}
+
+	if mf.data == nil {
+		return ErrNotMapped
+	}
+
+	if !mf.config.Mode.isWritable() {
+		return ErrWriteToReadOnlyMap
+	}
+
+	// Private copies must not touch the file
+	if mf.config.Mode != ModeCopyOnWrite && mf.mapper == nil {
+		var base int64
+		if mf.windowSize > 0 {
+			base = mf.windowOffset
+		}
+		if err := mf.punchHole(base, int64(len(mf.data))); err == nil {
+			return nil
+		}
+	}
+
+	clear(mf.data)
+	mf.modified = true
+	mf.noteDirty(len(mf.data))
+	return nil
 }
 
 // Truncate changes the size of the file.
-// For mapped files, this is not supported in Phase 1.
+// A shared writable mapping is unmapped, resized and mapped again, which
+// works on every platform including Windows, where a mapped file cannot
+// change size. Growth extends the file with ftruncate (SetEndOfFile on
+// Windows), so the new tail reads as zeros (allocated rather than sparse
+// with Config.ZeroFillGrowth). A windowed file is remapped at the window
+// holding the position, so growing a file whose last window was partial
+// extends that window to the full WindowSize and writes into the new tail
+// need no slide. On Linux, growing a full-file mapping first tries mremap
+// without MREMAP_MAYMOVE, extending the mapping at its current address, and
+// falls back to unmapping and remapping if the address range beyond it is
+// taken; other platforms always remap. Slices returned by Data are invalid
+// after a resize in either case.
 func (mf *MappedFile) Truncate(size int64) error {
-	// Cannot truncate a mapped file
-	if mf.data != nil {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return err
+	}
+
+	if mf.data == nil {
+		return mf.file.Truncate(size)
+	}
+
+	switch {
+	case size == mf.size:
+		return nil
+	case size < 0:
+		return ErrInvalidOffset
+	case mf.config.Mode.isWritable() && mf.config.Mode != ModeCopyOnWrite:
+		return mf.resizeLocked(size)
+	default:
+		// Cannot truncate a read-only or private mapping
 		return errors.New("cannot truncate mapped file")
 	}
-	return mf.file.Truncate(size)
+}
+
+// Size returns the logical size of the mapped file in bytes.
+// Unlike Stat, it reads the cached size and makes no syscall.
+func (mf *MappedFile) Size() int64 {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.size
+}
+
+// readLimit returns the offset at which reads stop: the mapped size or,
+// with Config.TruncationIsEOF, the file's current size if it is smaller.
+func (mf *MappedFile) readLimit() (int64, error) {
+	if !mf.config.TruncationIsEOF {
+		return mf.size, nil
+	}
+
+	fi, err := mf.StatCached(false)
+	if err != nil {
+		return 0, fmt.Errorf("stat failed: %w", err)
+	}
+
+	return min(mf.size, fi.Size()), nil
+}
+
+// windowUpTo returns the current window from windowPos, cut off at file
+// offset limit. Callers only ask for positions before limit, so a windowPos
+// outside the mapping means mmap returned less than the file claims (some
+// special files do); that fails with ErrShortMapping instead of panicking.
+func (mf *MappedFile) windowUpTo(windowPos, limit int64) ([]byte, error) {
+	if windowPos < 0 || windowPos >= int64(len(mf.data)) {
+		return nil, fmt.Errorf("%s: offset %d of a %d-byte mapping: %w", mf.file.Name(), windowPos, len(mf.data), ErrShortMapping)
+	}
+
+	end := mf.fileOffsetToWindowOffset(limit)
+	if end > int64(len(mf.data)) {
+		end = int64(len(mf.data))
+	}
+	return mf.data[windowPos:end], nil
+}
+
+// StatCached returns file info for the underlying file, reusing the result
+// of a previous stat if it is younger than Config.StatCacheInterval. Pass
+// forceRefresh to always stat. Size, which reports the mapped size, never
+// stats at all.
+func (mf *MappedFile) StatCached(forceRefresh bool) (fs.FileInfo, error) {
+	mf.statMu.Lock()
+	defer mf.statMu.Unlock()
+
+	interval := mf.config.StatCacheInterval
+	if !forceRefresh && interval > 0 && mf.statInfo != nil && time.Since(mf.statTime) < interval {
+		return mf.statInfo, nil
+	}
+
+	fi, err := mf.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	mf.statInfo = fi
+	mf.statTime = time.Now()
+	return fi, nil
+}
+
+// LastSyncTime returns when the file was last synced successfully, by Sync
+// or the periodic sync manager. It is the zero time if it never was.
+func (mf *MappedFile) LastSyncTime() time.Time {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.lastSync
+}
+
+// IsDirty reports whether the file has been written through its methods
+// since it was last synced. Stores through the slice returned by Data are
+// not seen.
+func (mf *MappedFile) IsDirty() bool {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.dirty > 0
+}
+
+// DirtyBytes returns the number of bytes written since the file was last
+// synced, or 0 if it is clean. Writes are counted rather than ranges
+// tracked, so rewriting the same bytes counts them again: the result is an
+// upper bound on the unsynced data, suited to ordering files for flushing.
+func (mf *MappedFile) DirtyBytes() int64 {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+	return mf.dirty
+}
+
+// Remap refreshes the mapping to the current size of the underlying file,
+// e.g. after another writer has appended to it. Slices previously returned
+// by Data are invalid after a successful remap.
+func (mf *MappedFile) Remap() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	fi, err := mf.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+
+	return mf.remapLocked(fi.Size())
+}
+
+// Reopen opens the file's path again and maps the file found there, e.g.
+// after it was atomically replaced by a rename, which leaves the existing
+// mapping on the old file. Pending writes are synced to the old file first.
+// The position is kept. Slices previously returned by Data are invalid
+// after a successful reopen. Only files opened through MemMapFS.OpenFile
+// can be reopened, and not once closed.
+func (mf *MappedFile) Reopen() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.closed {
+		return os.ErrClosed
+	}
+
+	if mf.reopen == nil {
+		return &os.PathError{Op: "reopen", Path: mf.file.Name(), Err: errors.ErrUnsupported}
+	}
+
+	if mf.pins > 0 {
+		return ErrPinned
+	}
+
+	// Writes since the last sync belong to the old file
+	if mf.modified && mf.data != nil {
+		if err := mf.syncLocked(); err != nil {
+			return err
+		}
+		mf.modified = false
+	}
+
+	file, err := mf.reopen()
+	if err != nil {
+		return err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("stat failed: %w", err)
+	}
+
+	if err := mf.munmap(); err != nil {
+		file.Close()
+		return err
+	}
+	mf.data = nil
+
+	old := mf.file
+	mf.file = file
+	mf.mapper, _ = file.(Mmapper)
+	mf.fd = 0
+	mf.statMu.Lock()
+	mf.statInfo = nil
+	mf.statMu.Unlock()
+	old.Close()
+
+	if err := mf.remapLocked(fi.Size()); err != nil {
+		return err
+	}
+
+	// The mapping stays valid without the descriptor
+	if mf.config.CloseFdAfterMap && mf.data != nil {
+		detached, err := detach(file)
+		if err != nil {
+			return err
+		}
+		mf.file = detached
+	}
+
+	return nil
+}
+
+// remapLocked replaces the mapping with one covering newSize bytes.
+// The caller must hold the write lock.
+func (mf *MappedFile) remapLocked(newSize int64) error {
+	if newSize == mf.size && mf.data != nil {
+		return nil
+	}
+
+	if mf.pins > 0 {
+		return ErrPinned
+	}
+
+	if err := mf.munmap(); err != nil {
+		return err
+	}
+	mf.data = nil
+	mf.size = newSize
+	mf.drained.Store(false)
+	mf.checksums = nil
+	mf.mismatch = false
+
+	// Empty files are not mapped; I/O falls through to the underlying file
+	if newSize == 0 {
+		return nil
+	}
+
+	// A file mapped whole because it fit in one window is windowed once it
+	// outgrows it
+	if !mf.windowed && mf.shouldWindow(newSize) {
+		mf.windowed = true
+		mf.windowSize = mf.baseWindowSize()
+	}
+
+	// Map the window holding the file position, so the next Read or Write
+	// need not slide, keeping it inside the file
+	if mf.windowSize > 0 {
+		target := mf.position
+		if target >= newSize {
+			target = newSize - 1
+		}
+		mf.windowOffset = (target / mf.windowSize) * mf.windowSize
+	}
+
+	return mf.mmap()
+}
+
+// errPastEOF describes a write of n bytes at off that does not fit in the
+// file. It wraps io.ErrShortWrite.
+func (mf *MappedFile) errPastEOF(n int, off int64) error {
+	return fmt.Errorf("write of %d bytes at offset %d exceeds file size %d: %w", n, off, mf.size, io.ErrShortWrite)
+}
+
+// errPastWindow describes a write of n bytes at off that crosses the end of
+// the current window. It wraps io.ErrShortWrite.
+func (mf *MappedFile) errPastWindow(n int, off int64) error {
+	end := mf.windowOffset + int64(len(mf.data))
+	return fmt.Errorf("write of %d bytes at offset %d crosses the end of window [%d, %d); use WriteAtv or a larger WindowSize: %w",
+		n, off, mf.windowOffset, end, io.ErrShortWrite)
+}
+
+// growLocked extends the underlying file to newSize, leaving a sparse hole
+// (or zeros written out, with Config.ZeroFillGrowth), and remaps it.
+// Copy-on-write mappings cannot grow since that would modify the file.
+// The caller must hold the write lock.
+func (mf *MappedFile) growLocked(newSize int64) error {
+	if mf.config.Mode == ModeCopyOnWrite {
+		return io.ErrShortWrite
+	}
+
+	if err := mf.resizeLocked(newSize); err != nil {
+		return fmt.Errorf("grow failed: %w", err)
+	}
+	return nil
+}
+
+// resizeLocked sets the size of the underlying file and remaps it. The
+// mapping is released first, since Windows refuses to resize a file with
+// mapped views. On failure the old mapping is restored. The caller must
+// hold the write lock.
+func (mf *MappedFile) resizeLocked(newSize int64) error {
+	if mf.pins > 0 {
+		return ErrPinned
+	}
+
+	oldSize := mf.size
+	if mf.canGrowInPlace(newSize) {
+		// Keep the mapping while the file grows, so it can be extended
+		// where it is
+		err := mf.file.Truncate(newSize)
+		if err == nil && mf.config.ZeroFillGrowth {
+			err = mf.zeroFill(oldSize, newSize)
+		}
+		if err != nil {
+			return err
+		}
+		if mf.growInPlace(newSize) {
+			mf.size = newSize
+			return nil
+		}
+		return mf.remapLocked(newSize)
+	}
+
+	if err := mf.munmap(); err != nil {
+		return err
+	}
+	mf.data = nil
+
+	err := mf.file.Truncate(newSize)
+	if err == nil && mf.config.ZeroFillGrowth && newSize > oldSize {
+		err = mf.zeroFill(oldSize, newSize)
+	}
+	if err != nil {
+		if mapErr := mf.mmap(); mapErr != nil {
+			return errors.Join(err, mapErr)
+		}
+		return err
+	}
+
+	return mf.remapLocked(newSize)
+}
+
+// canGrowInPlace reports whether growing the file to newSize may extend the
+// current mapping in place instead of unmapping and remapping it. Only a
+// full-file mapping of the file itself qualifies, and only on Linux, where
+// growInPlace uses mremap; elsewhere a mapped file may not even be
+// truncatable.
+func (mf *MappedFile) canGrowInPlace(newSize int64) bool {
+	return growInPlaceSupported && newSize > mf.size && mf.mmapData != nil &&
+		mf.windowSize == 0 && !mf.shouldWindow(newSize) &&
+		mf.mapper == nil && mf.remote == nil && !mf.config.UseHugePages
+}
+
+// zeroFill writes zeros to [from, to) of the underlying file so that the
+// range is allocated on disk rather than left as a hole.
+func (mf *MappedFile) zeroFill(from, to int64) error {
+	return writeZeros(mf.file, from, to)
+}
+
+// writeZeros writes zeros to [from, to) of file, allocating the range.
+func writeZeros(file absfs.File, from, to int64) error {
+	zeros := make([]byte, min(to-from, zeroFillChunk))
+	for off := from; off < to; {
+		n, err := file.WriteAt(zeros[:min(to-off, int64(len(zeros)))], off)
+		off += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Addr returns the virtual address at which the current mapping (or window)
+// begins, for correlating with tools such as pmap or gdb. It returns 0 if the
+// file is not mapped. The value is only meaningful while the file is open and
+// changes when a window slides or the file is remapped.
+func (mf *MappedFile) Addr() uintptr {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	if len(mf.mmapData) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&mf.mmapData[0]))
+}
+
+// adviseSpan returns the pages of the mapping covering file offsets
+// [off, off+length), for advice on part of the mapping. The range must lie
+// within the mapped region (the current window, if windowed). The caller
+// must hold mf.mu.
+func (mf *MappedFile) adviseSpan(off, length int64) ([]byte, error) {
+	if mf.mmapData == nil || len(mf.data) == 0 {
+		return nil, ErrNotMapped
+	}
+
+	start := mf.fileOffsetToWindowOffset(off)
+	if off < 0 || length <= 0 || start < 0 || start+length > int64(len(mf.data)) {
+		return nil, ErrInvalidOffset
+	}
+
+	// data may begin past mmapData's page alignment padding
+	pad := int64(uintptr(unsafe.Pointer(&mf.data[0])) - uintptr(unsafe.Pointer(&mf.mmapData[0])))
+	pageSize := int64(os.Getpagesize())
+	from := (pad + start) / pageSize * pageSize
+	to := min(int64(len(mf.mmapData)), (pad+start+length+pageSize-1)/pageSize*pageSize)
+
+	return mf.mmapData[from:to], nil
+}
+
+// preloadSink keeps the page touches in preloadSync from being optimized away.
+var preloadSink atomic.Uint32
+
+// preloadSync touches every page of the mapping and, where mincore is
+// available, repeats for pages that are not resident until none are left or
+// Config.PreloadTimeout expires.
+func (mf *MappedFile) preloadSync() error {
+	data := mf.mmapData
+	if len(data) == 0 {
+		return nil
+	}
+
+	// Start readahead so the touches below mostly hit the page cache
+	_ = mf.preload()
+
+	pageSize := os.Getpagesize()
+	pages := (len(data) + pageSize - 1) / pageSize
+	resident := make([]byte, pages)
+
+	var deadline time.Time
+	if mf.config.PreloadTimeout > 0 {
+		deadline = time.Now().Add(mf.config.PreloadTimeout)
+	}
+
+	for {
+		var sum byte
+		for i := 0; i < pages; i++ {
+			if resident[i]&1 == 0 {
+				sum += data[i*pageSize]
+			}
+		}
+		preloadSink.Add(uint32(sum))
+
+		err := mincore(data, resident)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("mincore failed: %w", err)
+		}
+
+		missing := 0
+		for _, r := range resident {
+			if r&1 == 0 {
+				missing++
+			}
+		}
+		if missing == 0 {
+			return nil
+		}
+
+		// Pages were evicted again after being touched, e.g. under memory pressure
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return fmt.Errorf("%d of %d pages not resident after %v: %w", missing, pages, mf.config.PreloadTimeout, ErrPreloadTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// mapSegment replaces the mapping with a single window covering exactly
+// [offset, offset+length) of the file. The file must have been opened with
+// windowing, so that the segment is treated as a window.
+func (mf *MappedFile) mapSegment(offset, length int64) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if offset < 0 || length <= 0 || offset+length > mf.size {
+		return ErrInvalidOffset
+	}
+
+	if err := mf.munmap(); err != nil {
+		return err
+	}
+	mf.data = nil
+
+	mf.windowSize = length
+	mf.windowOffset = offset
+	mf.drained.Store(false)
+	return mf.mmap()
+}
+
+// Drain unmaps the file, releasing its mapped pages, but keeps it open:
+// the position, descriptor and configuration stay as they are, and the
+// next Read, Write, Seek or similar call transparently remaps the same
+// window. Pending writes are synced first. Slices returned by Data are
+// invalid afterwards, and Data returns nil until the file is remapped.
+//
+// A modified copy-on-write mapping cannot be drained without losing its
+// private changes and returns ErrCopyOnWriteDrain, and a pinned one, such as
+// the mapping behind a SharedMemory, returns ErrPinned. A file opened with
+// CloseFdAfterMap has no descriptor to remap from and returns os.ErrClosed.
+func (mf *MappedFile) Drain() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	return mf.drainLocked()
+}
+
+// EvictWindow flushes and unmaps the window covering offset, leaving the
+// file open like Drain, so that memory for a region the caller is done
+// with can be released precisely. Files keep a single window (the whole
+// file, for full mappings), so this does nothing unless that window is
+// the one covering offset. Offsets outside the file fail with
+// ErrInvalidOffset.
+func (mf *MappedFile) EvictWindow(offset int64) error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if offset < 0 || offset >= mf.size {
+		return ErrInvalidOffset
+	}
+
+	if !mf.inWindow(offset) {
+		return nil
+	}
+
+	return mf.drainLocked()
+}
+
+// drainLocked implements Drain. The caller must hold the write lock.
+func (mf *MappedFile) drainLocked() error {
+	if mf.data == nil {
+		return nil
+	}
+
+	if mf.config.Mode == ModeCopyOnWrite && mf.modified {
+		return ErrCopyOnWriteDrain
+	}
+
+	if _, ok := mf.file.(*detachedFile); ok {
+		return os.ErrClosed
+	}
+
+	if mf.pins > 0 {
+		return ErrPinned
+	}
+
+	if mf.modified {
+		if err := mf.syncLocked(); err != nil {
+			return err
+		}
+		mf.modified = false
+	}
+
+	if err := mf.munmap(); err != nil {
+		return err
+	}
+	mf.data = nil
+	mf.drained.Store(true)
+	return nil
+}
+
+// Freeze makes the file read-only for the rest of its life, e.g. once an
+// initialization phase has filled it: pending writes are synced, write
+// permission is dropped from the mapping so that stray writes through Data
+// fault, and Write, WriteAt and friends return ErrWriteToReadOnlyMap. Later
+// windows and remaps are mapped read-only too. Freezing a read-only file
+// does nothing.
+//
+// A modified copy-on-write window cannot be frozen, since its private
+// changes would be lost when it slides, and returns ErrCopyOnWriteSlide.
+func (mf *MappedFile) Freeze() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if !mf.config.Mode.isWritable() {
+		return nil
+	}
+
+	if mf.config.Mode == ModeCopyOnWrite && mf.modified && mf.windowed {
+		return ErrCopyOnWriteSlide
+	}
+
+	if mf.modified && mf.data != nil {
+		if err := mf.syncLocked(); err != nil {
+			return err
+		}
+		mf.modified = false
+	}
+
+	if err := mf.protectReadOnly(); err != nil {
+		return err
+	}
+
+	// The config may be shared with other files from the same MemMapFS
+	config := *mf.config
+	config.Mode = ModeReadOnly
+	mf.config = &config
+	return nil
+}
+
+// undrainLocked remaps a file released by Drain. The caller must hold the
+// write lock.
+func (mf *MappedFile) undrainLocked() error {
+	if !mf.drained.Load() {
+		return nil
+	}
+
+	if err := mf.mmap(); err != nil {
+		return fmt.Errorf("failed to remap drained file: %w", err)
+	}
+	mf.drained.Store(false)
+	return nil
+}
+
+// rlockMapped acquires the read lock, first remapping the file under the
+// write lock if it has been drained. On success the read lock is held.
+func (mf *MappedFile) rlockMapped() error {
+	mf.mu.RLock()
+	for mf.drained.Load() {
+		mf.mu.RUnlock()
+
+		mf.mu.Lock()
+		err := mf.undrainLocked()
+		mf.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		mf.mu.RLock()
+	}
+	return nil
+}
+
+// lockForAccess acquires the lock for an access that may have to slide the
+// window: the write lock for windowed files, the read lock otherwise, after
+// remapping a drained file. It reports whether the write lock is held; pass
+// that to unlockAccess. Windowing is checked under the lock since growth
+// may switch it on.
+func (mf *MappedFile) lockForAccess() (exclusive bool, err error) {
+	if err := mf.rlockMapped(); err != nil {
+		return false, err
+	}
+	if !mf.windowed {
+		return false, nil
+	}
+	mf.mu.RUnlock()
+
+	// A file never stops being windowed once it is
+	mf.mu.Lock()
+	if err := mf.undrainLocked(); err != nil {
+		mf.mu.Unlock()
+		return false, err
+	}
+	return true, nil
+}
+
+// unlockAccess releases the lock taken by lockForAccess.
+func (mf *MappedFile) unlockAccess(exclusive bool) {
+	if exclusive {
+		mf.mu.Unlock()
+	} else {
+		mf.mu.RUnlock()
+	}
 }
 
 // Name returns the name of the file.
@@ -398,6 +1780,7 @@ func (mf *MappedFile) Readdirnames(n int) ([]string, error) {
 }
 
 // ReadDir reads the contents of the directory and returns a slice of DirEntry values.
+// It makes MappedFile an fs.ReadDirFile.
 func (mf *MappedFile) ReadDir(n int) ([]fs.DirEntry, error) {
 	return mf.file.ReadDir(n)
 }
@@ -407,8 +1790,12 @@ func (mf *MappedFile) WriteString(s string) (int, error) {
 	return mf.Write([]byte(s))
 }
 
-// Ensure MappedFile implements absfs.File
-var _ absfs.File = (*MappedFile)(nil)
+// Ensure MappedFile implements absfs.File, fs.ReadDirFile and io.ByteScanner
+var (
+	_ absfs.File     = (*MappedFile)(nil)
+	_ fs.ReadDirFile = (*MappedFile)(nil)
+	_ io.ByteScanner = (*MappedFile)(nil)
+)
 
 // slideWindow remaps the memory window to include the given file offset.
 // The caller must hold the write lock.
@@ -418,24 +1805,42 @@ func (mf *MappedFile) slideWindow(targetOffset int64) error {
 		return nil
 	}
 
-	// Check if target is already in current window
-	if targetOffset >= mf.windowOffset && targetOffset < mf.windowOffset+int64(len(mf.data)) {
+	// Check if target is already in current window. A window that failed
+	// verification is mapped again, to be verified again.
+	if !mf.mismatch && targetOffset >= mf.windowOffset && targetOffset < mf.windowOffset+int64(len(mf.data)) {
 		return nil
 	}
 
+	// Unmapping a private window discards its changes; msync cannot save them
+	if mf.config.Mode == ModeCopyOnWrite && mf.modified {
+		return ErrCopyOnWriteSlide
+	}
+
+	// Pinned slices must stay valid
+	if mf.pins > 0 {
+		return ErrPinned
+	}
+
 	// Sync current window if modified
 	if mf.modified {
 		if err := mf.msync(); err != nil {
 			return fmt.Errorf("failed to sync before sliding window: %w", err)
 		}
 		mf.modified = false
+		mf.dirty = 0
 	}
+	mf.recordWindowChecksum()
 
 	// Unmap current window
 	if err := mf.munmap(); err != nil {
 		return fmt.Errorf("failed to unmap current window: %w", err)
 	}
 
+	mf.slides++
+	if mf.config.AdaptiveWindow {
+		mf.growWindowIfThrashing()
+	}
+
 	// Calculate new window offset
 	// Align to window boundaries for better performance
 	newOffset := (targetOffset / mf.windowSize) * mf.windowSize
@@ -448,16 +1853,110 @@ func (mf *MappedFile) slideWindow(targetOffset int64) error {
 		}
 	}
 
+	oldOffset := mf.windowOffset
 	mf.windowOffset = newOffset
 
 	// Remap at new offset
-	if err := mf.mmap(); err != nil {
+	err := mf.mmap()
+	for err != nil && mf.config.AdaptiveWindow && isTransientMmapError(err) && mf.windowSize > mf.baseWindowSize() {
+		// Memory pressure: halve a grown window and try again
+		mf.config.warn("memmapfs: remapping grown window failed, halving it",
+			"name", mf.file.Name(), "windowSize", mf.windowSize, "error", err)
+		mf.windowSize /= 2
+		mf.windowOffset = (targetOffset / mf.windowSize) * mf.windowSize
+		newOffset = mf.windowOffset
+		err = mf.mmap()
+	}
+	if err != nil {
 		return fmt.Errorf("failed to remap window: %w", err)
 	}
+	if err := mf.verifyWindowChecksum(); err != nil {
+		return err
+	}
+
+	if newOffset > oldOffset && newOffset-oldOffset <= mf.windowSize {
+		mf.readAheadWindows()
+	}
+
+	if mf.config.OnWindowSlide != nil {
+		mf.config.OnWindowSlide(oldOffset, newOffset, mf.windowSize)
+	}
 
 	return nil
 }
 
+// readAheadDepth returns the number of windows read ahead on sequential
+// slides, or 0 if read-ahead does not apply to this file.
+func (mf *MappedFile) readAheadDepth() int {
+	if !readAheadSupported || mf.windowSize == 0 || mf.remote != nil || mf.mapper != nil {
+		return 0
+	}
+	return max(mf.config.ReadAheadWindows, 0)
+}
+
+// readAheadWindows hints the kernel to read the windows after the current
+// one (Config.ReadAheadWindows). It is only a hint, so failures are logged
+// and otherwise ignored. The caller must hold the write lock.
+func (mf *MappedFile) readAheadWindows() {
+	depth := mf.readAheadDepth()
+	if depth == 0 {
+		return
+	}
+
+	off := mf.windowOffset + int64(len(mf.data))
+	length := min(int64(depth)*mf.windowSize, mf.size-off)
+	if length <= 0 {
+		return
+	}
+
+	if err := mf.readAhead(off, length); err != nil {
+		mf.config.warn("memmapfs: read-ahead failed", "name", mf.file.Name(), "error", err)
+	}
+}
+
+// growWindowIfThrashing doubles the window size, up to the configured
+// maximum, when the window has slid more than adaptiveSlideThreshold times
+// within adaptiveSlidePeriod. The caller must hold the write lock.
+func (mf *MappedFile) growWindowIfThrashing() {
+	now := time.Now()
+	if now.Sub(mf.burstStart) > adaptiveSlidePeriod {
+		mf.burstStart = now
+		mf.slideBurst = 0
+	}
+
+	mf.slideBurst++
+	if mf.slideBurst <= adaptiveSlideThreshold {
+		return
+	}
+
+	maxSize := mf.config.MaxWindowSize
+	if maxSize <= 0 {
+		maxSize = mf.baseWindowSize() * 16
+	}
+	if mf.windowSize*2 <= maxSize {
+		mf.windowSize *= 2
+	}
+
+	mf.burstStart = now
+	mf.slideBurst = 0
+}
+
+// baseWindowSize returns the configured window size before any adaptation.
+func (mf *MappedFile) baseWindowSize() int64 {
+	if mf.config.WindowSize > 0 {
+		return mf.config.WindowSize
+	}
+	return DefaultWindowSize
+}
+
+// shouldWindow reports whether a file of the given size is mapped in
+// windows. Windowing must be configured for it, and the file must not fit
+// in a single window: mapping such a file whole maps the same bytes while
+// sparing every Read the window's write lock.
+func (mf *MappedFile) shouldWindow(size int64) bool {
+	return mf.config.windowedFor(size) && size > mf.baseWindowSize()
+}
+
 // ensureInWindow checks if the given file offset is within the current window
 // and slides the window if necessary. The caller must hold the write lock.
 func (mf *MappedFile) ensureInWindow(fileOffset int64) error {
@@ -467,8 +1966,7 @@ func (mf *MappedFile) ensureInWindow(fileOffset int64) error {
 	}
 
 	// Check if offset is within current window
-	windowEnd := mf.windowOffset + int64(len(mf.data))
-	if fileOffset >= mf.windowOffset && fileOffset < windowEnd {
+	if mf.inWindow(fileOffset) {
 		return nil
 	}
 
@@ -476,6 +1974,16 @@ func (mf *MappedFile) ensureInWindow(fileOffset int64) error {
 	return mf.slideWindow(fileOffset)
 }
 
+// inWindow reports whether the given file offset is mapped by the current
+// window. A window that failed verification maps nothing. The caller must
+// hold at least the read lock.
+func (mf *MappedFile) inWindow(fileOffset int64) bool {
+	if mf.data == nil || mf.mismatch {
+		return false
+	}
+	return fileOffset >= mf.windowOffset && fileOffset < mf.windowOffset+int64(len(mf.data))
+}
+
 // fileOffsetToWindowOffset converts a file offset to an offset within the current window.
 func (mf *MappedFile) fileOffsetToWindowOffset(fileOffset int64) int64 {
 	if mf.windowSize == 0 {
@@ -484,3 +1992,21 @@ func (mf *MappedFile) fileOffsetToWindowOffset(fileOffset int64) int64 {
 	}
 	return fileOffset - mf.windowOffset
 }
+
+// retryMmap retries a mapping attempt that failed with err while the failure
+// is transient, sleeping with exponential backoff between attempts.
+// It returns the error from the last attempt, or nil once one succeeds.
+func (mf *MappedFile) retryMmap(attempt func() error, err error) error {
+	backoff := mf.config.MmapRetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultMmapRetryBackoff
+	}
+
+	for i := 0; i < mf.config.MmapRetries && err != nil && isTransientMmapError(err); i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = attempt()
+	}
+
+	return err
+}
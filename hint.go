@@ -0,0 +1,98 @@
+package memmapfs
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// CacheHint is a portable access-pattern hint for MappedFile.Hint. Each
+// hint maps to the closest primitive the platform offers, or does nothing
+// where there is none; hints never change what the file reads back except
+// where noted for HintDontNeed and HintFree.
+//
+//	Hint            Linux             macOS / BSD       Windows
+//	HintSequential  MADV_SEQUENTIAL   MADV_SEQUENTIAL   no-op
+//	HintRandom      MADV_RANDOM       MADV_RANDOM       no-op
+//	HintWillNeed    MADV_WILLNEED     MADV_WILLNEED     no-op
+//	HintDontNeed    MADV_DONTNEED     MADV_DONTNEED     no-op
+//	HintFree        MADV_FREE *       MADV_FREE *       no-op
+//
+// * File-backed mappings that reject MADV_FREE get MADV_DONTNEED instead.
+type CacheHint int
+
+const (
+	// HintSequential expects access in increasing order: the kernel reads
+	// ahead aggressively and may drop pages soon after they are used.
+	HintSequential CacheHint = iota
+
+	// HintRandom expects scattered access: the kernel reads ahead little
+	// or not at all.
+	HintRandom
+
+	// HintWillNeed expects the mapped region to be read soon: the kernel
+	// starts reading it in without waiting.
+	HintWillNeed
+
+	// HintDontNeed releases the mapped pages from the process. Shared
+	// mappings read the file's contents back on the next access; private
+	// (ModeCopyOnWrite) mappings lose their unsynced changes.
+	HintDontNeed
+
+	// HintFree marks the mapped pages as unneeded so the kernel may reclaim
+	// them lazily. As with HintDontNeed, private changes may be lost.
+	HintFree
+)
+
+// String returns the name of the hint.
+func (h CacheHint) String() string {
+	switch h {
+	case HintSequential:
+		return "HintSequential"
+	case HintRandom:
+		return "HintRandom"
+	case HintWillNeed:
+		return "HintWillNeed"
+	case HintDontNeed:
+		return "HintDontNeed"
+	case HintFree:
+		return "HintFree"
+	default:
+		return fmt.Sprintf("CacheHint(%d)", int(h))
+	}
+}
+
+// Hint applies a portable access-pattern hint to the current mapping (the
+// current window, if windowed). See CacheHint for what each hint does on
+// each platform. It fails with ErrNotMapped if the file is not mapped, on
+// every platform, and with errors.ErrUnsupported for an unknown hint.
+func (mf *MappedFile) Hint(h CacheHint) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	mapped := mf.mmapData != nil
+	mf.mu.RUnlock()
+
+	if !mapped {
+		return ErrNotMapped
+	}
+
+	switch h {
+	case HintSequential:
+		return mf.AdviseSequential()
+	case HintRandom:
+		return mf.AdviseRandom()
+	case HintWillNeed:
+		return mf.AdviseWillNeed()
+	case HintDontNeed:
+		return mf.AdviseDontNeed()
+	case HintFree:
+		// MADV_FREE only applies to private anonymous memory on Linux
+		if err := mf.AdviseFree(); !errors.Is(err, syscall.EINVAL) {
+			return err
+		}
+		return mf.AdviseDontNeed()
+	default:
+		return fmt.Errorf("%v: %w", h, errors.ErrUnsupported)
+	}
+}
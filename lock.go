@@ -0,0 +1,48 @@
+package memmapfs
+
+// Lock acquires an exclusive lock shared by every process using the shared
+// memory file, blocking until it is available. It is an advisory lock on
+// the whole file (flock on Unix, LockFileEx on Windows): it only excludes
+// other callers of Lock, including other segments of the same file, and it
+// is released automatically if the holding process exits. Within a process
+// Lock also excludes other goroutines.
+func (sm *SharedMemory) Lock() error {
+	sm.lockMu.Lock()
+
+	if err := sm.lockFile(); err != nil {
+		sm.lockMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Unlock releases the lock acquired by Lock.
+func (sm *SharedMemory) Unlock() error {
+	defer sm.lockMu.Unlock()
+
+	return sm.unlockFile()
+}
+
+// WithLock runs fn with the shared memory region while holding Lock, and
+// releases the lock afterwards even if fn panics. It returns fn's error, or
+// the error from acquiring or releasing the lock.
+func (sm *SharedMemory) WithLock(fn func(data []byte) error) (err error) {
+	if err := sm.Lock(); err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := sm.Unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	return fn(sm.data)
+}
+
+// lockTarget returns the file whose descriptor carries the lock.
+func (sm *SharedMemory) lockTarget() interface{} {
+	if mf := sm.MappedFile(); mf != nil {
+		return mf.file
+	}
+	return sm.file
+}
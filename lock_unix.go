@@ -0,0 +1,41 @@
+//go:build !windows
+
+package memmapfs
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive flock on the shared memory file.
+func (sm *SharedMemory) lockFile() error {
+	fd, err := getFD(sm.lockTarget())
+	if err != nil {
+		return fmt.Errorf("failed to get file descriptor: %w", err)
+	}
+
+	for {
+		err = unix.Flock(int(fd), unix.LOCK_EX)
+		if err != unix.EINTR {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("flock failed: %w", err)
+	}
+	return nil
+}
+
+// unlockFile releases the flock taken by lockFile.
+func (sm *SharedMemory) unlockFile() error {
+	fd, err := getFD(sm.lockTarget())
+	if err != nil {
+		return fmt.Errorf("failed to get file descriptor: %w", err)
+	}
+
+	if err := unix.Flock(int(fd), unix.LOCK_UN); err != nil {
+		return fmt.Errorf("flock failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build windows
+
+package memmapfs
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockOffset is the byte locked by Lock. Windows byte-range locks are
+// mandatory for ReadFile and WriteFile, so the lock sits far past any data
+// and never blocks plain I/O on the file.
+const lockOffset = 1<<63 - 1
+
+// lockFile takes an exclusive LockFileEx lock on the shared memory file.
+func (sm *SharedMemory) lockFile() error {
+	handle, err := getHandle(sm.lockTarget())
+	if err != nil {
+		return fmt.Errorf("failed to get file handle: %w", err)
+	}
+
+	ol := lockOverlapped()
+	if err := windows.LockFileEx(windows.Handle(handle), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &ol); err != nil {
+		return fmt.Errorf("LockFileEx failed: %w", err)
+	}
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile.
+func (sm *SharedMemory) unlockFile() error {
+	handle, err := getHandle(sm.lockTarget())
+	if err != nil {
+		return fmt.Errorf("failed to get file handle: %w", err)
+	}
+
+	ol := lockOverlapped()
+	if err := windows.UnlockFileEx(windows.Handle(handle), 0, 1, 0, &ol); err != nil {
+		return fmt.Errorf("UnlockFileEx failed: %w", err)
+	}
+	return nil
+}
+
+// lockOverlapped returns an Overlapped addressing lockOffset.
+func lockOverlapped() windows.Overlapped {
+	return windows.Overlapped{
+		Offset:     uint32(lockOffset & 0xFFFFFFFF),
+		OffsetHigh: uint32(lockOffset >> 32),
+	}
+}
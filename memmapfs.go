@@ -3,8 +3,13 @@ package memmapfs
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/absfs/absfs"
@@ -18,10 +23,39 @@ const (
 	ModeReadOnly MappingMode = iota
 	// ModeReadWrite maps files as read-write (PROT_READ|PROT_WRITE, MAP_SHARED)
 	ModeReadWrite
-	// ModeCopyOnWrite maps files as copy-on-write (PROT_READ|PROT_WRITE, MAP_PRIVATE)
+	// ModeCopyOnWrite maps files as copy-on-write (PROT_READ|PROT_WRITE, MAP_PRIVATE).
+	// Private changes live only in the current mapping, so a windowed mapping
+	// refuses to slide away from a modified window (ErrCopyOnWriteSlide)
+	// rather than silently discarding them; use MapFullFile to edit freely.
 	ModeCopyOnWrite
+	// ModeReadExec maps files as readable and executable (PROT_READ|PROT_EXEC, MAP_SHARED)
+	// for loaders that execute code directly from the mapping.
+	ModeReadExec
+	// ModeReadWriteExec maps files as writable and executable (PROT_READ|PROT_WRITE|PROT_EXEC, MAP_SHARED).
+	// This violates W^X: hardened kernels (SELinux, PaX, OpenBSD) and macOS
+	// reject such mappings, and it widens the attack surface of any memory bug.
+	// Prefer writing with ModeReadWrite and remapping with ModeReadExec.
+	ModeReadWriteExec
 )
 
+// isWritable reports whether mappings in this mode accept writes.
+func (m MappingMode) isWritable() bool {
+	return m == ModeReadWrite || m == ModeCopyOnWrite || m == ModeReadWriteExec
+}
+
+// Logger receives warnings about internal fallbacks. Args are alternating
+// keys and values, as with log/slog.
+type Logger interface {
+	Warn(msg string, args ...interface{})
+}
+
+// warn reports a warning to the configured Logger, if any.
+func (c *Config) warn(msg string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Warn(msg, args...)
+	}
+}
+
 // SyncMode defines how modified pages are synchronized to disk.
 type SyncMode int
 
@@ -41,35 +75,253 @@ type Config struct {
 	// Mode specifies the mapping mode (read-only, read-write, copy-on-write)
 	Mode MappingMode
 
+	// PrivateReadOnly maps ModeReadOnly files with MAP_PRIVATE instead of
+	// MAP_SHARED, so the mapping can never write back to the file. Note that
+	// this is not a snapshot: whether external writes to pages that have not
+	// been privately copied are visible is unspecified by POSIX, and on Linux
+	// they are. Ignored on Windows.
+	PrivateReadOnly bool
+
 	// SyncMode specifies when to sync dirty pages to disk
 	SyncMode SyncMode
 
 	// SyncInterval is the interval for periodic sync (only used with SyncPeriodic)
 	SyncInterval time.Duration
 
+	// SyncWorkers is the number of files the periodic sync manager syncs
+	// concurrently on each tick, so that a file on a slow volume does not
+	// hold back the others. If 0 or 1, files are synced one at a time.
+	SyncWorkers int
+
+	// NoSyncOnClose makes Close skip syncing a modified file, for scratch
+	// files whose contents are not wanted. Shared mappings still leave their
+	// dirty pages to the OS, which writes them back when it sees fit, as
+	// with SyncNever.
+	NoSyncOnClose bool
+
+	// SyncDirtyThreshold, if positive, asks the periodic sync manager to flush
+	// a file early once more than this many bytes have been written to it
+	// since its last sync, bounding data at risk for bursty writers. Only
+	// used with SyncPeriodic.
+	SyncDirtyThreshold int64
+
+	// StatCacheInterval, if positive, is the minimum interval between stats
+	// of the underlying file for size checks (SIGBUS truncation detection,
+	// WatchSize). Within it the cached FileInfo is reused, trading slightly
+	// stale size information for fewer syscalls. See MappedFile.StatCached.
+	StatCacheInterval time.Duration
+
+	// OnSyncError, if set, is called when a periodic background sync fails.
+	// Without it such failures are reported to Logger, if any.
+	OnSyncError func(mf *MappedFile, err error)
+
+	// Logger, if set, receives warnings about fallbacks that otherwise
+	// happen silently, such as huge pages being unavailable or a failed
+	// preload. A *slog.Logger satisfies it.
+	Logger Logger
+
 	// MapFullFile determines whether to map the entire file at once
 	// If false, WindowSize is used for windowed mapping
 	MapFullFile bool
 
+	// MaxFileSize, if positive, makes OpenFile fail with ErrFileTooLarge for
+	// files larger than this when MapFullFile is set, guarding against
+	// mapping a huge or bogus size reported by an untrusted filesystem.
+	// Windowed mappings are not limited.
+	MaxFileSize int64
+
 	// WindowSize specifies the size of the mapping window for large files
-	// Only used when MapFullFile is false. If 0, defaults to 1GB.
+	// Only used when MapFullFile is false or the file exceeds WindowAbove.
+	// Files no larger than one window are mapped whole instead, and switch
+	// to windows if they later grow past it. If 0, defaults to 1GB.
 	WindowSize int64
 
+	// WindowAbove, if positive, maps files larger than this in windows of
+	// WindowSize even when MapFullFile is set, so small files get the speed
+	// of a full mapping without large ones exhausting address space.
+	WindowAbove int64
+
+	// Hints lists CacheHints applied to each file when it is first mapped
+	// (the first window, if windowed), e.g. HintSequential for files that
+	// are streamed out whole. Failures are logged and otherwise ignored.
+	Hints []CacheHint
+
+	// MapReadsOnly makes OpenFile return the underlying file unmapped for
+	// opens that can write (O_WRONLY, O_RDWR, O_APPEND, O_TRUNC), so only
+	// reads go through mappings and writes keep ordinary file semantics.
+	MapReadsOnly bool
+
+	// AdaptiveWindow lets a windowed mapping double its window size when it
+	// slides too often (thrashing), up to MaxWindowSize, and halve it again
+	// if remapping a grown window fails under memory pressure.
+	AdaptiveWindow bool
+
+	// MaxWindowSize caps the window size reached by AdaptiveWindow.
+	// If 0, defaults to 16 times WindowSize.
+	MaxWindowSize int64
+
+	// ReadAheadWindows asks the kernel to start reading the next N windows
+	// of the file whenever the window slides forward to the adjacent one,
+	// hiding disk latency for sequential streaming. Only windowed mappings
+	// of local files use it, and only Linux implements it (with
+	// POSIX_FADV_WILLNEED); elsewhere it is ignored. Stats reports the
+	// effective depth.
+	ReadAheadWindows int
+
+	// VerifyChecksums records a CRC32 of each window of a windowed mapping
+	// as it slides away, after syncing its writes, and checks it when the
+	// window is mapped again. A mismatch fails the access that slid the
+	// window with ErrChecksumMismatch, revealing another writer touching
+	// the file behind the mapping's back. Remapping or resizing the file
+	// discards the recorded checksums. Hashing each window costs a full
+	// read of it per slide, so this is meant for development and testing.
+	VerifyChecksums bool
+
+	// OnWindowSlide, if set, is called after a windowed mapping successfully
+	// remaps to a new window. It runs with the file's lock held and must not
+	// call back into the MappedFile.
+	OnWindowSlide func(oldOffset, newOffset, windowSize int64)
+
+	// Decompressor, if set, presents the decompressed contents of each file
+	// opened read-only, e.g. for gzip-compressed data files. Decompression
+	// is lazy and happens in memory; only ModeReadOnly is supported.
+	Decompressor Decompressor
+
+	// EOFOnLastRead makes Read return io.EOF together with the final bytes
+	// of the file, saving callers an extra zero-byte Read at the end.
+	EOFOnLastRead bool
+
+	// TruncationIsEOF caps Read, ReadAt, ReadAtv, ReadNext and ReadAll at
+	// the file's current size, so a consumer of a file that another process
+	// truncates sees io.EOF past the new end instead of SIGBUS. The size is
+	// checked with StatCached, so a shrink within StatCacheInterval of the
+	// last stat can still fault. The mapping itself keeps its size; use
+	// RemapAfterTruncation or Remap to release it.
+	TruncationIsEOF bool
+
 	// Preload hints that pages should be loaded immediately
 	Preload bool
 
 	// PreloadAsync performs preload asynchronously
 	PreloadAsync bool
 
+	// PreloadSync makes opening a file block until the initial mapping (the
+	// first window, if windowed) is resident, touching every page and, on
+	// Linux, checking with mincore until none are missing. Open fails with
+	// ErrPreloadTimeout if that takes longer than PreloadTimeout, when set.
+	// Elsewhere the pages are touched once without verification.
+	PreloadSync bool
+
+	// PreloadTimeout bounds the wait for PreloadSync. Zero waits forever.
+	PreloadTimeout time.Duration
+
 	// PopulatePages uses MAP_POPULATE to eagerly load pages (Linux-specific)
 	// This prefaults page tables, loading file contents into RAM immediately
 	// More aggressive than Preload which uses madvise hints
+	// On Linux 5.14+ MADV_POPULATE_READ is used instead of MAP_POPULATE
 	PopulatePages bool
 
 	// UseHugePages attempts to use huge pages (MAP_HUGETLB on Linux)
 	// Requires system configuration and may fail if huge pages unavailable
 	// Can significantly improve TLB performance for large files
 	UseHugePages bool
+
+	// HugePageSizeLog2 selects the huge page size used with UseHugePages as a
+	// power of two (e.g. 21 for 2MB, 30 for 1GB), encoded via MAP_HUGE_SHIFT
+	// on Linux. If 0 or unavailable, the system default huge page size is used.
+	HugePageSizeLog2 int
+
+	// ExtraMmapFlags are OR'd into the flags passed to mmap(2), for flags this
+	// package has no option for. Ignored on Windows.
+	ExtraMmapFlags int
+
+	// ValidateFlags maps shared modes with MAP_SHARED_VALIDATE instead of
+	// MAP_SHARED, so that the kernel rejects unknown flags (including any in
+	// ExtraMmapFlags) with EOPNOTSUPP instead of silently ignoring them.
+	// Linux 4.15+ only; older kernels fail every mapping. Ignored elsewhere.
+	ValidateFlags bool
+
+	// Preallocate reserves disk blocks for writable shared mappings before
+	// mapping (fallocate on Linux, F_PREALLOCATE on macOS, writing zeros on
+	// the BSDs and Windows), so that writes through the mapping cannot fail
+	// with ENOSPC on a sparse hole. Linux reserves the whole file; elsewhere
+	// only the region the file grows by is reserved and existing holes stay.
+	// Only applied when the file is opened O_RDWR.
+	Preallocate bool
+
+	// PreallocateSize is the size to preallocate when Preallocate is set.
+	// Files smaller than this are grown to it; if 0, the current size is used.
+	PreallocateSize int64
+
+	// GrowOnWriteAt lets Write, WriteAt and WriteAtv past the end of a shared writable
+	// mapping extend the file (sparsely, via truncate) and remap it instead
+	// of failing. Combine with ExtraMmapFlags: MAP_NORESERVE on Linux for large
+	// sparse files. Slices returned by Data are invalid after growth.
+	GrowOnWriteAt bool
+
+	// ZeroFillGrowth makes growth (Truncate, GrowOnWriteAt) write out zeros
+	// for the new tail instead of leaving a sparse hole, so the space is
+	// allocated up front. Reads see zeros either way.
+	ZeroFillGrowth bool
+
+	// DurableCreate fsyncs the containing directory after creating a file
+	// (OpenFile with O_CREATE, Create) or renaming one, so that the new name
+	// survives a crash and not just the contents. See MemMapFS.SyncDir.
+	DurableCreate bool
+
+	// OpenSync opens underlying files with O_SYNC (FILE_FLAG_WRITE_THROUGH
+	// on Windows), as if every OpenFile call passed it. See OpenFile for how
+	// O_SYNC applies to mapped writes. Every write then waits for the disk,
+	// which is typically orders of magnitude slower than SyncLazy or
+	// SyncPeriodic; use it only where each write must be durable on return.
+	OpenSync bool
+
+	// NoAtime opens underlying files with O_NOATIME so that reading them,
+	// including through the mapping, does not update their access time
+	// (Linux only; ignored elsewhere). The kernel only permits this for the
+	// file's owner, so on EPERM the file is opened normally instead.
+	NoAtime bool
+
+	// DontFork excludes the mapping from child processes created by fork
+	// (MADV_DONTFORK on Linux). Ignored on other platforms.
+	DontFork bool
+
+	// NoCache sets F_NOCACHE on the descriptor so that the file's pages do
+	// not pollute the unified buffer cache (macOS only; ignored elsewhere).
+	// See MappedFile.SetNoCache.
+	NoCache bool
+
+	// DegradeOnMapFailure makes OpenFile return the plain underlying file
+	// instead of an error when the file cannot be mapped (permissions,
+	// unsupported filesystem, ENOMEM, ...). I/O keeps working, just without
+	// the benefits of mapping; callers can detect this with a type assertion.
+	DegradeOnMapFailure bool
+
+	// DowngradeOnReadOnlyFS retries an open for writing that fails with
+	// EROFS (a read-only mount such as squashfs or a CD-ROM image) as
+	// O_RDONLY, mapping the file with ModeReadOnly instead of a writable
+	// mode. Without it such opens fail with ErrReadOnlyFS.
+	DowngradeOnReadOnlyFS bool
+
+	// MmapRetries is the number of times a mapping attempt that failed with a
+	// transient out-of-memory error is retried before giving up. 0 disables retries.
+	MmapRetries int
+
+	// MmapRetryBackoff is the delay before the first retry; it doubles on each
+	// subsequent attempt. If 0, defaults to DefaultMmapRetryBackoff.
+	MmapRetryBackoff time.Duration
+
+	// CloseFdAfterMap closes the underlying file as soon as it is mapped,
+	// relying on the mapping to keep the data alive. This saves descriptors
+	// when many small files are mapped at once. Stat and Name still work, but
+	// Remap does not. Requires a read-only mode with MapFullFile, since
+	// windowed mappings need the descriptor to slide.
+	CloseFdAfterMap bool
+}
+
+// windowedFor reports whether a file of the given size is mapped in windows.
+func (c *Config) windowedFor(size int64) bool {
+	return !c.MapFullFile || (c.WindowAbove > 0 && size > c.WindowAbove)
 }
 
 // DefaultConfig returns a configuration suitable for most use cases.
@@ -83,11 +335,70 @@ func DefaultConfig() *Config {
 	}
 }
 
+// ReadOptimizedConfig returns a configuration for read-mostly workloads
+// such as serving files: reads are mapped read-only, as a whole up to 1GB
+// and in 1GB windows beyond, with a sequential access hint, while writes
+// go to the underlying files unmapped. Files that cannot be mapped are
+// read without a mapping.
+func ReadOptimizedConfig() *Config {
+	return &Config{
+		Mode:                ModeReadOnly,
+		SyncMode:            SyncNever,
+		MapFullFile:         true,
+		WindowAbove:         DefaultWindowSize,
+		WindowSize:          DefaultWindowSize,
+		Hints:               []CacheHint{HintSequential},
+		MapReadsOnly:        true,
+		DegradeOnMapFailure: true,
+	}
+}
+
 // MemMapFS wraps an existing filesystem and provides memory-mapped file access.
 type MemMapFS struct {
 	underlying  absfs.FileSystem
 	config      *Config
 	syncManager *syncManager
+	open        *openFiles // MappedFiles not yet closed, for CloseAll
+}
+
+// openFiles is the set of MappedFiles a MemMapFS has handed out and that
+// have not been closed yet.
+type openFiles struct {
+	mu    sync.Mutex
+	files map[*MappedFile]struct{}
+}
+
+// add tracks mf until it is closed.
+func (of *openFiles) add(mf *MappedFile) {
+	of.mu.Lock()
+	of.files[mf] = struct{}{}
+	of.mu.Unlock()
+	mf.open = of
+}
+
+// remove stops tracking mf.
+func (of *openFiles) remove(mf *MappedFile) {
+	of.mu.Lock()
+	delete(of.files, mf)
+	of.mu.Unlock()
+}
+
+// list returns the tracked files.
+func (of *openFiles) list() []*MappedFile {
+	of.mu.Lock()
+	defer of.mu.Unlock()
+
+	files := make([]*MappedFile, 0, len(of.files))
+	for mf := range of.files {
+		files = append(files, mf)
+	}
+	return files
+}
+
+// NewReadOptimized wraps underlying with ReadOptimizedConfig, a safe and
+// fast default for read-mostly use.
+func NewReadOptimized(underlying absfs.FileSystem) *MemMapFS {
+	return New(underlying, ReadOptimizedConfig())
 }
 
 // New creates a new memory-mapped filesystem wrapper.
@@ -100,11 +411,12 @@ func New(underlying absfs.FileSystem, config *Config) *MemMapFS {
 	mfs := &MemMapFS{
 		underlying: underlying,
 		config:     config,
+		open:       &openFiles{files: make(map[*MappedFile]struct{})},
 	}
 
 	// Initialize periodic sync manager if needed
 	if config.SyncMode == SyncPeriodic && config.SyncInterval > 0 {
-		mfs.syncManager = newSyncManager(config.SyncInterval)
+		mfs.syncManager = newSyncManager(config.SyncInterval, config.SyncWorkers)
 	}
 
 	return mfs
@@ -118,13 +430,53 @@ func (mfs *MemMapFS) Open(name string) (absfs.File, error) {
 
 // OpenFile opens a file with specified flags and permissions.
 // For Phase 1, only read-only mode is fully supported.
+// If Config.Decompressor is set, the returned file is a DecompressingMappedFile.
+// Directories, empty files, FIFOs and sockets cannot be mapped and are
+// returned as the underlying file.
+//
+// O_SYNC and O_DSYNC (or Config.OpenSync) are passed to the underlying
+// file, which only makes write(2) on its descriptor synchronous: stores
+// into a mapping never go through the descriptor. So a file opened with
+// either is also mapped with SyncImmediate, whatever Config.SyncMode says,
+// and each Write or WriteAt returns only once msync(MS_SYNC) (or
+// FlushViewOfFile and FlushFileBuffers) has put it on disk.
 func (mfs *MemMapFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	if mfs.config.CloseFdAfterMap && (!mfs.config.MapFullFile || mfs.config.Mode.isWritable()) {
+		return nil, ErrCloseFdAfterMap
+	}
+
+	if mfs.config.Decompressor == nil {
+		return mfs.openFile(name, flag, perm)
+	}
+
+	if mfs.config.Mode != ModeReadOnly {
+		return nil, ErrDecompressorMode
+	}
+
+	file, err := mfs.openFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecompressingFile(file, mfs.config.Decompressor)
+}
+
+// openFile opens and, where possible, maps a file.
+func (mfs *MemMapFS) openFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
 	// Open the underlying file
-	file, err := mfs.underlying.OpenFile(name, flag, perm)
+	file, err := mfs.openUnderlying(name, flag, perm)
+	if errors.Is(err, syscall.EROFS) {
+		return mfs.openReadOnlyFS(name, flag, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// Writers get the plain file
+	if mfs.config.MapReadsOnly && flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return file, nil
+	}
+
 	// Get file info to determine size
 	fi, err := file.Stat()
 	if err != nil {
@@ -137,25 +489,203 @@ func (mfs *MemMapFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.Fi
 		return file, nil
 	}
 
+	// FIFOs and sockets are streams with no pages to map; reads and writes
+	// go straight to the underlying file
+	if fi.Mode()&(os.ModeNamedPipe|os.ModeSocket) != 0 {
+		return file, nil
+	}
+
 	size := fi.Size()
+
+	// Don't trust an absurd reported size with a full-file mapping
+	if err := mfs.checkFileSize(name, size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Reserve disk blocks up front so writes through the mapping cannot hit ENOSPC
+	shared := mfs.config.Mode == ModeReadWrite || mfs.config.Mode == ModeReadWriteExec
+	if mfs.config.Preallocate && shared && flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_RDWR {
+		if mfs.config.PreallocateSize > size {
+			size = mfs.config.PreallocateSize
+		}
+		if size > 0 {
+			if err := preallocate(file, size); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+
 	if size == 0 {
 		return file, nil
 	}
 
+	// Every mapping needs read access to the descriptor, even for writes,
+	// so a write-only descriptor would fail mmap with an opaque EACCES.
+	if flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		if mfs.config.DegradeOnMapFailure {
+			mfs.config.warn("memmapfs: write-only file not mapped", "name", name)
+			return file, nil
+		}
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", name, ErrWriteOnlyMapping)
+	}
+
+	// Likewise a shared writable mapping needs write access, or mmap fails
+	// with EACCES. Copy-on-write mappings never write back, so they don't.
+	if shared && flag&(os.O_RDONLY|os.O_WRONLY|os.O_RDWR) == os.O_RDONLY {
+		if mfs.config.DegradeOnMapFailure {
+			mfs.config.warn("memmapfs: read-only file not mapped writable", "name", name)
+			return file, nil
+		}
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", name, ErrReadOnlyDescriptor)
+	}
+
+	// Mapped writes bypass the descriptor, so O_SYNC is honored by syncing
+	// each of them
+	config := mfs.config
+	if openedSync(flag|mfs.syncFlag()) && config.Mode.isWritable() && config.SyncMode != SyncImmediate {
+		syncConfig := *config
+		syncConfig.SyncMode = SyncImmediate
+		config = &syncConfig
+	}
+
 	// Create mapped file
-	mf, err := newMappedFile(file, mfs.config, size, mfs.syncManager)
+	mf, err := newMappedFile(file, config, size, mfs.syncManager)
 	if err != nil {
+		if mfs.config.DegradeOnMapFailure {
+			mfs.config.warn("memmapfs: mapping failed, using unmapped I/O", "name", name, "error", err)
+			return file, nil
+		}
 		file.Close()
 		return nil, err
 	}
 
+	// Reopen opens the path the same way, without creating or truncating
+	reopenFlag := flag &^ (os.O_CREATE | os.O_EXCL | os.O_TRUNC)
+	mf.reopen = func() (absfs.File, error) {
+		return mfs.openUnderlyingFlags(name, reopenFlag, perm)
+	}
+	mfs.open.add(mf)
+
 	return mf, nil
 }
 
+// openReadOnlyFS handles an open for writing that failed with EROFS:
+// with Config.DowngradeOnReadOnlyFS it opens and maps the file read-only,
+// otherwise it explains the failure with ErrReadOnlyFS.
+func (mfs *MemMapFS) openReadOnlyFS(name string, flag int, openErr error) (absfs.File, error) {
+	if !mfs.config.DowngradeOnReadOnlyFS || flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return nil, fmt.Errorf("%s: %w: %w", name, ErrReadOnlyFS, openErr)
+	}
+
+	config := *mfs.config
+	if config.Mode == ModeReadWrite || config.Mode == ModeReadWriteExec {
+		config.Mode = ModeReadOnly
+	}
+	config.warn("memmapfs: read-only file system, opening read-only", "name", name)
+
+	ro := &MemMapFS{underlying: mfs.underlying, config: &config, syncManager: mfs.syncManager, open: mfs.open}
+	return ro.openFile(name, os.O_RDONLY, 0)
+}
+
+// checkFileSize enforces Config.MaxFileSize for full-file mappings.
+// Windowed mappings only ever map WindowSize bytes and are not limited.
+func (mfs *MemMapFS) checkFileSize(name string, size int64) error {
+	if limit := mfs.config.MaxFileSize; limit > 0 && size > limit && !mfs.config.windowedFor(size) {
+		return fmt.Errorf("%s: size %d exceeds MaxFileSize %d: %w", name, size, limit, ErrFileTooLarge)
+	}
+	return nil
+}
+
+// openUnderlying opens name on the underlying filesystem, adding O_NOATIME
+// when Config.NoAtime is set and the platform and permissions allow it.
+// With Config.DurableCreate, a file it creates is made durable with SyncDir.
+func (mfs *MemMapFS) openUnderlying(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	if mfs.config.DurableCreate && flag&os.O_CREATE != 0 {
+		_, statErr := mfs.underlying.Stat(name)
+
+		file, err := mfs.openUnderlyingFlags(name, flag, perm)
+		if err != nil || !errors.Is(statErr, os.ErrNotExist) {
+			return file, err
+		}
+
+		if err := mfs.SyncDir(name); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return file, nil
+	}
+
+	return mfs.openUnderlyingFlags(name, flag, perm)
+}
+
+// openUnderlyingFlags implements openUnderlying without DurableCreate.
+func (mfs *MemMapFS) openUnderlyingFlags(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	flag |= mfs.syncFlag()
+
+	if mfs.config.NoAtime && oNoAtime != 0 {
+		file, err := mfs.underlying.OpenFile(name, flag|oNoAtime, perm)
+		if !errors.Is(err, os.ErrPermission) {
+			return file, err
+		}
+		// Only the owner (or CAP_FOWNER) may use O_NOATIME
+		mfs.config.warn("memmapfs: O_NOATIME not permitted, opening normally", "name", name)
+	}
+
+	return mfs.underlying.OpenFile(name, flag, perm)
+}
+
+// syncFlag returns the open flag added for Config.OpenSync, or 0.
+func (mfs *MemMapFS) syncFlag() int {
+	if mfs.config.OpenSync {
+		return os.O_SYNC
+	}
+	return 0
+}
+
+// openedSync reports whether flag asks for synchronized writes with O_SYNC
+// or O_DSYNC.
+func openedSync(flag int) bool {
+	return flag&os.O_SYNC == os.O_SYNC || (oDSync != 0 && flag&oDSync == oDSync)
+}
+
 // Create creates a new file.
 // For Phase 1, this delegates to the underlying filesystem.
 func (mfs *MemMapFS) Create(name string) (absfs.File, error) {
-	return mfs.underlying.Create(name)
+	file, err := mfs.underlying.Create(name)
+	if err != nil || !mfs.config.DurableCreate {
+		return file, err
+	}
+
+	if err := mfs.SyncDir(name); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// SyncDir fsyncs the directory containing name, making a preceding create
+// or rename of name durable across a crash; syncing the file itself does
+// not persist its directory entry. It is a no-op on Windows, where
+// directories cannot be synced and NTFS journals metadata itself.
+func (mfs *MemMapFS) SyncDir(name string) error {
+	if !syncDirSupported {
+		return nil
+	}
+
+	dir, err := mfs.underlying.Open(filepath.Dir(name))
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
 }
 
 // Mkdir creates a directory.
@@ -179,8 +709,19 @@ func (mfs *MemMapFS) RemoveAll(name string) error {
 }
 
 // Rename renames a file or directory.
+// With Config.DurableCreate the directories of both names are synced.
 func (mfs *MemMapFS) Rename(oldname, newname string) error {
-	return mfs.underlying.Rename(oldname, newname)
+	if err := mfs.underlying.Rename(oldname, newname); err != nil || !mfs.config.DurableCreate {
+		return err
+	}
+
+	if err := mfs.SyncDir(newname); err != nil {
+		return err
+	}
+	if filepath.Dir(oldname) != filepath.Dir(newname) {
+		return mfs.SyncDir(oldname)
+	}
+	return nil
 }
 
 // Stat returns file info.
@@ -198,6 +739,17 @@ func (mfs *MemMapFS) Chown(name string, uid, gid int) error {
 	return mfs.underlying.Chown(name, uid, gid)
 }
 
+// Lchown changes the ownership of a symlink itself, if the underlying
+// filesystem supports it.
+func (mfs *MemMapFS) Lchown(name string, uid, gid int) error {
+	if lfs, ok := mfs.underlying.(interface {
+		Lchown(name string, uid, gid int) error
+	}); ok {
+		return lfs.Lchown(name, uid, gid)
+	}
+	return &os.PathError{Op: "lchown", Path: name, Err: errors.ErrUnsupported}
+}
+
 // Chtimes changes file access and modification times.
 func (mfs *MemMapFS) Chtimes(name string, atime, mtime time.Time) error {
 	return mfs.underlying.Chtimes(name, atime, mtime)
@@ -233,11 +785,143 @@ func (mfs *MemMapFS) ReadFile(name string) ([]byte, error) {
 	return mfs.underlying.ReadFile(name)
 }
 
+// ReadFileMapped maps the named file read-only and returns its contents as
+// a zero-copy slice of the mapping, together with a Closer that unmaps it.
+// The slice must not be used or modified after Close. The whole file is
+// mapped regardless of the windowing configuration.
+//
+// Empty files, and files that could not be mapped when DegradeOnMapFailure
+// is set, are read into ordinary memory instead. A zero-copy slice can only
+// hold the bytes on disk, so with a Decompressor configured ReadFileMapped
+// fails with ErrCompressedMapping.
+func (mfs *MemMapFS) ReadFileMapped(name string) ([]byte, io.Closer, error) {
+	if mfs.config.Decompressor != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, ErrCompressedMapping)
+	}
+
+	config := *mfs.config
+	config.Mode = ModeReadOnly
+	config.MapFullFile = true
+	config.WindowAbove = 0
+	config.MapReadsOnly = false
+
+	ro := &MemMapFS{underlying: mfs.underlying, config: &config, open: mfs.open}
+	file, err := ro.openFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mf, ok := file.(*MappedFile); ok {
+		return mf.Data(), mf, nil
+	}
+
+	fi, err := file.Stat()
+	if err == nil && fi.IsDir() {
+		err = fmt.Errorf("%s is a directory", name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return data, file, nil
+}
+
+// OpenReadSeeker opens the named file with a read-only mapping and returns
+// it as an io.ReadSeeker that has no other methods, so it can be handed to
+// code that must not write to it, together with a Closer that unmaps it.
+// The usual windowing and decompression settings apply: with a
+// Decompressor, reads see the decompressed contents.
+func (mfs *MemMapFS) OpenReadSeeker(name string) (io.ReadSeeker, io.Closer, error) {
+	config := *mfs.config
+	config.Mode = ModeReadOnly
+
+	ro := &MemMapFS{underlying: mfs.underlying, config: &config, open: mfs.open}
+	file, err := ro.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := file.Stat()
+	if err == nil && fi.IsDir() {
+		err = fmt.Errorf("%s is a directory", name)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return readSeeker{file}, file, nil
+}
+
+// readSeeker hides every method of the wrapped file but Read and Seek.
+type readSeeker struct {
+	rs io.ReadSeeker
+}
+
+func (r readSeeker) Read(p []byte) (int, error) {
+	return r.rs.Read(p)
+}
+
+func (r readSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.rs.Seek(offset, whence)
+}
+
 // Sub returns a Filer corresponding to the subtree rooted at dir.
 func (mfs *MemMapFS) Sub(dir string) (fs.FS, error) {
 	return absfs.FilerToFS(mfs.underlying, dir)
 }
 
+// CloseAll syncs and closes every MappedFile opened through mfs that has
+// not been closed yet, then stops periodic syncing. It is meant for
+// shutdown: errors from the individual files are collected and returned
+// together, so one failure does not leave the remaining files open.
+// Files that were returned unmapped (directories, empty files) are not
+// tracked and must be closed by the caller.
+func (mfs *MemMapFS) CloseAll() error {
+	var errs []error
+	for _, mf := range mfs.open.list() {
+		name := mf.Name()
+		if err := mf.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	if mfs.syncManager != nil {
+		mfs.syncManager.stop()
+	}
+
+	return errors.Join(errs...)
+}
+
+// SyncStatus reports the last successful sync time and pending dirty bytes
+// of every file tracked by the periodic sync manager, sorted by name.
+// It returns nil unless the filesystem uses SyncPeriodic.
+func (mfs *MemMapFS) SyncStatus() []SyncStatus {
+	if mfs.syncManager == nil {
+		return nil
+	}
+	return mfs.syncManager.status()
+}
+
+// PageSize returns the system memory page size in bytes.
+func (mfs *MemMapFS) PageSize() int {
+	return os.Getpagesize()
+}
+
+// AllocationGranularity returns the alignment in bytes required for mapping
+// offsets. It equals the page size on Unix and is 64KB on Windows. Choosing
+// a WindowSize that is a multiple of it avoids partial-page remaps.
+func (mfs *MemMapFS) AllocationGranularity() int {
+	return allocationGranularity()
+}
+
 // Ensure MemMapFS implements absfs.FileSystem
 var _ absfs.FileSystem = (*MemMapFS)(nil)
 
@@ -248,4 +932,28 @@ var (
 	ErrInvalidWhence      = errors.New("invalid whence")
 	ErrWriteToReadOnlyMap = errors.New("cannot write to read-only mapping")
 	ErrSIGBUS             = errors.New("SIGBUS signal received: possible file truncation or I/O error")
+	ErrWriteOnlyMapping   = errors.New("cannot map file opened O_WRONLY: mappings require read access, use O_RDWR")
+	ErrReadOnlyDescriptor = errors.New("cannot map file opened O_RDONLY with ModeReadWrite: shared writable mappings require O_RDWR")
+	ErrBackingRemoved     = errors.New("backing file was removed while mapped")
+	ErrUnaligned          = errors.New("offset is not aligned for atomic access")
+	ErrDecompressorMode   = errors.New("decompression requires ModeReadOnly")
+	ErrCompressedMapping  = errors.New("zero-copy access returns the on-disk bytes, not decompressed data")
+	ErrCloseFdAfterMap    = errors.New("CloseFdAfterMap requires a read-only full-file mapping")
+	ErrRemoteMode         = errors.New("remote files require ModeReadOnly")
+	ErrPartialRecord      = errors.New("file size is not a multiple of the record size")
+	ErrWaitTimeout        = errors.New("timed out waiting for shared memory event")
+	ErrCopyOnWriteSlide   = errors.New("cannot slide a modified copy-on-write window: private changes would be lost")
+	ErrCopyOnWriteDrain   = errors.New("cannot drain a modified copy-on-write mapping: private changes would be lost")
+	ErrPartialSync        = errors.New("sync only partially reached disk")
+	ErrFileTooLarge       = errors.New("file is too large to map in full")
+	ErrPinned             = errors.New("mapping is pinned and cannot be moved or unmapped")
+	ErrHeaderMismatch     = errors.New("shared memory header does not match")
+	ErrSharedMemoryFull   = errors.New("shared memory region is full")
+	ErrPreloadTimeout     = errors.New("mapping did not become resident before the preload timeout")
+	ErrCrossesWindow      = errors.New("range crosses the end of the current window")
+	ErrChecksumMismatch   = errors.New("window contents changed since it was last mapped")
+	ErrShortMapping       = errors.New("mapping is shorter than the file")
+	ErrWindowedMapping    = errors.New("operation requires a full-file mapping, not a window")
+	ErrReadOnlyFS         = errors.New("file system is read-only: open with O_RDONLY and ModeReadOnly, or set DowngradeOnReadOnlyFS")
+	ErrOverlayIsBase      = errors.New("overlay file is the base file")
 )
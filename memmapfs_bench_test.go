@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/absfs/osfs"
@@ -51,6 +52,9 @@ func BenchmarkSequentialRead(b *testing.B) {
 			b.Run("MemMap", func(b *testing.B) {
 				benchmarkMemMapSequentialRead(b, size)
 			})
+			b.Run("MemMapReadNext", func(b *testing.B) {
+				benchmarkMemMapSequentialReadNext(b, size)
+			})
 		})
 	}
 }
@@ -119,6 +123,47 @@ func benchmarkMemMapSequentialRead(b *testing.B, size int) {
 	}
 }
 
+// benchmarkMemMapSequentialReadNext reads in the same 4KB steps as
+// benchmarkMemMapSequentialRead, but zero-copy.
+func benchmarkMemMapSequentialReadNext(b *testing.B, size int) {
+	tmpFile, cleanup := setupBenchmarkFile(b, size)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		b.Fatal(err)
+	}
+	mfs := New(osFS, DefaultConfig())
+
+	var sum byte
+
+	b.ResetTimer()
+	b.SetBytes(int64(size))
+
+	for i := 0; i < b.N; i++ {
+		file, err := mfs.Open(tmpFile)
+		if err != nil {
+			b.Fatal(err)
+		}
+		mf := file.(*MappedFile)
+
+		for {
+			p, err := mf.ReadNext(4096)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			// Touch each page so it is faulted in, as a copying read would
+			sum += p[0]
+		}
+
+		file.Close()
+	}
+	_ = sum
+}
+
 // BenchmarkRandomRead compares random access read performance.
 func BenchmarkRandomRead(b *testing.B) {
 	sizes := []int{
@@ -973,3 +1018,37 @@ func BenchmarkWindowSize(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkGetFD compares descriptor extraction from a wrapped file with the
+// per-type accessor cache against searching the fields on every call.
+func BenchmarkGetFD(b *testing.B) {
+	tmpFile, cleanup := setupBenchmarkFile(b, 4096)
+	defer cleanup()
+
+	fs, err := osfs.NewFS()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	file, err := fs.Open(tmpFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	b.Run("Cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := wrappedFD(file); !ok {
+				b.Fatal("no descriptor found")
+			}
+		}
+	})
+
+	b.Run("Uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, ok := findFDAccessor(reflect.TypeOf(file))(file); !ok {
+				b.Fatal("no descriptor found")
+			}
+		}
+	})
+}
@@ -1,12 +1,24 @@
 package memmapfs
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/absfs/absfs"
 	"github.com/absfs/osfs"
@@ -1311,6 +1323,8 @@ func TestAdviseLinuxSpecific(t *testing.T) {
 	}{
 		{"HugePage", mf.AdviseHugePage},
 		{"NoHugePage", mf.AdviseNoHugePage},
+		{"DontFork", mf.AdviseDontFork},
+		{"DoFork", mf.AdviseDoFork},
 	}
 
 	for _, tt := range tests {
@@ -1692,6 +1706,44 @@ func TestCheckTruncation(t *testing.T) {
 	file.Close()
 }
 
+// TestCheckTruncationStaleCache tests that a cached stat from before a
+// truncation does not hide it.
+func TestCheckTruncationStaleCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows cannot truncate a mapped file")
+	}
+
+	tmpFile, cleanup := createTestFile(t, "Hello, memmapfs!")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.StatCacheInterval = time.Hour
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Fill the cache before the truncation
+	if _, err := mf.StatCached(false); err != nil {
+		t.Fatalf("StatCached() failed: %v", err)
+	}
+	if err := os.Truncate(tmpFile, 5); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+
+	truncated, err := mf.checkTruncation()
+	if !truncated || err == nil {
+		t.Errorf("checkTruncation() = %v, %v, want truncation detected", truncated, err)
+	}
+}
+
 // TestFilesystemMethods tests various MemMapFS methods.
 func TestFilesystemMethods(t *testing.T) {
 	osFS, err := osfs.NewFS()
@@ -1857,7 +1909,7 @@ func TestWriteBeyondFileSize(t *testing.T) {
 	// Try to write more than file size
 	largeData := make([]byte, 100)
 	_, err = file.Write(largeData)
-	if err != io.ErrShortWrite {
+	if !errors.Is(err, io.ErrShortWrite) {
 		t.Errorf("Expected io.ErrShortWrite, got %v", err)
 	}
 }
@@ -2050,3 +2102,5670 @@ func TestConfigCombinations(t *testing.T) {
 		})
 	}
 }
+
+// TestOpenWriteOnly tests that mapping a write-only descriptor fails cleanly.
+func TestOpenWriteOnly(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "Hello, memmapfs!")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadWrite,
+		SyncMode:    SyncNever,
+		MapFullFile: true,
+	}
+	mfs := New(osFS, config)
+
+	_, err = mfs.OpenFile(tmpFile, os.O_WRONLY, 0644)
+	if !errors.Is(err, ErrWriteOnlyMapping) {
+		t.Fatalf("Expected ErrWriteOnlyMapping, got %v", err)
+	}
+
+	// A shared writable mapping needs write access as well
+	_, err = mfs.Open(tmpFile)
+	if !errors.Is(err, ErrReadOnlyDescriptor) {
+		t.Fatalf("Expected ErrReadOnlyDescriptor, got %v", err)
+	}
+
+	// Backends may hand out a read-only descriptor whatever the flags
+	if runtime.GOOS != "windows" {
+		roFile, err := osFS.OpenFile(tmpFile, os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		defer roFile.Close()
+
+		if _, err := newMappedFile(roFile, config, 16, nil); !errors.Is(err, ErrReadOnlyDescriptor) {
+			t.Fatalf("Expected ErrReadOnlyDescriptor from a read-only descriptor, got %v", err)
+		}
+	}
+
+	// Empty files are never mapped, so O_WRONLY is still allowed
+	emptyFile := filepath.Join(t.TempDir(), "empty.txt")
+	file, err := mfs.OpenFile(emptyFile, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() on empty file failed: %v", err)
+	}
+	file.Close()
+}
+
+// TestSize tests the cached size accessor.
+func TestSize(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, DefaultConfig())
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	if mf.Size() != int64(len(content)) {
+		t.Errorf("Expected Size() %d, got %d", len(content), mf.Size())
+	}
+}
+
+// TestMultiMappedFile tests reading several files as one logical region.
+func TestMultiMappedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	parts := []string{"Hello, ", "", "multi", "mapped world!"}
+
+	var names []string
+	var expected string
+	for i, part := range parts {
+		name := filepath.Join(tmpDir, fmt.Sprintf("segment%d.txt", i))
+		if err := os.WriteFile(name, []byte(part), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		names = append(names, name)
+		expected += part
+	}
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, DefaultConfig())
+
+	multi, err := mfs.OpenMulti(names)
+	if err != nil {
+		t.Fatalf("OpenMulti() failed: %v", err)
+	}
+	defer multi.Close()
+
+	if multi.Size() != int64(len(expected)) {
+		t.Errorf("Expected Size() %d, got %d", len(expected), multi.Size())
+	}
+
+	// Sequential read of the whole region
+	data, err := io.ReadAll(multi)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(data))
+	}
+
+	// ReadAt spanning a segment boundary (and the empty segment)
+	buf := make([]byte, 8)
+	n, err := multi.ReadAt(buf, 4)
+	if err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf[:n]) != expected[4:12] {
+		t.Errorf("Expected %q, got %q", expected[4:12], string(buf[:n]))
+	}
+
+	// ReadAt past the end returns a short read with EOF
+	n, err = multi.ReadAt(buf, multi.Size()-3)
+	if err != io.EOF || n != 3 {
+		t.Errorf("Expected 3 bytes and io.EOF, got %d and %v", n, err)
+	}
+
+	// Seek relative to the end
+	pos, err := multi.Seek(-6, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if pos != multi.Size()-6 {
+		t.Errorf("Expected position %d, got %d", multi.Size()-6, pos)
+	}
+	n, _ = multi.Read(buf)
+	if string(buf[:n]) != "world!" {
+		t.Errorf("Expected %q, got %q", "world!", string(buf[:n]))
+	}
+
+	// Reads after Close fail instead of reaching the closed segments
+	if err := multi.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := multi.ReadAt(buf, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ReadAt() after Close() = %v, want os.ErrClosed", err)
+	}
+	if _, err := multi.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if _, err := multi.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read() after Close() = %v, want os.ErrClosed", err)
+	}
+}
+
+// TestDontFork tests applying MADV_DONTFORK at map time.
+func TestDontFork(t *testing.T) {
+	content := make([]byte, 64*1024)
+	for i := range content {
+		content[i] = byte(i % 256)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: false,
+		WindowSize:  16 * 1024,
+		DontFork:    true,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	// Reading across windows re-applies the advice on every remap
+	buf := make([]byte, 1)
+	for _, off := range []int64{0, 20 * 1024, 50 * 1024} {
+		if _, err := file.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d) failed: %v", off, err)
+		}
+		if buf[0] != byte(off%256) {
+			t.Errorf("At offset %d expected %d, got %d", off, byte(off%256), buf[0])
+		}
+	}
+}
+
+// TestReadAll tests copying the full contents of full and windowed mappings.
+func TestReadAll(t *testing.T) {
+	content := make([]byte, 100*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	configs := map[string]*Config{
+		"FullFile": DefaultConfig(),
+		"Windowed": {
+			Mode:        ModeReadOnly,
+			MapFullFile: false,
+			WindowSize:  32 * 1024,
+		},
+	}
+
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			mfs := New(osFS, config)
+
+			file, err := mfs.Open(tmpFile)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			mf := file.(*MappedFile)
+
+			// Position must not affect the result
+			if _, err := mf.Seek(1000, io.SeekStart); err != nil {
+				t.Fatalf("Seek() failed: %v", err)
+			}
+
+			data, err := mf.ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll() failed: %v", err)
+			}
+			mf.Close()
+
+			// The copy must remain valid after Close
+			if string(data) != string(content) {
+				t.Error("ReadAll() content mismatch")
+			}
+		})
+	}
+}
+
+// TestMmapRetry tests retrying transient mapping failures with backoff.
+func TestMmapRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ENOMEM is reported differently on Windows")
+	}
+
+	mf := &MappedFile{
+		config: &Config{
+			MmapRetries:      3,
+			MmapRetryBackoff: time.Microsecond,
+		},
+	}
+
+	// Succeeds on the second retry
+	attempts := 0
+	err := mf.retryMmap(func() error {
+		attempts++
+		if attempts < 2 {
+			return syscall.ENOMEM
+		}
+		return nil
+	}, syscall.ENOMEM)
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+
+	// Gives up after MmapRetries attempts
+	attempts = 0
+	err = mf.retryMmap(func() error {
+		attempts++
+		return syscall.ENOMEM
+	}, syscall.ENOMEM)
+	if !errors.Is(err, syscall.ENOMEM) {
+		t.Errorf("Expected ENOMEM, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// Non-transient errors are not retried
+	attempts = 0
+	err = mf.retryMmap(func() error {
+		attempts++
+		return nil
+	}, syscall.EACCES)
+	if !errors.Is(err, syscall.EACCES) || attempts != 0 {
+		t.Errorf("Expected EACCES without retries, got %v after %d attempts", err, attempts)
+	}
+}
+
+// TestWriteAtv tests vectored writes, including writes spanning windows.
+func TestWriteAtv(t *testing.T) {
+	content := make([]byte, 64*1024)
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadWrite,
+		SyncMode:    SyncLazy,
+		MapFullFile: false,
+		WindowSize:  16 * 1024,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	mf := file.(*MappedFile)
+
+	// Write a header and body that straddle the first window boundary
+	header := []byte("HEADER:")
+	body := []byte("body spanning two windows")
+	off := int64(16*1024 - 10)
+
+	n, err := mf.WriteAtv([][]byte{header, body}, off)
+	if err != nil {
+		t.Fatalf("WriteAtv() failed: %v", err)
+	}
+	if n != len(header)+len(body) {
+		t.Errorf("Expected %d bytes written, got %d", len(header)+len(body), n)
+	}
+
+	// Writes past the end are rejected up front
+	if _, err := mf.WriteAtv([][]byte{header, body}, int64(len(content)-5)); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Expected io.ErrShortWrite, got %v", err)
+	}
+
+	if err := mf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	expected := string(header) + string(body)
+	if got := string(data[off : off+int64(len(expected))]); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+// TestExecModes tests the executable mapping modes.
+// Executable mappings may be rejected by noexec mounts or W^X policies.
+func TestExecModes(t *testing.T) {
+	content := "\x90\x90\x90\xc3"
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		mode     MappingMode
+		writable bool
+	}{
+		{"ReadExec", ModeReadExec, false},
+		{"ReadWriteExec", ModeReadWriteExec, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile, cleanup := createTestFile(t, content)
+			defer cleanup()
+
+			mfs := New(osFS, &Config{Mode: tt.mode, MapFullFile: true})
+
+			file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+			if err != nil {
+				t.Skipf("Executable mapping not permitted here: %v", err)
+			}
+			defer file.Close()
+
+			mf := file.(*MappedFile)
+			if string(mf.Data()) != content {
+				t.Errorf("Expected %q, got %q", content, string(mf.Data()))
+			}
+
+			_, err = mf.WriteAt([]byte{0xcc}, 0)
+			if tt.writable && err != nil {
+				t.Errorf("WriteAt() failed: %v", err)
+			}
+			if !tt.writable && err != ErrWriteToReadOnlyMap {
+				t.Errorf("Expected ErrWriteToReadOnlyMap, got %v", err)
+			}
+		})
+	}
+}
+
+// TestBackingRemoved tests that a mapping survives removal of its file.
+func TestBackingRemoved(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not allow removing mapped files")
+	}
+
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadWrite,
+		SyncMode:    SyncLazy,
+		MapFullFile: true,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	if err := os.Remove(tmpFile); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	// Reads from the mapping keep working
+	buf := make([]byte, len(content))
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() after remove failed: %v", err)
+	}
+	if string(buf) != content {
+		t.Errorf("Expected %q, got %q", content, string(buf))
+	}
+
+	// Sync of pending writes reports the removed backing
+	if _, err := file.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := file.Sync(); err != ErrBackingRemoved {
+		t.Errorf("Expected ErrBackingRemoved, got %v", err)
+	}
+}
+
+// TestOnWindowSlide tests the window slide callback.
+func TestOnWindowSlide(t *testing.T) {
+	content := make([]byte, 64*1024)
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	type slide struct{ oldOffset, newOffset, windowSize int64 }
+	var slides []slide
+
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: false,
+		WindowSize:  16 * 1024,
+		OnWindowSlide: func(oldOffset, newOffset, windowSize int64) {
+			slides = append(slides, slide{oldOffset, newOffset, windowSize})
+		},
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, 1)
+	for _, off := range []int64{100, 40 * 1024, 40*1024 + 1, 0} {
+		if _, err := file.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d) failed: %v", off, err)
+		}
+	}
+
+	expected := []slide{
+		{0, 32 * 1024, 16 * 1024},
+		{32 * 1024, 0, 16 * 1024},
+	}
+	if len(slides) != len(expected) {
+		t.Fatalf("Expected %d slides, got %d: %v", len(expected), len(slides), slides)
+	}
+	for i := range expected {
+		if slides[i] != expected[i] {
+			t.Errorf("Slide %d: expected %v, got %v", i, expected[i], slides[i])
+		}
+	}
+}
+
+// TestOnSyncError tests that periodic sync failures are reported.
+func TestOnSyncError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows does not allow removing mapped files")
+	}
+
+	tmpFile, cleanup := createTestFile(t, "Hello, memmapfs!")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	config := &Config{
+		Mode:         ModeReadWrite,
+		SyncMode:     SyncPeriodic,
+		SyncInterval: 10 * time.Millisecond,
+		MapFullFile:  true,
+		OnSyncError: func(mf *MappedFile, err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	}
+	mfs := New(osFS, config)
+	defer mfs.syncManager.stop()
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	// Removing the backing file makes every periodic sync fail
+	if _, err := file.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := os.Remove(tmpFile); err != nil {
+		t.Fatalf("Remove() failed: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != ErrBackingRemoved {
+			t.Errorf("Expected ErrBackingRemoved, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnSyncError was not called")
+	}
+}
+
+// TestPreallocate tests growing and reserving space before mapping.
+func TestPreallocate(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "prealloc.db")
+	const size = 64 * 1024
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:            ModeReadWrite,
+		SyncMode:        SyncLazy,
+		MapFullFile:     true,
+		Preallocate:     true,
+		PreallocateSize: size,
+	}
+	mfs := New(osFS, config)
+
+	// A freshly created file is grown and mapped immediately
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf, ok := file.(*MappedFile)
+	if !ok {
+		t.Fatal("Expected preallocated file to be mapped")
+	}
+	if mf.Size() != size {
+		t.Errorf("Expected Size() %d, got %d", size, mf.Size())
+	}
+
+	if _, err := mf.WriteAt([]byte("end"), size-3); err != nil {
+		t.Errorf("WriteAt() at end failed: %v", err)
+	}
+
+	fi, err := os.Stat(tmpFile)
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if fi.Size() != size {
+		t.Errorf("Expected file size %d, got %d", size, fi.Size())
+	}
+}
+
+// TestReset tests rewinding a mapping for reuse.
+func TestReset(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadWrite,
+		SyncMode:    SyncLazy,
+		MapFullFile: true,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if _, err := mf.Write([]byte("J")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if err := mf.Reset(); err != nil {
+		t.Fatalf("Reset() failed: %v", err)
+	}
+	if mf.modified {
+		t.Error("Expected Reset() to clear modified")
+	}
+
+	// Data is left intact and reading starts over
+	buf := make([]byte, 5)
+	if _, err := mf.Read(buf); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if string(buf) != "Jello" {
+		t.Errorf("Expected %q, got %q", "Jello", string(buf))
+	}
+
+	if err := mf.ResetTo(7); err != nil {
+		t.Fatalf("ResetTo() failed: %v", err)
+	}
+	if _, err := mf.Read(buf); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if string(buf) != "memma" {
+		t.Errorf("Expected %q, got %q", "memma", string(buf))
+	}
+
+	if err := mf.ResetTo(-1); err != ErrInvalidOffset {
+		t.Errorf("Expected ErrInvalidOffset, got %v", err)
+	}
+}
+
+// TestReadAtv tests scatter reads, including reads spanning windows.
+func TestReadAtv(t *testing.T) {
+	content := make([]byte, 64*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: false,
+		WindowSize:  16 * 1024,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Scatter across the first window boundary
+	off := int64(16*1024 - 4)
+	header := make([]byte, 6)
+	body := make([]byte, 10)
+	n, err := mf.ReadAtv([][]byte{header, body}, off)
+	if err != nil {
+		t.Fatalf("ReadAtv() failed: %v", err)
+	}
+	if n != 16 {
+		t.Errorf("Expected 16 bytes read, got %d", n)
+	}
+	if string(header) != string(content[off:off+6]) || string(body) != string(content[off+6:off+16]) {
+		t.Error("ReadAtv() content mismatch")
+	}
+
+	// Buffers extending past the end get a short read with EOF
+	n, err = mf.ReadAtv([][]byte{header, body}, int64(len(content)-8))
+	if err != io.EOF || n != 8 {
+		t.Errorf("Expected 8 bytes and io.EOF, got %d and %v", n, err)
+	}
+}
+
+// TestWindowedReadAtParallel tests concurrent ReadAt on a windowed mapping,
+// mixing reads inside the current window with reads that slide it.
+func TestWindowedReadAtParallel(t *testing.T) {
+	content := make([]byte, 64*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: false,
+		WindowSize:  16 * 1024,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			buf := make([]byte, 64)
+			for i := 0; i < 200; i++ {
+				// Most reads stay in window 0; every goroutine occasionally slides
+				off := int64((i * 97) % (16*1024 - 64))
+				if i%50 == g {
+					off += 32 * 1024
+				}
+				if _, err := file.ReadAt(buf, off); err != nil {
+					errs <- fmt.Errorf("ReadAt(%d) failed: %v", off, err)
+					return
+				}
+				if string(buf) != string(content[off:off+64]) {
+					errs <- fmt.Errorf("ReadAt(%d) content mismatch", off)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestEOFOnLastRead tests both end-of-file conventions for Read.
+func TestEOFOnLastRead(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, eofOnLast := range []bool{false, true} {
+		t.Run(fmt.Sprintf("EOFOnLastRead=%v", eofOnLast), func(t *testing.T) {
+			mfs := New(osFS, &Config{Mode: ModeReadOnly, MapFullFile: true, EOFOnLastRead: eofOnLast})
+
+			file, err := mfs.Open(tmpFile)
+			if err != nil {
+				t.Fatalf("Open() failed: %v", err)
+			}
+			defer file.Close()
+
+			buf := make([]byte, len(content))
+			n, err := file.Read(buf)
+			if n != len(content) {
+				t.Errorf("Expected %d bytes, got %d", len(content), n)
+			}
+
+			expected := error(nil)
+			if eofOnLast {
+				expected = io.EOF
+			}
+			if err != expected {
+				t.Errorf("Expected %v with the final bytes, got %v", expected, err)
+			}
+
+			// Either way, the next Read reports EOF
+			if n, err := file.Read(buf); n != 0 || err != io.EOF {
+				t.Errorf("Expected (0, io.EOF), got (%d, %v)", n, err)
+			}
+		})
+	}
+}
+
+// TestSyncInvalidate tests that committed changes are visible to plain file reads.
+func TestSyncInvalidate(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "Hello, memmapfs!")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadWrite,
+		SyncMode:    SyncNever,
+		MapFullFile: true,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if _, err := mf.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := mf.SyncInvalidate(); err != nil {
+		t.Fatalf("SyncInvalidate() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data) != "Jello, memmapfs!" {
+		t.Errorf("Expected %q, got %q", "Jello, memmapfs!", string(data))
+	}
+}
+
+// TestWatchSize tests remapping a file as another writer appends to it.
+func TestWatchSize(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, DefaultConfig())
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	sizes := make(chan int64, 4)
+	stop := mf.WatchSize(5*time.Millisecond, func(newSize int64) {
+		sizes <- newSize
+	})
+	defer stop()
+
+	// Append from a separate writer
+	appended := " More data."
+	f, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	if _, err := f.WriteString(appended); err != nil {
+		t.Fatalf("WriteString() failed: %v", err)
+	}
+	f.Close()
+
+	expectedSize := int64(len(content) + len(appended))
+	select {
+	case size := <-sizes:
+		if size != expectedSize {
+			t.Errorf("Expected new size %d, got %d", expectedSize, size)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchSize callback was not called")
+	}
+
+	// The appended region is now mapped
+	buf := make([]byte, len(appended))
+	if _, err := mf.ReadAt(buf, int64(len(content))); err != nil {
+		t.Fatalf("ReadAt() of appended data failed: %v", err)
+	}
+	if string(buf) != appended {
+		t.Errorf("Expected %q, got %q", appended, string(buf))
+	}
+	if mf.Size() != expectedSize {
+		t.Errorf("Expected Size() %d, got %d", expectedSize, mf.Size())
+	}
+
+	// Watching a closed file starts nothing
+	if err := mf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	mf.WatchSize(time.Millisecond, func(int64) {
+		t.Error("WatchSize callback called on a closed file")
+	})()
+	mf.mu.RLock()
+	done := mf.done
+	mf.mu.RUnlock()
+	if done != nil {
+		t.Error("WatchSize() on a closed file created a done channel")
+	}
+}
+
+// TestPageSize tests the page size and allocation granularity accessors.
+func TestPageSize(t *testing.T) {
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, nil)
+
+	pageSize := mfs.PageSize()
+	if pageSize <= 0 || pageSize&(pageSize-1) != 0 {
+		t.Errorf("Expected a positive power of two page size, got %d", pageSize)
+	}
+
+	granularity := mfs.AllocationGranularity()
+	if granularity < pageSize || granularity%pageSize != 0 {
+		t.Errorf("Expected granularity to be a multiple of page size %d, got %d", pageSize, granularity)
+	}
+}
+
+// TestHugePageSize tests requesting an explicit huge page size.
+// Unavailable sizes fall back to default huge pages, then normal pages.
+func TestHugePageSize(t *testing.T) {
+	content := make([]byte, 2*1024*1024)
+	content[0] = 'X'
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{
+		Mode:             ModeReadOnly,
+		SyncMode:         SyncNever,
+		MapFullFile:      true,
+		UseHugePages:     true,
+		HugePageSizeLog2: 30, // 1GB pages are rarely reserved
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Skipf("Huge pages not available on this system: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, 1)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if buf[0] != 'X' {
+		t.Errorf("Expected 'X', got %q", buf[0])
+	}
+}
+
+// TestDegradeOnMapFailure tests falling back to the plain file.
+func TestDegradeOnMapFailure(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:                ModeReadWrite,
+		MapFullFile:         true,
+		DegradeOnMapFailure: true,
+	}
+	mfs := New(osFS, config)
+
+	// A write-only descriptor cannot be mapped
+	file, err := mfs.OpenFile(tmpFile, os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, ok := file.(*MappedFile); ok {
+		t.Fatal("Expected an unmapped file")
+	}
+
+	// The plain file still works
+	if _, err := file.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	file.Close()
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data) != "Jello, memmapfs!" {
+		t.Errorf("Expected %q, got %q", "Jello, memmapfs!", string(data))
+	}
+}
+
+// TestAtomicUint64 tests the atomic helpers on a shared memory region.
+func TestAtomicUint64(t *testing.T) {
+	sm, err := CreateSharedMemory(&SharedMemoryConfig{
+		Path: filepath.Join(t.TempDir(), "counters.dat"),
+		Size: 4096,
+		Mode: ModeReadWrite,
+	})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer sm.Remove()
+
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				if _, err := sm.AddUint64(8, 1); err != nil {
+					t.Errorf("AddUint64() failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, err := sm.LoadUint64(8)
+	if err != nil {
+		t.Fatalf("LoadUint64() failed: %v", err)
+	}
+	if val != goroutines*increments {
+		t.Errorf("Expected %d, got %d", goroutines*increments, val)
+	}
+
+	if err := sm.StoreUint64(16, 42); err != nil {
+		t.Fatalf("StoreUint64() failed: %v", err)
+	}
+	if swapped, err := sm.CompareAndSwapUint64(16, 41, 7); err != nil || swapped {
+		t.Errorf("Expected CAS with wrong old value to fail, got %v, %v", swapped, err)
+	}
+	if swapped, err := sm.CompareAndSwapUint64(16, 42, 7); err != nil || !swapped {
+		t.Errorf("Expected CAS to succeed, got %v, %v", swapped, err)
+	}
+	if val, _ := sm.LoadUint64(16); val != 7 {
+		t.Errorf("Expected 7 after CAS, got %d", val)
+	}
+
+	// Misaligned and out-of-bounds offsets are rejected
+	if _, err := sm.LoadUint64(3); err != ErrUnaligned {
+		t.Errorf("Expected ErrUnaligned, got %v", err)
+	}
+	if _, err := sm.LoadUint64(4096 - 4); err != ErrInvalidOffset {
+		t.Errorf("Expected ErrInvalidOffset, got %v", err)
+	}
+}
+
+// TestAdaptiveWindow tests that a thrashing window grows up to its cap.
+func TestAdaptiveWindow(t *testing.T) {
+	content := make([]byte, 256*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:           ModeReadOnly,
+		MapFullFile:    false,
+		WindowSize:     4 * 1024,
+		AdaptiveWindow: true,
+		MaxWindowSize:  64 * 1024,
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Bounce between distant offsets to force rapid slides
+	buf := make([]byte, 16)
+	for i := 0; i < 200; i++ {
+		off := int64((i % 2) * 200 * 1024)
+		if _, err := mf.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d) failed: %v", off, err)
+		}
+		if string(buf) != string(content[off:off+16]) {
+			t.Fatalf("ReadAt(%d) content mismatch", off)
+		}
+	}
+
+	stats := mf.Stats()
+	if stats.WindowSize <= 4*1024 {
+		t.Errorf("Expected window to grow beyond 4KB, got %d", stats.WindowSize)
+	}
+	if stats.WindowSize > 64*1024 {
+		t.Errorf("Expected window capped at 64KB, got %d", stats.WindowSize)
+	}
+	if stats.WindowSlides == 0 {
+		t.Error("Expected WindowSlides to be counted")
+	}
+}
+
+// TestDecompressor tests transparent decompression of gzip files.
+func TestDecompressor(t *testing.T) {
+	var plain bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&plain, "line %d\n", i)
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(plain.Bytes())
+	zw.Close()
+
+	tmpFile, cleanup := createTestFile(t, compressed.String())
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: true,
+		Decompressor: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	df, ok := file.(*DecompressingMappedFile)
+	if !ok {
+		t.Fatalf("Expected *DecompressingMappedFile, got %T", file)
+	}
+
+	// Random access into the decompressed stream
+	buf := make([]byte, 10)
+	off := int64(plain.Len() / 2)
+	if _, err := df.ReadAt(buf, off); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf) != string(plain.Bytes()[off:off+10]) {
+		t.Errorf("ReadAt() mismatch: got %q", string(buf))
+	}
+
+	// Seeking to the end reports the decompressed size
+	end, err := df.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if end != int64(plain.Len()) {
+		t.Errorf("Expected decompressed size %d, got %d", plain.Len(), end)
+	}
+
+	// Sequential read of everything
+	if _, err := df.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	data, err := io.ReadAll(df)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if !bytes.Equal(data, plain.Bytes()) {
+		t.Error("Decompressed content mismatch")
+	}
+
+	if _, err := df.Write([]byte("x")); err != ErrWriteToReadOnlyMap {
+		t.Errorf("Expected ErrWriteToReadOnlyMap, got %v", err)
+	}
+
+	// Writable modes are rejected
+	config.Mode = ModeReadWrite
+	if _, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0); err != ErrDecompressorMode {
+		t.Errorf("Expected ErrDecompressorMode, got %v", err)
+	}
+}
+
+// TestAddr tests querying the mapping's base address.
+func TestAddr(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "Hello, memmapfs!")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, DefaultConfig())
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	mf := file.(*MappedFile)
+
+	addr := mf.Addr()
+	if addr == 0 {
+		t.Fatal("Expected a non-zero address while mapped")
+	}
+	if addr%uintptr(mfs.AllocationGranularity()) != 0 {
+		t.Errorf("Expected address %#x to be aligned to %d", addr, mfs.AllocationGranularity())
+	}
+
+	mf.Close()
+	if mf.Addr() != 0 {
+		t.Error("Expected Addr() to be 0 after Close")
+	}
+}
+
+// TestSharedMemorySegment tests mapping a sub-range of a shared memory file.
+func TestSharedMemorySegment(t *testing.T) {
+	tmpDir := t.TempDir()
+	sharedPath := filepath.Join(tmpDir, "segment.dat")
+
+	const segOff = 8192
+	sm, err := CreateSharedMemory(&SharedMemoryConfig{
+		Path:   sharedPath,
+		Size:   3 * 4096,
+		Offset: segOff,
+		Length: 100,
+	})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer sm.Close()
+
+	if len(sm.Data()) != 100 {
+		t.Fatalf("len(Data()) = %d, want 100", len(sm.Data()))
+	}
+	if sm.Offset() != segOff {
+		t.Errorf("Offset() = %d, want %d", sm.Offset(), segOff)
+	}
+
+	copy(sm.Data(), "segment")
+	if err := sm.StoreUint64(8, 42); err != nil {
+		t.Fatalf("StoreUint64() failed: %v", err)
+	}
+
+	// Words outside the segment are refused, even where the file has them
+	for _, off := range []int64{-8, 96, 4096} {
+		if err := sm.StoreUint64(off, 1); !errors.Is(err, ErrInvalidOffset) {
+			t.Errorf("StoreUint64(%d) = %v, want ErrInvalidOffset", off, err)
+		}
+		if _, err := sm.LoadUint64(off); !errors.Is(err, ErrInvalidOffset) {
+			t.Errorf("LoadUint64(%d) = %v, want ErrInvalidOffset", off, err)
+		}
+	}
+	if got := int64(len(sm.Data())); got != 100 || sm.MappedFile().Dump().WindowOffset > segOff {
+		t.Errorf("segment mapping moved by out-of-segment access")
+	}
+	if err := sm.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(sharedPath)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(content[segOff:segOff+7]) != "segment" {
+		t.Errorf("file at %d = %q, want %q", segOff, content[segOff:segOff+7], "segment")
+	}
+
+	// A second mapping of an unaligned sub-range sees the same bytes
+	other, err := OpenSharedMemorySegment(sharedPath, true, segOff+3, 4)
+	if err != nil {
+		t.Fatalf("OpenSharedMemorySegment() failed: %v", err)
+	}
+	defer other.Close()
+
+	if string(other.Data()) != "ment" {
+		t.Errorf("Data() = %q, want %q", other.Data(), "ment")
+	}
+
+	whole, err := OpenSharedMemorySegment(sharedPath, false, segOff, 0)
+	if err != nil {
+		t.Fatalf("OpenSharedMemorySegment() failed: %v", err)
+	}
+	defer whole.Close()
+
+	if whole.Size() != 4096 {
+		t.Errorf("Size() = %d, want 4096", whole.Size())
+	}
+	if v, err := whole.LoadUint64(8); err != nil || v != 42 {
+		t.Errorf("LoadUint64(8) = %d, %v, want 42", v, err)
+	}
+
+	if _, err := OpenSharedMemorySegment(sharedPath, false, 4096, 3*4096); err == nil {
+		t.Error("Expected error for segment past end of file")
+	}
+}
+
+// TestAdviseRemoveZeroes tests that AdviseRemove discards mapped contents
+// consistently: the region reads as zeros in memory and in the file.
+func TestAdviseRemoveZeroes(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 3*4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true})
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	if err := mf.AdviseRemove(); err != nil {
+		t.Fatalf("AdviseRemove() failed: %v", err)
+	}
+
+	if !bytes.Equal(mf.Data(), make([]byte, 3*4096)) {
+		t.Error("mapped data not zeroed after AdviseRemove()")
+	}
+
+	if err := mf.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	content, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if !bytes.Equal(content, make([]byte, 3*4096)) {
+		t.Error("file not zeroed after AdviseRemove()")
+	}
+
+	// Read-only mappings cannot discard data
+	ro, err := New(osFS, DefaultConfig()).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer ro.Close()
+
+	if err := ro.(*MappedFile).AdviseRemove(); !errors.Is(err, ErrWriteToReadOnlyMap) {
+		t.Errorf("AdviseRemove() on read-only mapping = %v, want ErrWriteToReadOnlyMap", err)
+	}
+}
+
+// TestCloseFdAfterMap tests that a mapping stays readable after its
+// descriptor is closed, and that unsupported combinations are rejected.
+func TestCloseFdAfterMap(t *testing.T) {
+	content := "detached mapping content"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.CloseFdAfterMap = true
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	mf := file.(*MappedFile)
+	if _, ok := mf.file.(*detachedFile); !ok {
+		t.Fatalf("underlying file = %T, want *detachedFile", mf.file)
+	}
+
+	buf := make([]byte, len(content))
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf) != content {
+		t.Errorf("ReadAt() = %q, want %q", buf, content)
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if fi.Size() != int64(len(content)) {
+		t.Errorf("Stat().Size() = %d, want %d", fi.Size(), len(content))
+	}
+	if file.Name() != tmpFile {
+		t.Errorf("Name() = %q, want %q", file.Name(), tmpFile)
+	}
+
+	// Nothing is left to remap from, so Drain must not unmap
+	if err := mf.Drain(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Drain() of a detached mapping = %v, want os.ErrClosed", err)
+	}
+	if _, err := file.ReadAt(buf, 0); err != nil || string(buf) != content {
+		t.Errorf("ReadAt() after refused Drain() = %q, %v, want %q", buf, err, content)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	for _, c := range []*Config{
+		{Mode: ModeReadOnly, CloseFdAfterMap: true},
+		{Mode: ModeReadWrite, MapFullFile: true, CloseFdAfterMap: true},
+	} {
+		if _, err := New(osFS, c).Open(tmpFile); !errors.Is(err, ErrCloseFdAfterMap) {
+			t.Errorf("Open() with %+v = %v, want ErrCloseFdAfterMap", c, err)
+		}
+	}
+}
+
+// TestSeekEndAfterGrowth tests that Seek(0, io.SeekEnd) reports the new size
+// after the file grows past its old end and is remapped.
+func TestSeekEndAfterGrowth(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "initial")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true})
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	if pos, err := file.Seek(0, io.SeekEnd); err != nil || pos != 7 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v, want 7", pos, err)
+	}
+
+	// Write past the old end of file through a separate descriptor
+	f, err := os.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	if _, err := f.WriteAt([]byte(" and appended"), 7); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	f.Close()
+
+	mf := file.(*MappedFile)
+	if err := mf.Remap(); err != nil {
+		t.Fatalf("Remap() failed: %v", err)
+	}
+
+	pos, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if pos != 20 {
+		t.Errorf("Seek(0, SeekEnd) = %d, want 20", pos)
+	}
+
+	// The position is usable for reads of the grown region
+	if _, err := file.Seek(-8, io.SeekEnd); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	buf := make([]byte, 8)
+	if n, err := file.Read(buf); err != nil || string(buf[:n]) != "appended" {
+		t.Errorf("Read() = %q, %v, want %q", buf[:n], err, "appended")
+	}
+}
+
+// TestSyncDirtyThreshold tests that crossing SyncDirtyThreshold triggers a
+// periodic sync before the next tick.
+func TestSyncDirtyThreshold(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{
+		Mode:               ModeReadWrite,
+		SyncMode:           SyncPeriodic,
+		SyncInterval:       time.Hour,
+		SyncDirtyThreshold: 100,
+		MapFullFile:        true,
+	})
+	defer mfs.syncManager.stop()
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	dirty := func() int64 {
+		mf.mu.RLock()
+		defer mf.mu.RUnlock()
+		return mf.dirty
+	}
+
+	// Below the threshold, nothing is flushed until the next tick
+	if _, err := file.WriteAt(make([]byte, 50), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if d := dirty(); d != 50 {
+		t.Fatalf("dirty = %d, want 50", d)
+	}
+
+	// Crossing it requests an early flush
+	if _, err := file.Write(make([]byte, 60)); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for dirty() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("dirty = %d after threshold was crossed, want early flush", dirty())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestNoCache tests Config.NoCache and SetNoCache, which only have an effect
+// on macOS.
+func TestNoCache(t *testing.T) {
+	content := "no cache content"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.NoCache = true
+	mfs := New(osFS, config)
+
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, len(content))
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf) != content {
+		t.Errorf("ReadAt() = %q, want %q", buf, content)
+	}
+
+	mf := file.(*MappedFile)
+	if err := mf.SetNoCache(false); err != nil {
+		t.Errorf("SetNoCache(false) failed: %v", err)
+	}
+	if err := mf.SetNoCache(true); err != nil {
+		t.Errorf("SetNoCache(true) failed: %v", err)
+	}
+}
+
+// memoryBackend is a RemoteBackend over an in-memory byte slice that counts
+// range requests.
+type memoryBackend struct {
+	data     []byte
+	requests int
+}
+
+func (b *memoryBackend) Size() (int64, error) {
+	return int64(len(b.data)), nil
+}
+
+func (b *memoryBackend) ReadRange(off, length int64) (io.ReadCloser, error) {
+	b.requests++
+	return io.NopCloser(bytes.NewReader(b.data[off : off+length])), nil
+}
+
+// TestOpenRemote tests windowed reads of a file served by a RemoteBackend.
+func TestOpenRemote(t *testing.T) {
+	pageSize := os.Getpagesize()
+	content := make([]byte, 3*pageSize+100)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	backend := &memoryBackend{data: content}
+
+	mfs := New(nil, &Config{Mode: ModeReadOnly, WindowSize: int64(pageSize)})
+
+	mf, err := mfs.OpenRemote("https://example.com/data.bin", backend)
+	if err != nil {
+		t.Fatalf("OpenRemote() failed: %v", err)
+	}
+	defer mf.Close()
+
+	if backend.requests != 1 {
+		t.Errorf("requests after open = %d, want 1", backend.requests)
+	}
+
+	got, err := io.ReadAll(mf)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("remote contents mismatch")
+	}
+	if backend.requests != 4 {
+		t.Errorf("requests after full read = %d, want 4", backend.requests)
+	}
+
+	// Reads within the current window are served without new requests
+	buf := make([]byte, 10)
+	if _, err := mf.ReadAt(buf, int64(3*pageSize+5)); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if !bytes.Equal(buf, content[3*pageSize+5:3*pageSize+15]) {
+		t.Error("ReadAt() contents mismatch")
+	}
+	if backend.requests != 4 {
+		t.Errorf("requests after in-window ReadAt = %d, want 4", backend.requests)
+	}
+
+	fi, err := mf.Stat()
+	if err != nil {
+		t.Fatalf("Stat() failed: %v", err)
+	}
+	if fi.Name() != "data.bin" || fi.Size() != int64(len(content)) {
+		t.Errorf("Stat() = %q, %d, want %q, %d", fi.Name(), fi.Size(), "data.bin", len(content))
+	}
+
+	if _, err := mf.WriteAt([]byte("x"), 0); !errors.Is(err, ErrWriteToReadOnlyMap) {
+		t.Errorf("WriteAt() = %v, want ErrWriteToReadOnlyMap", err)
+	}
+
+	rw := New(nil, &Config{Mode: ModeReadWrite})
+	if _, err := rw.OpenRemote("data.bin", backend); !errors.Is(err, ErrRemoteMode) {
+		t.Errorf("OpenRemote() with ModeReadWrite = %v, want ErrRemoteMode", err)
+	}
+}
+
+// TestSyncStatus tests LastSyncTime and MemMapFS.SyncStatus.
+func TestSyncStatus(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	if status := New(osFS, DefaultConfig()).SyncStatus(); status != nil {
+		t.Errorf("SyncStatus() without SyncPeriodic = %v, want nil", status)
+	}
+
+	mfs := New(osFS, &Config{
+		Mode:         ModeReadWrite,
+		SyncMode:     SyncPeriodic,
+		SyncInterval: time.Hour,
+		MapFullFile:  true,
+	})
+	defer mfs.syncManager.stop()
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	if !mf.LastSyncTime().IsZero() {
+		t.Errorf("LastSyncTime() before sync = %v, want zero", mf.LastSyncTime())
+	}
+
+	if _, err := file.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	status := mfs.SyncStatus()
+	if len(status) != 1 {
+		t.Fatalf("len(SyncStatus()) = %d, want 1", len(status))
+	}
+	if status[0].Name != tmpFile || status[0].Dirty != 5 || !status[0].LastSync.IsZero() {
+		t.Errorf("SyncStatus() = %+v, want %s with 5 dirty bytes and no sync", status[0], tmpFile)
+	}
+
+	before := time.Now()
+	if err := file.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	if mf.LastSyncTime().Before(before) {
+		t.Errorf("LastSyncTime() = %v, want after %v", mf.LastSyncTime(), before)
+	}
+	status = mfs.SyncStatus()
+	if status[0].Dirty != 0 || !status[0].LastSync.Equal(mf.LastSyncTime()) {
+		t.Errorf("SyncStatus() after Sync = %+v, want no dirty bytes", status[0])
+	}
+}
+
+// TestValidateFlags tests that ValidateFlags makes the kernel reject flags
+// it would otherwise ignore.
+func TestValidateFlags(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("MAP_SHARED_VALIDATE is Linux-specific")
+	}
+
+	tmpFile, cleanup := createTestFile(t, "validate flags")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.ValidateFlags = true
+
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() with ValidateFlags failed: %v", err)
+	}
+	file.Close()
+
+	// MAP_SYNC is only supported on DAX filesystems
+	const mapSync = 0x80000
+	config.ExtraMmapFlags = mapSync
+
+	file, err = New(osFS, config).Open(tmpFile)
+	if err == nil {
+		file.Close()
+		t.Skip("filesystem supports MAP_SYNC")
+	}
+	if !errors.Is(err, syscall.EOPNOTSUPP) {
+		t.Errorf("Open() with validated MAP_SYNC = %v, want EOPNOTSUPP", err)
+	}
+}
+
+// TestRecords tests iterating a file as fixed-size records, including
+// records that straddle window boundaries.
+func TestRecords(t *testing.T) {
+	pageSize := os.Getpagesize()
+	const recordSize = 24
+
+	content := make([]byte, 3*pageSize+10)
+	for i := range content {
+		content[i] = byte(i / recordSize)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, config := range []*Config{
+		{Mode: ModeReadOnly, MapFullFile: true},
+		{Mode: ModeReadOnly, WindowSize: int64(pageSize)},
+	} {
+		file, err := New(osFS, config).Open(tmpFile)
+		if err != nil {
+			t.Fatalf("Open() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+
+		if _, err := mf.Records(recordSize, false); !errors.Is(err, ErrPartialRecord) {
+			t.Errorf("Records() = %v, want ErrPartialRecord", err)
+		}
+
+		records, err := mf.Records(recordSize, true)
+		if err != nil {
+			t.Fatalf("Records() failed: %v", err)
+		}
+
+		var got []byte
+		count := 0
+		records(func(rec []byte, err error) bool {
+			if err != nil {
+				t.Fatalf("record %d: %v", count, err)
+			}
+			got = append(got, rec...)
+			count++
+			return true
+		})
+
+		want := (len(content) + recordSize - 1) / recordSize
+		if count != want {
+			t.Errorf("WindowSize %d: got %d records, want %d", config.WindowSize, count, want)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("WindowSize %d: records do not reassemble the file", config.WindowSize)
+		}
+
+		// Stopping early
+		count = 0
+		records(func(rec []byte, err error) bool {
+			count++
+			return count < 3
+		})
+		if count != 3 {
+			t.Errorf("iteration continued after yield returned false: %d records", count)
+		}
+
+		file.Close()
+	}
+}
+
+// TestSharedMemoryNotifyWait tests event signaling through a counter in a
+// shared memory region, from two independent mappings of the same file.
+func TestSharedMemoryNotifyWait(t *testing.T) {
+	tmpDir := t.TempDir()
+	sharedPath := filepath.Join(tmpDir, "events.dat")
+
+	producer, err := CreateSharedMemory(&SharedMemoryConfig{Path: sharedPath, Size: 4096})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer producer.Close()
+
+	consumer, err := OpenSharedMemory(sharedPath, false)
+	if err != nil {
+		t.Fatalf("OpenSharedMemory() failed: %v", err)
+	}
+	defer consumer.Close()
+
+	seq, err := consumer.EventSeq(0)
+	if err != nil {
+		t.Fatalf("EventSeq() failed: %v", err)
+	}
+
+	if err := consumer.Wait(0, seq, 20*time.Millisecond); !errors.Is(err, ErrWaitTimeout) {
+		t.Errorf("Wait() without Notify = %v, want ErrWaitTimeout", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Wait(0, seq, 5*time.Second)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	copy(producer.Data()[8:], "ready")
+	if err := producer.Notify(0); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Wait() failed: %v", err)
+	}
+	if string(consumer.Data()[8:13]) != "ready" {
+		t.Errorf("consumer sees %q, want %q", consumer.Data()[8:13], "ready")
+	}
+
+	// A Notify before Wait is not missed
+	next, _ := consumer.EventSeq(0)
+	if next != seq+1 {
+		t.Errorf("EventSeq() = %d, want %d", next, seq+1)
+	}
+	if err := consumer.Wait(0, seq, time.Second); err != nil {
+		t.Errorf("Wait() on stale seq = %v, want nil", err)
+	}
+
+	if err := consumer.Notify(0); !errors.Is(err, ErrWriteToReadOnlyMap) {
+		t.Errorf("Notify() on read-only region = %v, want ErrWriteToReadOnlyMap", err)
+	}
+	if err := producer.Notify(2); !errors.Is(err, ErrUnaligned) {
+		t.Errorf("Notify(2) = %v, want ErrUnaligned", err)
+	}
+}
+
+// TestPrivateReadOnly tests mapping read-only files with MAP_PRIVATE.
+func TestPrivateReadOnly(t *testing.T) {
+	content := "private read-only content"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.PrivateReadOnly = true
+
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	if runtime.GOOS != "windows" {
+		const mapShared = 0x1 // Same value on Linux, macOS and the BSDs
+		if _, flags := file.(*MappedFile).getProtectionFlags(); flags&mapShared != 0 {
+			t.Errorf("flags = %#x, want MAP_PRIVATE", flags)
+		}
+	}
+
+	buf := make([]byte, len(content))
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf) != content {
+		t.Errorf("ReadAt() = %q, want %q", buf, content)
+	}
+
+	if _, err := file.WriteAt([]byte("x"), 0); !errors.Is(err, ErrWriteToReadOnlyMap) {
+		t.Errorf("WriteAt() = %v, want ErrWriteToReadOnlyMap", err)
+	}
+}
+
+// TestGrowOnWriteAt tests that WriteAt and WriteAtv past the end extend the
+// file when GrowOnWriteAt is set.
+func TestGrowOnWriteAt(t *testing.T) {
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, config := range []*Config{
+		{Mode: ModeReadWrite, MapFullFile: true, GrowOnWriteAt: true},
+		{Mode: ModeReadWrite, WindowSize: 64 * 1024, GrowOnWriteAt: true},
+	} {
+		tmpFile, cleanup := createTestFile(t, "header")
+		defer cleanup()
+
+		file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+
+		const off = 1 << 20
+		if n, err := file.WriteAt([]byte("record"), off); err != nil || n != 6 {
+			t.Fatalf("WriteAt() past EOF = %d, %v, want 6, nil", n, err)
+		}
+
+		mf := file.(*MappedFile)
+		if mf.Size() != off+6 {
+			t.Errorf("Size() = %d, want %d", mf.Size(), off+6)
+		}
+
+		buf := make([]byte, 6)
+		if _, err := file.ReadAt(buf, 0); err != nil || string(buf) != "header" {
+			t.Errorf("ReadAt(0) = %q, %v, want %q", buf, err, "header")
+		}
+		if _, err := file.ReadAt(buf, off/2); err != nil || !bytes.Equal(buf, make([]byte, 6)) {
+			t.Errorf("ReadAt() in hole = %q, %v, want zeros", buf, err)
+		}
+
+		// Vectored writes grow the file too
+		if n, err := mf.WriteAtv([][]byte{[]byte("tr"), []byte("ailer")}, off+6); err != nil || n != 7 {
+			t.Fatalf("WriteAtv() past EOF = %d, %v, want 7, nil", n, err)
+		}
+
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+
+		content, err := os.ReadFile(tmpFile)
+		if err != nil {
+			t.Fatalf("ReadFile() failed: %v", err)
+		}
+		if len(content) != off+13 || string(content[off:]) != "recordtrailer" {
+			t.Errorf("file has %d bytes ending %q, want %d ending %q", len(content), content[len(content)-13:], off+13, "recordtrailer")
+		}
+	}
+
+	// Without the option the write still fails
+	tmpFile, cleanup := createTestFile(t, "header")
+	defer cleanup()
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt([]byte("record"), 1<<20); err == nil {
+		t.Error("WriteAt() past EOF without GrowOnWriteAt succeeded")
+	}
+}
+
+// fdWrapper wraps a file the way custom filesystems commonly do.
+type fdWrapper struct {
+	name  string
+	inner interface{}
+	f     *os.File
+}
+
+// TestWrappedFD tests descriptor extraction from wrapped files through the
+// per-type accessor cache.
+func TestWrappedFD(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "wrapped")
+	defer cleanup()
+
+	f, err := os.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer f.Close()
+
+	for _, w := range []*fdWrapper{
+		{name: "field", f: f},
+		{name: "interface", inner: f},
+	} {
+		// The second lookup is served by the cached accessor
+		for i := 0; i < 2; i++ {
+			fd, ok := wrappedFD(w)
+			if !ok || fd != f.Fd() {
+				t.Errorf("%s: wrappedFD() = %d, %v, want %d, true", w.name, fd, ok, f.Fd())
+			}
+		}
+	}
+
+	if _, ok := wrappedFD(&fdWrapper{name: "empty"}); ok {
+		t.Error("wrappedFD() found a descriptor in an empty wrapper")
+	}
+	if _, ok := wrappedFD("not a file"); ok {
+		t.Error("wrappedFD() found a descriptor in a string")
+	}
+}
+
+// TestReadAtPooled tests copying mapped data into pooled buffers.
+func TestReadAtPooled(t *testing.T) {
+	content := "pooled buffer content"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, DefaultConfig()).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+
+	buf, release, err := mf.ReadAtPooled(7, 6)
+	if err != nil {
+		t.Fatalf("ReadAtPooled() failed: %v", err)
+	}
+	if string(buf) != "buffer" {
+		t.Errorf("ReadAtPooled() = %q, want %q", buf, "buffer")
+	}
+
+	// The copy is mutable without touching the read-only mapping
+	buf[0] = 'B'
+	if mf.Data()[7] != 'b' {
+		t.Error("modifying the pooled copy changed the mapping")
+	}
+	release()
+
+	buf, release, err = mf.ReadAtPooled(14, 100)
+	if err != io.EOF {
+		t.Errorf("ReadAtPooled() past EOF error = %v, want io.EOF", err)
+	}
+	if string(buf) != "content" {
+		t.Errorf("ReadAtPooled() past EOF = %q, want %q", buf, "content")
+	}
+	release()
+
+	// The buffer is sized to the bytes available, not to the length asked for
+	buf, release, err = mf.ReadAtPooled(14, 1<<62)
+	if err != io.EOF || string(buf) != "content" || cap(buf) > 1<<10 {
+		t.Errorf("ReadAtPooled() far past EOF = %q (cap %d), %v, want %q, io.EOF", buf, cap(buf), err, "content")
+	}
+	if release != nil {
+		release()
+	}
+
+	if _, _, err := mf.ReadAtPooled(int64(len(content)), 4); err != io.EOF {
+		t.Errorf("ReadAtPooled() at EOF error = %v, want io.EOF", err)
+	}
+	if _, _, err := mf.ReadAtPooled(int64(len(content))+10, 4); err != io.EOF {
+		t.Errorf("ReadAtPooled() past EOF offset error = %v, want io.EOF", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, release, _ := mf.ReadAtPooled(0, 16)
+		release()
+	})
+	if allocs > 1 {
+		t.Errorf("ReadAtPooled() allocates %v times per call, want at most 1", allocs)
+	}
+}
+
+// TestStatCached tests reuse of the underlying file's stat within
+// StatCacheInterval, and forced refreshes.
+func TestStatCached(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "cached stat")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := DefaultConfig()
+	config.StatCacheInterval = time.Hour
+
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	fi, err := mf.StatCached(false)
+	if err != nil {
+		t.Fatalf("StatCached() failed: %v", err)
+	}
+	if fi.Size() != 11 {
+		t.Errorf("StatCached().Size() = %d, want 11", fi.Size())
+	}
+
+	// Grow the file behind the mapping's back
+	grow := func(p string, off int64) {
+		f, err := os.OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		defer f.Close()
+		if _, err := f.WriteAt([]byte(p), off); err != nil {
+			t.Fatalf("WriteAt() failed: %v", err)
+		}
+	}
+	grow(", grown", 11)
+
+	if fi, _ := mf.StatCached(false); fi.Size() != 11 {
+		t.Errorf("StatCached(false).Size() = %d, want cached 11", fi.Size())
+	}
+	if fi, _ := mf.StatCached(true); fi.Size() != 18 {
+		t.Errorf("StatCached(true).Size() = %d, want 18", fi.Size())
+	}
+
+	// Without an interval every call stats
+	mf.config = DefaultConfig()
+	grow(" again", 18)
+	if fi, _ := mf.StatCached(false); fi.Size() != 24 {
+		t.Errorf("StatCached(false) without interval = %d, want 24", fi.Size())
+	}
+}
+
+// TestCopyOnWriteWindowSlide tests that a windowed copy-on-write mapping
+// refuses to discard private changes by sliding away from them.
+func TestCopyOnWriteWindowSlide(t *testing.T) {
+	const windowSize = 64 * 1024
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("a", 3*windowSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeCopyOnWrite, WindowSize: windowSize})
+
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, 1)
+
+	// Unmodified windows slide freely
+	if _, err := file.ReadAt(buf, 2*windowSize); err != nil {
+		t.Fatalf("ReadAt() in another window failed: %v", err)
+	}
+
+	if _, err := file.WriteAt([]byte("b"), 2*windowSize+1); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	// Reads within the modified window still work
+	if _, err := file.ReadAt(buf, 2*windowSize+1); err != nil || buf[0] != 'b' {
+		t.Errorf("ReadAt() in window = %q, %v, want %q", buf, err, "b")
+	}
+
+	if _, err := file.ReadAt(buf, 0); !errors.Is(err, ErrCopyOnWriteSlide) {
+		t.Errorf("ReadAt() outside modified window = %v, want ErrCopyOnWriteSlide", err)
+	}
+}
+
+// TestReadFileMapped tests mapping a whole file read-only in one call.
+func TestReadFileMapped(t *testing.T) {
+	content := strings.Repeat("mapped file ", 10000)
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	// Windowed, writable configuration is overridden
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, WindowSize: 4096})
+
+	data, closer, err := mfs.ReadFileMapped(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFileMapped() failed: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("ReadFileMapped() returned %d bytes, want the %d byte file", len(data), len(content))
+	}
+	if _, ok := closer.(*MappedFile); !ok {
+		t.Errorf("closer = %T, want *MappedFile", closer)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// Empty files are not mapped but still readable
+	emptyFile, cleanupEmpty := createTestFile(t, "")
+	defer cleanupEmpty()
+
+	data, closer, err = mfs.ReadFileMapped(emptyFile)
+	if err != nil {
+		t.Fatalf("ReadFileMapped() on empty file failed: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("ReadFileMapped() on empty file = %d bytes, want 0", len(data))
+	}
+	closer.Close()
+
+	if _, _, err := mfs.ReadFileMapped(filepath.Dir(tmpFile)); err == nil {
+		t.Error("ReadFileMapped() on a directory succeeded")
+	}
+
+	// The mapping would hold compressed bytes
+	gzfs := New(osFS, &Config{
+		Mode: ModeReadOnly,
+		Decompressor: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	})
+	if _, _, err := gzfs.ReadFileMapped(tmpFile); !errors.Is(err, ErrCompressedMapping) {
+		t.Errorf("ReadFileMapped() with a Decompressor = %v, want ErrCompressedMapping", err)
+	}
+}
+
+// TestWindowedWriteLimits tests writes near window and file boundaries.
+func TestWindowedWriteLimits(t *testing.T) {
+	const windowSize = 64 * 1024
+	size := 2*windowSize + 100
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("a", size))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, WindowSize: windowSize}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	// Ending exactly at EOF in the last, partial window succeeds
+	if _, err := file.WriteAt([]byte("tail"), int64(size-4)); err != nil {
+		t.Errorf("WriteAt() ending at EOF failed: %v", err)
+	}
+
+	_, err = file.WriteAt([]byte("tail!"), int64(size-4))
+	if !errors.Is(err, io.ErrShortWrite) || !strings.Contains(err.Error(), fmt.Sprintf("file size %d", size)) {
+		t.Errorf("WriteAt() past EOF = %v, want ErrShortWrite naming the file size", err)
+	}
+
+	_, err = file.WriteAt([]byte("span"), windowSize-2)
+	want := fmt.Sprintf("window [0, %d)", windowSize)
+	if !errors.Is(err, io.ErrShortWrite) || !strings.Contains(err.Error(), want) {
+		t.Errorf("WriteAt() across window = %v, want ErrShortWrite naming %s", err, want)
+	}
+}
+
+// TestLchown tests that Lchown passes through to the underlying filesystem.
+func TestLchown(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink ownership is not supported on Windows")
+	}
+
+	tmpFile, cleanup := createTestFile(t, "target")
+	defer cleanup()
+
+	link := tmpFile + ".link"
+	if err := os.Symlink(tmpFile, link); err != nil {
+		t.Fatalf("Symlink() failed: %v", err)
+	}
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	// Changing ownership to ourselves is always permitted
+	if err := New(osFS, nil).Lchown(link, os.Getuid(), os.Getgid()); err != nil {
+		t.Errorf("Lchown() failed: %v", err)
+	}
+}
+
+// TestNoAtime tests mapping and reading files opened with NoAtime.
+func TestNoAtime(t *testing.T) {
+	content := "archived contents"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeReadOnly, MapFullFile: true, NoAtime: true})
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("ReadAll() = %q, want %q", data, content)
+	}
+}
+
+// TestDrain tests releasing the mapping and remapping on the next access.
+func TestDrain(t *testing.T) {
+	content := "0123456789abcdefghij"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, config := range []*Config{
+		{Mode: ModeReadWrite, MapFullFile: true},
+		{Mode: ModeReadWrite, WindowSize: int64(os.Getpagesize())},
+	} {
+		file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+
+		buf := make([]byte, 4)
+		if _, err := mf.Read(buf); err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+		if _, err := mf.WriteAt([]byte("X"), 10); err != nil {
+			t.Fatalf("WriteAt() failed: %v", err)
+		}
+
+		if err := mf.Drain(); err != nil {
+			t.Fatalf("Drain() failed: %v", err)
+		}
+		if mf.Data() != nil {
+			t.Error("Data() after Drain() is not nil")
+		}
+
+		// The position survives and the mapping comes back on demand
+		if _, err := mf.Read(buf); err != nil {
+			t.Fatalf("Read() after Drain() failed: %v", err)
+		}
+		if string(buf) != "4567" {
+			t.Errorf("Read() after Drain() = %q, want %q", buf, "4567")
+		}
+		if mf.Data() == nil {
+			t.Error("Data() is nil after remapping")
+		}
+
+		if err := mf.Drain(); err != nil {
+			t.Fatalf("Drain() failed: %v", err)
+		}
+		if _, err := mf.ReadAt(buf[:1], 10); err != nil || buf[0] != 'X' {
+			t.Errorf("ReadAt() after Drain() = %q, %v, want %q", buf[:1], err, "X")
+		}
+
+		mf.Close()
+	}
+
+	// Drained writes were synced to the file
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if data[10] != 'X' {
+		t.Errorf("file[10] = %q, want %q", data[10], 'X')
+	}
+}
+
+// TestDrainCopyOnWrite tests that modified private mappings refuse to drain.
+func TestDrainCopyOnWrite(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "private data")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeCopyOnWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if _, err := mf.WriteAt([]byte("P"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := mf.Drain(); !errors.Is(err, ErrCopyOnWriteDrain) {
+		t.Errorf("Drain() = %v, want ErrCopyOnWriteDrain", err)
+	}
+}
+
+// TestAdvisePopulate tests prefaulting an existing mapping.
+func TestAdvisePopulate(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("p", 3*os.Getpagesize()))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if err := mf.AdvisePopulateRead(); errors.Is(err, syscall.EINVAL) {
+		t.Skip("MADV_POPULATE_READ not supported by this kernel")
+	} else if err != nil {
+		t.Fatalf("AdvisePopulateRead() failed: %v", err)
+	}
+	if err := mf.AdvisePopulateWrite(); err != nil {
+		t.Fatalf("AdvisePopulateWrite() failed: %v", err)
+	}
+
+	// Populating does not change the contents
+	if data := mf.Data(); data[0] != 'p' || data[len(data)-1] != 'p' {
+		t.Error("AdvisePopulateWrite() changed the mapped data")
+	}
+}
+
+// fullDiskFile is an *os.File whose WriteAt fails with ENOSPC past limit.
+type fullDiskFile struct {
+	*os.File
+	limit int64
+}
+
+func (f *fullDiskFile) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) <= f.limit {
+		return f.File.WriteAt(p, off)
+	}
+	n := 0
+	if off < f.limit {
+		n, _ = f.File.WriteAt(p[:f.limit-off], off)
+	}
+	return n, syscall.ENOSPC
+}
+
+// TestRecoverSync tests the write-back used when a synchronous msync fails.
+func TestRecoverSync(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("o", 100))
+	defer cleanup()
+
+	for _, tc := range []struct {
+		limit   int64
+		partial bool
+	}{
+		{limit: 1000, partial: false},
+		{limit: 40, partial: true},
+	} {
+		osFile, err := os.OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+
+		config := &Config{Mode: ModeReadWrite, SyncMode: SyncImmediate, MapFullFile: true}
+		mf, err := newMappedFile(&fullDiskFile{File: osFile, limit: tc.limit}, config, 100, nil)
+		if err != nil {
+			t.Fatalf("newMappedFile() failed: %v", err)
+		}
+
+		mf.mu.Lock()
+		copy(mf.data, strings.Repeat("n", 100))
+		err = mf.recoverSync(errors.New("msync failed"))
+		mf.mu.Unlock()
+
+		var pse *PartialSyncError
+		switch {
+		case !tc.partial && err != nil:
+			t.Errorf("recoverSync() with space = %v, want nil", err)
+		case tc.partial && !errors.As(err, &pse):
+			t.Errorf("recoverSync() on full disk = %v, want *PartialSyncError", err)
+		case tc.partial:
+			if pse.Durable != tc.limit {
+				t.Errorf("Durable = %d, want %d", pse.Durable, tc.limit)
+			}
+			if !errors.Is(err, ErrPartialSync) || !errors.Is(err, syscall.ENOSPC) {
+				t.Errorf("recoverSync() = %v, want ErrPartialSync wrapping ENOSPC", err)
+			}
+		}
+
+		mf.Close()
+	}
+}
+
+// TestMaxFileSize tests refusing full-file mappings of oversized files.
+func TestMaxFileSize(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("s", 1000))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	_, err = New(osFS, &Config{Mode: ModeReadOnly, MapFullFile: true, MaxFileSize: 999}).Open(tmpFile)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Open() of oversized file = %v, want ErrFileTooLarge", err)
+	}
+
+	// Files at the limit, and windowed mappings, are fine
+	for _, config := range []*Config{
+		{Mode: ModeReadOnly, MapFullFile: true, MaxFileSize: 1000},
+		{Mode: ModeReadOnly, WindowSize: int64(os.Getpagesize()), MaxFileSize: 999},
+	} {
+		file, err := New(osFS, config).Open(tmpFile)
+		if err != nil {
+			t.Errorf("Open() failed: %v", err)
+			continue
+		}
+		file.Close()
+	}
+}
+
+// pipeFS reports every file it opens as a named pipe.
+type pipeFS struct {
+	absfs.FileSystem
+}
+
+type pipeFile struct {
+	absfs.File
+}
+
+type pipeInfo struct {
+	fs.FileInfo
+}
+
+func (p pipeFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	f, err := p.FileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return pipeFile{f}, nil
+}
+
+func (f pipeFile) Stat() (fs.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return pipeInfo{fi}, nil
+}
+
+func (fi pipeInfo) Mode() fs.FileMode {
+	return fi.FileInfo.Mode() | fs.ModeNamedPipe
+}
+
+// TestOpenNamedPipe tests that FIFOs are streamed rather than mapped.
+func TestOpenNamedPipe(t *testing.T) {
+	content := "streamed"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(pipeFS{osFS}, nil).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	if _, ok := file.(*MappedFile); ok {
+		t.Error("Open() mapped a named pipe")
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil || string(data) != content {
+		t.Errorf("ReadAll() = %q, %v, want %q", data, err, content)
+	}
+}
+
+// TestTruncateGrow tests growing a mapped file with Truncate.
+func TestTruncateGrow(t *testing.T) {
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	newSize := int64(3*os.Getpagesize() + 5)
+	for _, zeroFill := range []bool{false, true} {
+		tmpFile, cleanup := createTestFile(t, strings.Repeat("t", 100))
+		defer cleanup()
+
+		config := &Config{Mode: ModeReadWrite, MapFullFile: true, ZeroFillGrowth: zeroFill}
+		file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+
+		if err := mf.Truncate(newSize); err != nil {
+			t.Fatalf("Truncate() failed: %v", err)
+		}
+		if mf.Size() != newSize {
+			t.Errorf("Size() = %d, want %d", mf.Size(), newSize)
+		}
+
+		buf := make([]byte, newSize)
+		if _, err := mf.ReadAt(buf, 0); err != nil {
+			t.Fatalf("ReadAt() failed: %v", err)
+		}
+		if string(buf[:100]) != strings.Repeat("t", 100) {
+			t.Error("Truncate() changed the existing contents")
+		}
+		for i, b := range buf[100:] {
+			if b != 0 {
+				t.Fatalf("grown byte %d = %#x, want 0", 100+i, b)
+			}
+		}
+
+		// Shrinking unmaps, truncates and remaps
+		if err := mf.Truncate(10); err != nil {
+			t.Fatalf("Truncate() to shrink failed: %v", err)
+		}
+		if mf.Size() != 10 || len(mf.Data()) != 10 {
+			t.Errorf("after shrink Size() = %d, len(Data()) = %d, want 10", mf.Size(), len(mf.Data()))
+		}
+		if fi, err := os.Stat(tmpFile); err != nil {
+			t.Errorf("Stat() failed: %v", err)
+		} else if fi.Size() != 10 {
+			t.Errorf("file size after shrink = %d, want 10", fi.Size())
+		}
+
+		mf.Close()
+	}
+}
+
+// TestPin tests that pinned mappings stay in place until unpinned.
+func TestPin(t *testing.T) {
+	pageSize := os.Getpagesize()
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("a", pageSize)+strings.Repeat("b", pageSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadOnly, WindowSize: int64(pageSize)}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	mf := file.(*MappedFile)
+
+	data, err := mf.Pin()
+	if err != nil {
+		t.Fatalf("Pin() failed: %v", err)
+	}
+
+	// Reading outside the pinned window would slide it
+	buf := make([]byte, 1)
+	if _, err := mf.ReadAt(buf, int64(pageSize)); !errors.Is(err, ErrPinned) {
+		t.Errorf("ReadAt() outside pinned window = %v, want ErrPinned", err)
+	}
+	if err := mf.Drain(); !errors.Is(err, ErrPinned) {
+		t.Errorf("Drain() while pinned = %v, want ErrPinned", err)
+	}
+
+	// Close waits for the pin to be released
+	closed := make(chan error)
+	go func() {
+		closed <- mf.Close()
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close() returned while pinned")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if data[0] != 'a' {
+		t.Errorf("pinned data[0] = %q, want %q", data[0], 'a')
+	}
+	mf.Unpin()
+
+	if err := <-closed; err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+}
+
+// TestReadAtEOF tests the io.ReaderAt convention for reads at the end.
+func TestReadAtEOF(t *testing.T) {
+	content := "Hello, memmapfs!"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, config := range []*Config{
+		DefaultConfig(),
+		{Mode: ModeReadOnly, WindowSize: int64(os.Getpagesize())},
+	} {
+		file, err := New(osFS, config).Open(tmpFile)
+		if err != nil {
+			t.Fatalf("Open() failed: %v", err)
+		}
+
+		buf := make([]byte, 4)
+		if n, err := file.ReadAt(buf, int64(len(content))); n != 0 || err != io.EOF {
+			t.Errorf("ReadAt() at EOF = %d, %v, want 0, io.EOF", n, err)
+		}
+		if _, err := file.ReadAt(buf, int64(len(content)+1)); err != ErrInvalidOffset {
+			t.Errorf("ReadAt() past EOF = %v, want ErrInvalidOffset", err)
+		}
+
+		// Standard library readers work over the mapping
+		r := io.NewSectionReader(file, 0, int64(len(content)))
+		if data, err := io.ReadAll(r); err != nil || string(data) != content {
+			t.Errorf("ReadAll(SectionReader) = %q, %v, want %q", data, err, content)
+		}
+
+		file.Close()
+	}
+}
+
+// TestRemapKeepsPosition tests that growth remaps the window at the position.
+func TestRemapKeepsPosition(t *testing.T) {
+	const windowSize = 64 * 1024
+	content := make([]byte, 4*windowSize)
+	for i := range content {
+		content[i] = byte(i / windowSize)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, WindowSize: windowSize}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Map window 0, then move the position into window 3
+	buf := make([]byte, 1)
+	if _, err := mf.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if _, err := mf.Seek(3*windowSize+10, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+
+	if err := mf.Truncate(5 * windowSize); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+	if !mf.inWindow(3*windowSize + 10) {
+		t.Errorf("window at %d after growth does not hold the position", mf.windowOffset)
+	}
+
+	if _, err := mf.Read(buf); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if buf[0] != 3 {
+		t.Errorf("Read() after growth = %d, want 3", buf[0])
+	}
+}
+
+// TestAttachSharedMemory tests late joiners attaching to an existing region.
+func TestAttachSharedMemory(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "attach.dat")
+
+	creator, err := CreateSharedMemory(&SharedMemoryConfig{Path: sharedPath, Size: 4096})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer creator.Close()
+	copy(creator.Data(), "MAGICv1")
+
+	joiner, err := AttachSharedMemory(&SharedMemoryAttachConfig{
+		Path:          sharedPath,
+		PopulatePages: true,
+		Header:        []byte("MAGICv1"),
+	})
+	if err != nil {
+		t.Fatalf("AttachSharedMemory() failed: %v", err)
+	}
+	defer joiner.Close()
+
+	// The joiner defaults to read-write and shares the creator's pages
+	copy(joiner.Data()[100:], "ack")
+	if string(creator.Data()[100:103]) != "ack" {
+		t.Errorf("creator sees %q, want %q", creator.Data()[100:103], "ack")
+	}
+
+	_, err = AttachSharedMemory(&SharedMemoryAttachConfig{Path: sharedPath, Header: []byte("MAGICv2")})
+	if !errors.Is(err, ErrHeaderMismatch) {
+		t.Errorf("AttachSharedMemory() with wrong header = %v, want ErrHeaderMismatch", err)
+	}
+}
+
+// recordingLogger collects warnings for tests.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Warn(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+// TestLogger tests that silent fallbacks are reported to Config.Logger.
+func TestLogger(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "logged")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	config := &Config{Mode: ModeReadWrite, MapFullFile: true, DegradeOnMapFailure: true, Logger: logger}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	file.Close()
+
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "write-only") {
+		t.Errorf("Logger got %q, want one write-only warning", logger.messages)
+	}
+}
+
+// The standard structured logger can be used directly
+var _ Logger = (*slog.Logger)(nil)
+
+// TestOpenViews tests read-only and read-write views of one file.
+func TestOpenViews(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "initial contents")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	reader, writer, err := New(osFS, &Config{MapFullFile: true, SyncMode: SyncLazy}).OpenViews(tmpFile)
+	if err != nil {
+		t.Fatalf("OpenViews() failed: %v", err)
+	}
+
+	if _, err := reader.WriteAt([]byte("x"), 0); err != ErrWriteToReadOnlyMap {
+		t.Errorf("reader WriteAt() = %v, want ErrWriteToReadOnlyMap", err)
+	}
+
+	// Shared mappings are coherent without a sync
+	if _, err := writer.WriteAt([]byte("updated"), 0); err != nil {
+		t.Fatalf("writer WriteAt() failed: %v", err)
+	}
+	buf := make([]byte, 7)
+	if _, err := reader.ReadAt(buf, 0); err != nil || string(buf) != "updated" {
+		t.Errorf("reader ReadAt() = %q, %v, want %q", buf, err, "updated")
+	}
+
+	// Closing one view leaves the other working
+	if err := reader.Close(); err != nil {
+		t.Fatalf("reader Close() failed: %v", err)
+	}
+	if _, err := writer.WriteAt([]byte("!"), 15); err != nil {
+		t.Errorf("writer WriteAt() after reader Close() failed: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("writer Close() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data) != "updated content!" {
+		t.Errorf("file = %q, want %q", data, "updated content!")
+	}
+}
+
+// TestReadNext tests zero-copy sequential reads.
+func TestReadNext(t *testing.T) {
+	pageSize := os.Getpagesize()
+	content := strings.Repeat("a", pageSize) + strings.Repeat("b", 10)
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, config := range []*Config{
+		DefaultConfig(),
+		{Mode: ModeReadOnly, WindowSize: int64(pageSize)},
+	} {
+		file, err := New(osFS, config).Open(tmpFile)
+		if err != nil {
+			t.Fatalf("Open() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+
+		var got []byte
+		for {
+			p, err := mf.ReadNext(1000)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("ReadNext() failed: %v", err)
+			}
+			if len(p) == 0 || len(p) > 1000 {
+				t.Fatalf("ReadNext() returned %d bytes, want 1-1000", len(p))
+			}
+			got = append(got, p...)
+		}
+
+		if string(got) != content {
+			t.Errorf("ReadNext() concatenated %d bytes, want the %d byte file", len(got), len(content))
+		}
+
+		// The position is shared with Read
+		if _, err := mf.Seek(int64(pageSize), io.SeekStart); err != nil {
+			t.Fatalf("Seek() failed: %v", err)
+		}
+		if p, err := mf.ReadNext(0); err != nil || string(p) != strings.Repeat("b", 10) {
+			t.Errorf("ReadNext(0) = %q, %v, want the tail", p, err)
+		}
+
+		file.Close()
+	}
+}
+
+// TestCreateSharedMemoryFailIfExists tests refusing to reuse a live region.
+func TestCreateSharedMemoryFailIfExists(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "exclusive.dat")
+	config := &SharedMemoryConfig{Path: sharedPath, Size: 4096, FailIfExists: true}
+
+	sm, err := CreateSharedMemory(config)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer sm.Close()
+	copy(sm.Data(), "live")
+
+	if _, err := CreateSharedMemory(config); !errors.Is(err, os.ErrExist) {
+		t.Errorf("second CreateSharedMemory() = %v, want os.ErrExist", err)
+	}
+	if string(sm.Data()[:4]) != "live" {
+		t.Errorf("region = %q after failed create, want %q", sm.Data()[:4], "live")
+	}
+}
+
+// TestDurableCreate tests directory syncs after create and rename.
+func TestDurableCreate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true, DurableCreate: true})
+
+	created := filepath.Join(tmpDir, "created.dat")
+	file, err := mfs.OpenFile(created, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(O_CREATE) failed: %v", err)
+	}
+	file.Close()
+
+	file, err = mfs.Create(filepath.Join(tmpDir, "other.dat"))
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	file.Close()
+
+	if err := os.Mkdir(filepath.Join(tmpDir, "sub"), 0755); err != nil {
+		t.Fatalf("Mkdir() failed: %v", err)
+	}
+	renamed := filepath.Join(tmpDir, "sub", "renamed.dat")
+	if err := mfs.Rename(created, renamed); err != nil {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+	if _, err := os.Stat(renamed); err != nil {
+		t.Errorf("Stat() of renamed file failed: %v", err)
+	}
+
+	if err := mfs.SyncDir(filepath.Join(tmpDir, "missing", "file")); err == nil && runtime.GOOS != "windows" {
+		t.Error("SyncDir() of a missing directory succeeded")
+	}
+}
+
+// mapperFS opens files that map themselves from an in-memory copy.
+type mapperFS struct {
+	absfs.FileSystem
+}
+
+type mapperFile struct {
+	absfs.File
+	buf    []byte
+	mapped int
+	syncs  int
+}
+
+func (f *mapperFile) Sync() error {
+	f.syncs++
+	return f.File.Sync()
+}
+
+func (m mapperFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	f, err := m.FileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &mapperFile{File: f, buf: buf}, nil
+}
+
+func (f *mapperFile) Mmap(off, length int64, prot, flags int) ([]byte, error) {
+	f.mapped++
+	return f.buf[off : off+length], nil
+}
+
+func (f *mapperFile) Munmap(b []byte) error {
+	f.mapped--
+	return nil
+}
+
+// TestMmapper tests mapping files through a backend's Mmapper.
+func TestMmapper(t *testing.T) {
+	pageSize := os.Getpagesize()
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("a", pageSize)+strings.Repeat("b", pageSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, WindowSize: int64(pageSize)}
+	file, err := New(mapperFS{osFS}, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	mf := file.(*MappedFile)
+	backend := mf.file.(*mapperFile)
+
+	// Windows slide through the Mmapper
+	buf := make([]byte, 1)
+	if _, err := mf.ReadAt(buf, int64(pageSize)); err != nil || buf[0] != 'b' {
+		t.Errorf("ReadAt() in second window = %q, %v, want %q", buf, err, "b")
+	}
+	if _, err := mf.WriteAt([]byte("B"), int64(pageSize)); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if backend.buf[pageSize] != 'B' {
+		t.Errorf("backend byte = %q, want %q", backend.buf[pageSize], 'B')
+	}
+
+	if err := mf.AdviseSequential(); err != nil {
+		t.Errorf("AdviseSequential() failed: %v", err)
+	}
+	if err := mf.Sync(); err != nil {
+		t.Errorf("Sync() failed: %v", err)
+	}
+
+	if err := mf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if backend.mapped != 0 {
+		t.Errorf("%d mappings left after Close()", backend.mapped)
+	}
+}
+
+// TestSeekDataHole tests finding data extents in a sparse file.
+func TestSeekDataHole(t *testing.T) {
+	const extent = 1 << 20
+	tmpFile := filepath.Join(t.TempDir(), "sparse.dat")
+
+	// [hole][data][hole]
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if _, err := f.WriteAt(bytes.Repeat([]byte("d"), extent), extent); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := f.Truncate(3 * extent); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+	f.Close()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, DefaultConfig()).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	data, err := mf.SeekData(0)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("SEEK_DATA not supported on this platform")
+	}
+	if err != nil {
+		t.Fatalf("SeekData() failed: %v", err)
+	}
+	if data == 0 {
+		t.Skip("filesystem does not report holes")
+	}
+	if data != extent {
+		t.Errorf("SeekData(0) = %d, want %d", data, extent)
+	}
+
+	hole, err := mf.SeekHole(data)
+	if err != nil {
+		t.Fatalf("SeekHole() failed: %v", err)
+	}
+	if hole != 2*extent {
+		t.Errorf("SeekHole(%d) = %d, want %d", data, hole, 2*extent)
+	}
+
+	if _, err := mf.SeekData(2 * extent); err != io.EOF {
+		t.Errorf("SeekData() in trailing hole = %v, want io.EOF", err)
+	}
+}
+
+// TestSeekDataHoleConcurrent tests that concurrent SeekData and SeekHole
+// calls leave the shared descriptor offset where it was.
+func TestSeekDataHoleConcurrent(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("d", 64*1024))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, DefaultConfig()).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if _, err := mf.SeekData(0); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("SEEK_DATA not supported on this platform")
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				off := int64((g*2000 + i) % (64 * 1024))
+				if g%2 == 0 {
+					mf.SeekData(off)
+				} else {
+					mf.SeekHole(off)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if pos, err := mf.file.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		t.Errorf("descriptor offset after concurrent queries = %d, %v, want 0", pos, err)
+	}
+}
+
+// TestAllocAligned tests the shared memory bump allocator.
+func TestAllocAligned(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "alloc.dat")
+	sm, err := CreateSharedMemory(&SharedMemoryConfig{Path: sharedPath, Size: 256})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer sm.Close()
+
+	prev := int64(allocHeaderSize)
+	for _, align := range []int64{1, 8, 64, 8} {
+		off, err := sm.AllocAligned(3, align)
+		if err != nil {
+			t.Fatalf("AllocAligned(3, %d) failed: %v", align, err)
+		}
+		if off < prev {
+			t.Errorf("AllocAligned() = %d overlaps previous allocation ending at %d", off, prev)
+		}
+		if addr := uintptr(unsafe.Pointer(&sm.Data()[off])); addr%uintptr(align) != 0 {
+			t.Errorf("AllocAligned(3, %d) address %#x is misaligned", align, addr)
+		}
+		prev = off + 3
+	}
+
+	// Aligned words work with the atomic helpers
+	off, err := sm.AllocAligned(8, 8)
+	if err != nil {
+		t.Fatalf("AllocAligned(8, 8) failed: %v", err)
+	}
+	if _, err := sm.AddUint64(off, 1); err != nil {
+		t.Errorf("AddUint64() at allocated offset failed: %v", err)
+	}
+
+	// A second process attaching sees the same high-water mark
+	other, err := OpenSharedMemory(sharedPath, true)
+	if err != nil {
+		t.Fatalf("OpenSharedMemory() failed: %v", err)
+	}
+	defer other.Close()
+	if mark, err := other.AllocatedBytes(); err != nil || mark != off+8 {
+		t.Errorf("AllocatedBytes() = %d, %v, want %d", mark, err, off+8)
+	}
+
+	if _, err := sm.AllocAligned(256, 8); !errors.Is(err, ErrSharedMemoryFull) {
+		t.Errorf("AllocAligned() past the end = %v, want ErrSharedMemoryFull", err)
+	}
+	if _, err := sm.AllocAligned(8, 3); err == nil {
+		t.Error("AllocAligned() with non-power-of-two alignment succeeded")
+	}
+}
+
+// TestHugePagesUnalignedFallback tests that a huge page mapping of a file
+// whose size isn't a multiple of the huge page size falls back to normal
+// pages and reports why.
+func TestHugePagesUnalignedFallback(t *testing.T) {
+	content := strings.Repeat("h", 2*1024*1024+123)
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	config := &Config{
+		Mode:         ModeReadOnly,
+		SyncMode:     SyncNever,
+		MapFullFile:  true,
+		UseHugePages: true,
+		Logger:       logger,
+	}
+
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	if len(mf.Data()) != len(content) {
+		t.Fatalf("Data() has %d bytes, want %d mapped after huge page fallback", len(mf.Data()), len(content))
+	}
+
+	buf := make([]byte, 4)
+	if _, err := mf.ReadAt(buf, int64(len(content))-4); err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if string(buf) != "hhhh" {
+		t.Errorf("ReadAt() = %q, want %q", buf, "hhhh")
+	}
+
+	// Regular files never accept MAP_HUGETLB, so Linux must report the fallback
+	if runtime.GOOS == "linux" {
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		found := false
+		for _, msg := range logger.messages {
+			if strings.Contains(msg, "huge pages unavailable") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Logger got %q, want a huge page fallback warning", logger.messages)
+		}
+	}
+}
+
+// TestSIGBUSAutoRemap tests that a truncated file is remapped on SIGBUS
+// before handlers run.
+func TestSIGBUSAutoRemap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGBUS does not exist on Windows")
+	}
+
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("s", 8192))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, MapFullFile: true}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	mf := file.(*MappedFile)
+	handler := GetSIGBUSHandler()
+	handler.SetAutoRemap(true)
+	defer handler.SetAutoRemap(false)
+	mf.EnableSIGBUSProtection()
+	defer mf.DisableSIGBUSProtection()
+
+	// Handlers can't be removed, so ignore calls for other files
+	got := make(chan error, 1)
+	handler.OnSIGBUS(func(mappedFile *MappedFile, err error) {
+		if mappedFile != mf {
+			return
+		}
+		select {
+		case got <- err:
+		default:
+		}
+	})
+
+	if err := os.Truncate(tmpFile, 100); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+
+	proc, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("FindProcess() failed: %v", err)
+	}
+	if err := proc.Signal(syscall.SIGBUS); err != nil {
+		t.Fatalf("Signal() failed: %v", err)
+	}
+
+	select {
+	case err := <-got:
+		if err == nil || !strings.Contains(err.Error(), "remapped") {
+			t.Errorf("Handler got %v, want a remapped truncation error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGBUS handler was not called")
+	}
+
+	if len(mf.Data()) != 100 {
+		t.Errorf("Data() has %d bytes after auto remap, want 100", len(mf.Data()))
+	}
+}
+
+// TestReadDirFile tests reading a directory through fs.ReadDirFile.
+func TestReadDirFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		fileName := filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(fileName, []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, DefaultConfig()).Open(tmpDir)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+
+	// Directories aren't mapped; whatever comes back must still be an fs.ReadDirFile
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		t.Fatalf("Open() returned %T, which is not an fs.ReadDirFile", file)
+	}
+
+	// Read in batches to check n > 0 ends with io.EOF
+	var names []string
+	for {
+		entries, err := dir.ReadDir(2)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				t.Errorf("Entry %q reported as a directory", entry.Name())
+			}
+			names = append(names, entry.Name())
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadDir() failed: %v", err)
+		}
+	}
+
+	if len(names) != 3 {
+		t.Errorf("ReadDir() returned %q, want 3 entries", names)
+	}
+}
+
+// TestTruncationIsEOF tests that reads stop at the current file size after
+// another writer truncates the file.
+func TestTruncationIsEOF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows refuses to truncate mapped files")
+	}
+
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("t", 3*4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, MapFullFile: true, TruncationIsEOF: true}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if err := os.Truncate(tmpFile, 100); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+
+	// Without the cap these would touch unbacked pages and fault
+	buf := make([]byte, 4096)
+	if _, err := mf.ReadAt(buf, 8192); err != io.EOF {
+		t.Errorf("ReadAt() past new size error = %v, want io.EOF", err)
+	}
+
+	n, err := mf.ReadAt(buf, 0)
+	if n != 100 || err != io.EOF {
+		t.Errorf("ReadAt() = (%d, %v), want (100, io.EOF)", n, err)
+	}
+
+	all, err := mf.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if len(all) != 100 {
+		t.Errorf("ReadAll() returned %d bytes, want 100", len(all))
+	}
+
+	if _, err := mf.ReadAtv([][]byte{buf}, 4096); err != io.EOF {
+		t.Errorf("ReadAtv() past new size error = %v, want io.EOF", err)
+	}
+
+	p, err := mf.ReadNext(0)
+	if err != nil {
+		t.Fatalf("ReadNext() failed: %v", err)
+	}
+	if len(p) != 100 {
+		t.Errorf("ReadNext() returned %d bytes, want 100", len(p))
+	}
+	if _, err := mf.Read(buf); err != io.EOF {
+		t.Errorf("Read() at new size error = %v, want io.EOF", err)
+	}
+}
+
+// TestAdviseCollapse tests collapsing the mapping, or part of it, into
+// huge pages.
+func TestAdviseCollapse(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("c", 4*1024*1024))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, MapFullFile: true}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Needs Linux 6.1+ and THP support, so failures are only logged
+	if err := mf.AdviseCollapse(); err != nil {
+		t.Logf("AdviseCollapse() failed (normal without MADV_COLLAPSE support): %v", err)
+	}
+	if err := mf.AdviseCollapseRange(100, 2*1024*1024); err != nil {
+		t.Logf("AdviseCollapseRange() failed (normal without MADV_COLLAPSE support): %v", err)
+	}
+
+	// Ranges outside the mapping are rejected on every platform
+	for _, r := range [][2]int64{{-1, 10}, {0, 0}, {4 * 1024 * 1024, 1}, {4*1024*1024 - 10, 11}} {
+		if err := mf.AdviseCollapseRange(r[0], r[1]); err != ErrInvalidOffset {
+			t.Errorf("AdviseCollapseRange(%d, %d) error = %v, want ErrInvalidOffset", r[0], r[1], err)
+		}
+	}
+}
+
+// TestWindowedConcurrencyStress hammers a windowed file from several
+// goroutines so that -race sees every path that reads or moves the window.
+func TestWindowedConcurrencyStress(t *testing.T) {
+	const windowSize = 64 * 1024
+	const fileSize = 16 * windowSize
+
+	content := make([]byte, fileSize)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, WindowSize: windowSize, AdaptiveWindow: true}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	iterations := 300
+	if testing.Short() {
+		iterations = 50
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	run := func(name string, fn func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := fn(i); err != nil {
+					errs <- fmt.Errorf("%s: %w", name, err)
+					return
+				}
+			}
+		}()
+	}
+
+	run("ReadAt", func(i int) error {
+		buf := make([]byte, 1024)
+		off := int64(i*7919*13) % (fileSize - int64(len(buf)))
+		n, err := mf.ReadAt(buf, off)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for j := 0; j < n; j++ {
+			if buf[j] != byte((off+int64(j))%251) {
+				return fmt.Errorf("byte at %d is %d, want %d", off+int64(j), buf[j], byte((off+int64(j))%251))
+			}
+		}
+		return nil
+	})
+	// Two readers share the file position, so Read must serialize them.
+	// They run alone first, where no other goroutine's write lock orders
+	// their accesses and hides a race from the detector, then in the mix.
+	readers := func() {
+		for _, name := range []string{"Read", "Read2"} {
+			run(name, func(i int) error {
+				buf := make([]byte, 3000)
+				if _, err := mf.Read(buf); err == io.EOF {
+					_, err = mf.Seek(0, io.SeekStart)
+					return err
+				} else {
+					return err
+				}
+			})
+		}
+	}
+	readers()
+	wg.Wait()
+	readers()
+	run("WriteAtv", func(i int) error {
+		// Rewrite bytes with their own values so readers can still check them
+		off := int64(i*104729) % (fileSize - 512)
+		_, err := mf.WriteAtv([][]byte{content[off : off+512]}, off)
+		return err
+	})
+	run("ReadAll", func(i int) error {
+		if i%20 != 0 {
+			return nil
+		}
+		all, err := mf.ReadAll()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(all, content) {
+			return fmt.Errorf("contents differ")
+		}
+		return nil
+	})
+	run("Advise", func(i int) error {
+		if err := mf.AdviseWillNeed(); err != nil {
+			return err
+		}
+		// The window may slide between Stats and the advice; that, or missing
+		// MADV_COLLAPSE support, is not what this test is after
+		_ = mf.AdviseCollapseRange(mf.Stats().WindowOffset, 4096)
+		return nil
+	})
+	run("Sync", func(i int) error {
+		return mf.Sync()
+	})
+	run("Inspect", func(i int) error {
+		_ = mf.Data()
+		_ = mf.Addr()
+		_ = mf.Size()
+		_ = mf.Stats()
+		return nil
+	})
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// TestReopen tests picking up a file that was replaced by a rename.
+func TestReopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows cannot rename over a mapped file")
+	}
+
+	tmpFile, cleanup := createTestFile(t, "old contents")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, MapFullFile: true}
+	mfs := New(osFS, config)
+	file, err := mfs.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	replacement := tmpFile + ".new"
+	if err := os.WriteFile(replacement, []byte("brand new contents"), 0644); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if err := os.Rename(replacement, tmpFile); err != nil {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+
+	// The mapping still shows the old inode until reopened
+	if got := string(mf.Data()); got != "old contents" {
+		t.Errorf("Data() before Reopen = %q, want %q", got, "old contents")
+	}
+
+	if err := mf.Reopen(); err != nil {
+		t.Fatalf("Reopen() failed: %v", err)
+	}
+
+	if got := string(mf.Data()); got != "brand new contents" {
+		t.Errorf("Data() after Reopen = %q, want %q", got, "brand new contents")
+	}
+	if mf.Size() != int64(len("brand new contents")) {
+		t.Errorf("Size() = %d, want %d", mf.Size(), len("brand new contents"))
+	}
+
+	// Remote files have no path to reopen
+	remote, err := mfs.OpenRemote("remote", &memoryBackend{data: []byte("remote")})
+	if err != nil {
+		t.Fatalf("OpenRemote() failed: %v", err)
+	}
+	defer remote.Close()
+	if err := remote.Reopen(); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("Reopen() on remote file error = %v, want errors.ErrUnsupported", err)
+	}
+	// A closed file stays closed
+	if err := mf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := mf.Reopen(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Reopen() after Close error = %v, want os.ErrClosed", err)
+	}
+}
+
+// TestPreloadSync tests that PreloadSync leaves the whole mapping resident.
+func TestPreloadSync(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("p", 1024*1024+17))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{
+		Mode:           ModeReadOnly,
+		SyncMode:       SyncNever,
+		MapFullFile:    true,
+		PreloadSync:    true,
+		PreloadTimeout: 10 * time.Second,
+	}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	pageSize := os.Getpagesize()
+	vec := make([]byte, (len(mf.mmapData)+pageSize-1)/pageSize)
+	if err := mincore(mf.mmapData, vec); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("mincore not available on this platform")
+	} else if err != nil {
+		t.Fatalf("mincore() failed: %v", err)
+	}
+
+	for i, r := range vec {
+		if r&1 == 0 {
+			t.Fatalf("Page %d of %d not resident after PreloadSync", i, len(vec))
+		}
+	}
+}
+
+// TestFreeze tests downgrading a read-write mapping to read-only.
+func TestFreeze(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat(".", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, MapFullFile: true}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if _, err := mf.WriteAt([]byte("frozen"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	if err := mf.Freeze(); err != nil {
+		t.Fatalf("Freeze() failed: %v", err)
+	}
+
+	if _, err := mf.WriteAt([]byte("thawed"), 0); err != ErrWriteToReadOnlyMap {
+		t.Errorf("WriteAt() after Freeze error = %v, want ErrWriteToReadOnlyMap", err)
+	}
+	if config.Mode != ModeReadWrite {
+		t.Errorf("Freeze() changed the shared config mode to %v", config.Mode)
+	}
+
+	// The pending write was synced before freezing
+	onDisk, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if !bytes.HasPrefix(onDisk, []byte("frozen")) {
+		t.Errorf("File starts with %q, want %q", onDisk[:6], "frozen")
+	}
+
+	// Writing through Data now faults
+	faulted := func() (faulted bool) {
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer func() { faulted = recover() != nil }()
+		mf.Data()[0] = 'x'
+		return false
+	}()
+	if !faulted {
+		t.Error("Write through Data() after Freeze did not fault")
+	}
+}
+
+// TestEvictWindow tests unmapping only the window covering an offset.
+func TestEvictWindow(t *testing.T) {
+	const windowSize = 64 * 1024
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("e", 4*windowSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, WindowSize: windowSize}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	for _, off := range []int64{-1, 4 * windowSize} {
+		if err := mf.EvictWindow(off); err != ErrInvalidOffset {
+			t.Errorf("EvictWindow(%d) error = %v, want ErrInvalidOffset", off, err)
+		}
+	}
+
+	// A window that isn't mapped is left alone
+	if err := mf.EvictWindow(3 * windowSize); err != nil {
+		t.Fatalf("EvictWindow() failed: %v", err)
+	}
+	if mf.Data() == nil {
+		t.Fatal("EvictWindow() of an unmapped window unmapped the current one")
+	}
+
+	if err := mf.EvictWindow(10); err != nil {
+		t.Fatalf("EvictWindow() failed: %v", err)
+	}
+	if mf.Data() != nil {
+		t.Fatal("EvictWindow() of the current window left it mapped")
+	}
+
+	// The next access maps a window again
+	buf := make([]byte, 4)
+	if _, err := mf.ReadAt(buf, 2*windowSize); err != nil {
+		t.Fatalf("ReadAt() after EvictWindow failed: %v", err)
+	}
+	if string(buf) != "eeee" {
+		t.Errorf("ReadAt() = %q, want %q", buf, "eeee")
+	}
+}
+
+// TestNoSyncOnClose tests that Close skips the sync when configured to.
+func TestNoSyncOnClose(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat(".", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	// Mmapper files sync through the file, so the syncs can be counted
+	for _, noSync := range []bool{false, true} {
+		config := &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, MapFullFile: true, NoSyncOnClose: noSync}
+		file, err := New(mapperFS{osFS}, config).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+		backing := mf.file.(*mapperFile)
+
+		if _, err := mf.WriteAt([]byte("scratch"), 0); err != nil {
+			t.Fatalf("WriteAt() failed: %v", err)
+		}
+		if err := mf.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+
+		want := 1
+		if noSync {
+			want = 0
+		}
+		if backing.syncs != want {
+			t.Errorf("NoSyncOnClose=%v: Close() synced %d times, want %d", noSync, backing.syncs, want)
+		}
+	}
+}
+
+// TestSharedMemoryWithLock tests that WithLock excludes other openers of
+// the file and releases the lock on panic.
+func TestSharedMemoryWithLock(t *testing.T) {
+	sharedPath := filepath.Join(t.TempDir(), "locked.dat")
+	creator, err := CreateSharedMemory(&SharedMemoryConfig{Path: sharedPath, Size: 4096})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer creator.Close()
+
+	// A second opener has its own descriptor, like another process would
+	joiner, err := OpenSharedMemory(sharedPath, true)
+	if err != nil {
+		t.Fatalf("OpenSharedMemory() failed: %v", err)
+	}
+	defer joiner.Close()
+
+	const rounds = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for _, sm := range []*SharedMemory{creator, joiner, creator, joiner} {
+		wg.Add(1)
+		go func(sm *SharedMemory) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				// A plain read-modify-write only adds up if the lock holds
+				err := sm.WithLock(func(data []byte) error {
+					n := binary.LittleEndian.Uint32(data)
+					runtime.Gosched()
+					binary.LittleEndian.PutUint32(data, n+1)
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(sm)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("WithLock() failed: %v", err)
+	}
+
+	if got := binary.LittleEndian.Uint32(creator.Data()); got != 4*rounds {
+		t.Errorf("Counter = %d, want %d", got, 4*rounds)
+	}
+
+	func() {
+		defer func() { recover() }()
+		creator.WithLock(func(data []byte) error { panic("boom") })
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- joiner.WithLock(func([]byte) error { return nil }) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WithLock() after panic failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lock was not released after a panic")
+	}
+}
+
+// TestReadAtZeroCopy tests reading a slice that aliases the mapping.
+func TestReadAtZeroCopy(t *testing.T) {
+	const windowSize = 64 * 1024
+	content := make([]byte, 2*windowSize+100)
+	for i := range content {
+		content[i] = byte(i % 253)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	full, err := New(osFS, &Config{Mode: ModeReadOnly, SyncMode: SyncNever, MapFullFile: true}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer full.Close()
+	mf := full.(*MappedFile)
+
+	p, err := mf.ReadAtZeroCopy(1000, windowSize)
+	if err != nil {
+		t.Fatalf("ReadAtZeroCopy() failed: %v", err)
+	}
+	if &p[0] != &mf.Data()[1000] {
+		t.Error("ReadAtZeroCopy() returned a copy, want an alias of the mapping")
+	}
+	if !bytes.Equal(p, content[1000:1000+windowSize]) {
+		t.Error("ReadAtZeroCopy() returned the wrong bytes")
+	}
+
+	// Past the end the available bytes come with io.EOF
+	p, err = mf.ReadAtZeroCopy(int64(len(content))-10, 100)
+	if err != io.EOF || len(p) != 10 {
+		t.Errorf("ReadAtZeroCopy() at end = (%d bytes, %v), want (10 bytes, io.EOF)", len(p), err)
+	}
+	if _, err := mf.ReadAtZeroCopy(-1, 1); err != ErrInvalidOffset {
+		t.Errorf("ReadAtZeroCopy(-1) error = %v, want ErrInvalidOffset", err)
+	}
+
+	windowed, err := New(osFS, &Config{Mode: ModeReadOnly, SyncMode: SyncNever, WindowSize: windowSize}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer windowed.Close()
+	wf := windowed.(*MappedFile)
+
+	p, err = wf.ReadAtZeroCopy(windowSize+5, 10)
+	if err != nil {
+		t.Fatalf("ReadAtZeroCopy() in second window failed: %v", err)
+	}
+	if !bytes.Equal(p, content[windowSize+5:windowSize+15]) {
+		t.Error("ReadAtZeroCopy() in second window returned the wrong bytes")
+	}
+
+	if _, err := wf.ReadAtZeroCopy(windowSize-5, 10); !errors.Is(err, ErrCrossesWindow) {
+		t.Errorf("ReadAtZeroCopy() across windows error = %v, want ErrCrossesWindow", err)
+	}
+}
+
+// TestCloseAll tests that CloseAll syncs and closes every file still open
+// and stops the periodic sync manager.
+func TestCloseAll(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 4096))
+	defer cleanup()
+	otherFile, cleanupOther := createTestFile(t, strings.Repeat("y", 4096))
+	defer cleanupOther()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{
+		Mode:         ModeReadWrite,
+		SyncMode:     SyncPeriodic,
+		SyncInterval: time.Hour,
+		MapFullFile:  true,
+	})
+
+	first, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	second, err := mfs.OpenFile(otherFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	closed, err := mfs.OpenFile(otherFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	if err := closed.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if _, err := first.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := second.WriteAt([]byte("world"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	if err := mfs.CloseAll(); err != nil {
+		t.Fatalf("CloseAll() failed: %v", err)
+	}
+
+	for _, mf := range []*MappedFile{first.(*MappedFile), second.(*MappedFile)} {
+		if mf.Data() != nil {
+			t.Errorf("%s still mapped after CloseAll()", mf.Name())
+		}
+	}
+	if !mfs.syncManager.stopped {
+		t.Error("sync manager still running after CloseAll()")
+	}
+
+	for name, want := range map[string]string{tmpFile: "hello", otherFile: "world"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile() failed: %v", err)
+		}
+		if string(data[:5]) != want {
+			t.Errorf("%s starts with %q after CloseAll(), want %q", name, data[:5], want)
+		}
+	}
+
+	// Nothing is left to close
+	if err := mfs.CloseAll(); err != nil {
+		t.Errorf("second CloseAll() failed: %v", err)
+	}
+}
+
+// TestReadByte tests byte-at-a-time reading with ReadByte and UnreadByte,
+// including across a window boundary.
+func TestReadByte(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	content := make([]byte, 2*windowSize+10)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadOnly, SyncMode: SyncNever, WindowSize: windowSize}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if err := mf.UnreadByte(); err != ErrInvalidOffset {
+		t.Errorf("UnreadByte() at start error = %v, want ErrInvalidOffset", err)
+	}
+
+	var got []byte
+	for {
+		b, err := mf.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadByte() failed: %v", err)
+		}
+		got = append(got, b)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("ReadByte() returned the wrong bytes")
+	}
+
+	// Step back over the window boundary and read again
+	if _, err := mf.Seek(windowSize, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if err := mf.UnreadByte(); err != nil {
+		t.Fatalf("UnreadByte() failed: %v", err)
+	}
+	b, err := mf.ReadByte()
+	if err != nil {
+		t.Fatalf("ReadByte() failed: %v", err)
+	}
+	if b != content[windowSize-1] {
+		t.Errorf("ReadByte() after UnreadByte() = %d, want %d", b, content[windowSize-1])
+	}
+}
+
+// TestReadAheadWindows tests that sequential reads through a windowed file
+// with ReadAheadWindows set report the depth and read ahead without errors.
+func TestReadAheadWindows(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	content := bytes.Repeat([]byte("readahead"), int(8*windowSize/9))
+	tmpFile, cleanup := createTestFile(t, string(content))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	logger := &recordingLogger{}
+	config := &Config{Mode: ModeReadOnly, SyncMode: SyncNever, WindowSize: windowSize, ReadAheadWindows: 3, Logger: logger}
+	file, err := New(osFS, config).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	want := 0
+	if runtime.GOOS == "linux" {
+		want = 3
+	}
+	if got := mf.Stats().ReadAheadWindows; got != want {
+		t.Errorf("Stats().ReadAheadWindows = %d, want %d", got, want)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Error("ReadAll() returned the wrong bytes")
+	}
+	if len(logger.messages) != 0 {
+		t.Errorf("Logger got %q, want no warnings", logger.messages)
+	}
+
+	// Full-file mappings have no windows to read ahead
+	full, err := New(osFS, &Config{Mode: ModeReadOnly, MapFullFile: true, ReadAheadWindows: 3}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer full.Close()
+	if got := full.(*MappedFile).Stats().ReadAheadWindows; got != 0 {
+		t.Errorf("Stats().ReadAheadWindows for full mapping = %d, want 0", got)
+	}
+}
+
+// TestTruncateGrowWindowed tests that growing a windowed file while the
+// position is in its last, partial window extends that window, so writes
+// into the new tail succeed.
+func TestTruncateGrowWindowed(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	oldSize := windowSize + windowSize/2
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", int(oldSize)))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, WindowSize: windowSize}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Move into the partial second window
+	if _, err := mf.Seek(windowSize+1, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if _, err := mf.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+
+	if err := mf.Truncate(oldSize + windowSize); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+
+	if got := len(mf.Data()); int64(got) != windowSize {
+		t.Errorf("window length after grow = %d, want %d", got, windowSize)
+	}
+	slides := mf.Stats().WindowSlides
+
+	tail := []byte(strings.Repeat("t", int(windowSize/4)))
+	if _, err := mf.WriteAt(tail, oldSize); err != nil {
+		t.Fatalf("WriteAt() at old size failed: %v", err)
+	}
+	if got := mf.Stats().WindowSlides; got != slides {
+		t.Errorf("WriteAt() at old size slid the window (%d slides, want %d)", got, slides)
+	}
+
+	if err := mf.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if int64(len(data)) != oldSize+windowSize || !bytes.Equal(data[oldSize:oldSize+int64(len(tail))], tail) {
+		t.Error("file does not hold the write into the grown tail")
+	}
+}
+
+// TestHint tests that every CacheHint succeeds on a mapped file on every
+// platform, and that unmapped files and unknown hints are reported.
+func TestHint(t *testing.T) {
+	content := strings.Repeat("hint", 4096)
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, mode := range []MappingMode{ModeReadOnly, ModeReadWrite} {
+		file, err := New(osFS, &Config{Mode: mode, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		mf := file.(*MappedFile)
+
+		for _, h := range []CacheHint{HintSequential, HintRandom, HintWillNeed, HintDontNeed, HintFree} {
+			if err := mf.Hint(h); err != nil {
+				t.Errorf("Hint(%v) in mode %d failed: %v", h, mode, err)
+			}
+		}
+
+		// Hints never change shared file contents
+		if got := string(mf.Data()); got != content {
+			t.Errorf("contents changed after hints in mode %d", mode)
+		}
+
+		if err := mf.Hint(CacheHint(99)); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("Hint(CacheHint(99)) error = %v, want ErrUnsupported", err)
+		}
+
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+		if err := mf.Hint(HintWillNeed); err != ErrNotMapped {
+			t.Errorf("Hint() after Close error = %v, want ErrNotMapped", err)
+		}
+	}
+}
+
+// TestOpenReadSeeker tests that OpenReadSeeker reads and seeks over a
+// mapping without exposing any way to write to it.
+func TestOpenReadSeeker(t *testing.T) {
+	content := "0123456789abcdef"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true})
+	rs, closer, err := mfs.OpenReadSeeker(tmpFile)
+	if err != nil {
+		t.Fatalf("OpenReadSeeker() failed: %v", err)
+	}
+	defer closer.Close()
+
+	if _, ok := rs.(io.Writer); ok {
+		t.Error("OpenReadSeeker() result implements io.Writer")
+	}
+	if _, ok := rs.(io.WriterAt); ok {
+		t.Error("OpenReadSeeker() result implements io.WriterAt")
+	}
+	if _, ok := rs.(*MappedFile); ok {
+		t.Error("OpenReadSeeker() returned the MappedFile itself")
+	}
+
+	if _, err := rs.Seek(10, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(rest) != content[10:] {
+		t.Errorf("read %q after Seek, want %q", rest, content[10:])
+	}
+
+	// The mapping behind it is read-only even though mfs is read-write
+	if mf, ok := closer.(*MappedFile); !ok || mf.config.Mode != ModeReadOnly {
+		t.Error("OpenReadSeeker() did not use a read-only mapping")
+	}
+
+	if _, _, err := mfs.OpenReadSeeker(filepath.Dir(tmpFile)); err == nil {
+		t.Error("OpenReadSeeker() on a directory succeeded, want error")
+	}
+}
+
+// TestOpenReadSeekerDecompressor tests that OpenReadSeeker applies the
+// filesystem's Decompressor.
+func TestOpenReadSeekerDecompressor(t *testing.T) {
+	plain := strings.Repeat("compressible text ", 1000)
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(plain))
+	zw.Close()
+
+	tmpFile, cleanup := createTestFile(t, compressed.String())
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{
+		Mode:        ModeReadOnly,
+		MapFullFile: true,
+		Decompressor: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	rs, closer, err := New(osFS, config).OpenReadSeeker(tmpFile)
+	if err != nil {
+		t.Fatalf("OpenReadSeeker() failed: %v", err)
+	}
+	defer closer.Close()
+
+	if _, err := rs.Seek(5, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	got, err := io.ReadAll(rs)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if string(got) != plain[5:] {
+		t.Errorf("read %d bytes after Seek, want the %d decompressed", len(got), len(plain)-5)
+	}
+}
+
+// TestCreateStreamWriter tests that a StreamWriter grows the file in chunks
+// across windows and truncates it to the written length on Close.
+func TestCreateStreamWriter(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	path := filepath.Join(t.TempDir(), "stream.dat")
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{SyncMode: SyncNever, WindowSize: windowSize})
+	chunk := 3 * windowSize
+	sw, err := mfs.CreateStreamWriter(path, chunk)
+	if err != nil {
+		t.Fatalf("CreateStreamWriter() failed: %v", err)
+	}
+
+	var want bytes.Buffer
+	line := []byte("a line of exported log output that does not divide the window size\n")
+	for int64(want.Len()) < 4*chunk+100 {
+		if _, err := sw.Write(line); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		want.Write(line)
+	}
+
+	if sw.Len() != int64(want.Len()) {
+		t.Errorf("Len() = %d, want %d", sw.Len(), want.Len())
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size()%chunk != 0 || fi.Size() < sw.Len() {
+		t.Errorf("file size before Close = %v (err %v), want a multiple of %d covering %d", fi.Size(), err, chunk, sw.Len())
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("file has %d bytes after Close, want the %d written", len(got), want.Len())
+	}
+
+	if _, err := sw.Write(line); err != os.ErrClosed {
+		t.Errorf("Write() after Close error = %v, want os.ErrClosed", err)
+	}
+}
+
+// TestStreamWriterChunkBoundary tests writes that end exactly on a chunk
+// boundary, followed by an empty write and a write into the next chunk.
+func TestStreamWriterChunkBoundary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.dat")
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	const chunk = 4096
+	sw, err := New(osFS, &Config{SyncMode: SyncNever, MapFullFile: true}).CreateStreamWriter(path, chunk)
+	if err != nil {
+		t.Fatalf("CreateStreamWriter() failed: %v", err)
+	}
+
+	first := bytes.Repeat([]byte("a"), chunk)
+	if n, err := sw.Write(first); n != chunk || err != nil {
+		t.Fatalf("Write() = %d, %v, want %d, nil", n, err, chunk)
+	}
+	if n, err := sw.Write(nil); n != 0 || err != nil {
+		t.Errorf("Write(nil) at chunk boundary = %d, %v, want 0, nil", n, err)
+	}
+	if n, err := sw.Write([]byte("b")); n != 1 || err != nil {
+		t.Fatalf("Write() past chunk boundary = %d, %v, want 1, nil", n, err)
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if want := append(first, 'b'); !bytes.Equal(got, want) {
+		t.Errorf("file has %d bytes after Close, want %d", len(got), len(want))
+	}
+}
+
+// TestPeriodicSyncSkipsClosedFiles tests that the sync manager skips a file
+// closed after it was captured for syncing, and that periodic syncs racing
+// with Close report nothing.
+func TestPeriodicSyncSkipsClosedFiles(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var syncErrs []error
+	config := &Config{
+		Mode:         ModeReadWrite,
+		SyncMode:     SyncPeriodic,
+		SyncInterval: time.Millisecond,
+		MapFullFile:  true,
+		OnSyncError: func(mf *MappedFile, err error) {
+			mu.Lock()
+			syncErrs = append(syncErrs, err)
+			mu.Unlock()
+		},
+	}
+	mfs := New(osFS, config)
+	defer mfs.syncManager.stop()
+
+	// A file captured before Close, as syncAll's snapshot would hold it
+	file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	if _, err := file.WriteAt([]byte("a"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	mfs.syncManager.syncFile(file.(*MappedFile))
+
+	for i := 0; i < 200; i++ {
+		file, err := mfs.OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		if _, err := file.WriteAt([]byte("b"), int64(i)); err != nil {
+			t.Fatalf("WriteAt() failed: %v", err)
+		}
+		if err := file.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(syncErrs) != 0 {
+		t.Errorf("periodic sync reported %d errors, first %v", len(syncErrs), syncErrs[0])
+	}
+}
+
+// TestIsDirty tests IsDirty and DirtyBytes across writes and Sync.
+func TestIsDirty(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("x", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, SyncMode: SyncNever, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	if mf.IsDirty() || mf.DirtyBytes() != 0 {
+		t.Errorf("fresh file: IsDirty() = %v, DirtyBytes() = %d, want clean", mf.IsDirty(), mf.DirtyBytes())
+	}
+
+	if _, err := mf.WriteAt([]byte("hello"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := mf.WriteAt([]byte("abc"), 100); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if !mf.IsDirty() || mf.DirtyBytes() != 8 {
+		t.Errorf("after writes: IsDirty() = %v, DirtyBytes() = %d, want dirty with 8 bytes", mf.IsDirty(), mf.DirtyBytes())
+	}
+
+	if err := mf.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	if mf.IsDirty() || mf.DirtyBytes() != 0 {
+		t.Errorf("after Sync: IsDirty() = %v, DirtyBytes() = %d, want clean", mf.IsDirty(), mf.DirtyBytes())
+	}
+
+	// Atomic stores count as writes too
+	if err := mf.StoreUint64(0, 42); err != nil {
+		t.Fatalf("StoreUint64() failed: %v", err)
+	}
+	if mf.DirtyBytes() != 8 {
+		t.Errorf("after StoreUint64: DirtyBytes() = %d, want 8", mf.DirtyBytes())
+	}
+}
+
+// TestVerifyChecksums tests that a window changed by another writer while
+// unmapped is reported with ErrChecksumMismatch when it is mapped again, on
+// every access until it matches again.
+func TestVerifyChecksums(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("c", int(2*windowSize)))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, WindowSize: windowSize, VerifyChecksums: true}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, 1)
+
+	// Our own writes are part of the recorded checksum
+	if _, err := file.WriteAt([]byte("own"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := file.ReadAt(buf, windowSize); err != nil {
+		t.Fatalf("ReadAt() second window failed: %v", err)
+	}
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Fatalf("ReadAt() back in first window failed: %v", err)
+	}
+	if _, err := file.ReadAt(buf, windowSize); err != nil {
+		t.Fatalf("ReadAt() second window failed: %v", err)
+	}
+
+	// Change the first window behind the mapping's back
+	other, err := os.OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.WriteAt([]byte("X"), 10); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := file.ReadAt(buf, 10); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("ReadAt() #%d of changed window = %q, %v, want ErrChecksumMismatch", i+1, buf, err)
+		}
+	}
+	if _, err := file.Read(buf); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Read() of changed window error = %v, want ErrChecksumMismatch", err)
+	}
+
+	// Other windows are unaffected, and the changed one is checked again
+	if _, err := file.ReadAt(buf, windowSize); err != nil {
+		t.Fatalf("ReadAt() second window failed: %v", err)
+	}
+	if _, err := file.ReadAt(buf, 10); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("ReadAt() of changed window after sliding error = %v, want ErrChecksumMismatch", err)
+	}
+
+	// Once the change is undone the window verifies again
+	if _, err := other.WriteAt([]byte("c"), 10); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := file.ReadAt(buf, 10); err != nil || buf[0] != 'c' {
+		t.Errorf("ReadAt() of restored window = %q, %v, want %q", buf, err, "c")
+	}
+}
+
+// mappingDirtyKB returns the dirty kilobytes that /proc/self/smaps reports
+// for the mapping starting at addr, and false where smaps is unavailable.
+func mappingDirtyKB(addr uintptr) (int64, bool) {
+	smaps, err := os.ReadFile("/proc/self/smaps")
+	if err != nil {
+		return 0, false
+	}
+
+	var dirty int64
+	found := false
+	for _, line := range strings.Split(string(smaps), "\n") {
+		var start, end uintptr
+		if n, _ := fmt.Sscanf(line, "%x-%x", &start, &end); n == 2 {
+			if found {
+				break
+			}
+			found = start == addr
+			continue
+		}
+
+		var kb int64
+		if !found {
+			continue
+		}
+		if _, err := fmt.Sscanf(line, "Shared_Dirty: %d kB", &kb); err == nil {
+			dirty += kb
+		} else if _, err := fmt.Sscanf(line, "Private_Dirty: %d kB", &kb); err == nil {
+			dirty += kb
+		}
+	}
+	return dirty, found
+}
+
+// TestDurableWriteAt tests that DurableWriteAt syncs regardless of the
+// sync mode and reports the bytes written as durable.
+func TestDurableWriteAt(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("d", 4096))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Where smaps shows dirty pages and msync cleans them (not on tmpfs),
+	// check that the page written was really written back
+	addr := uintptr(unsafe.Pointer(&mf.mmapData[0]))
+	checkClean := false
+	if _, err := mf.WriteAt([]byte("w"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if kb, ok := mappingDirtyKB(addr); ok && kb > 0 {
+		if err := mf.Flush(0, 1); err != nil {
+			t.Fatalf("Flush() failed: %v", err)
+		}
+		kb, _ = mappingDirtyKB(addr)
+		checkClean = kb == 0
+	}
+	if err := mf.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	n, err := mf.DurableWriteAt([]byte("durable"), 100)
+	if err != nil || n != 7 {
+		t.Fatalf("DurableWriteAt() = (%d, %v), want (7, nil)", n, err)
+	}
+	if mf.IsDirty() {
+		t.Error("IsDirty() after DurableWriteAt() = true, want false")
+	}
+	if kb, _ := mappingDirtyKB(addr); checkClean && kb != 0 {
+		t.Errorf("mapping has %d kB dirty after DurableWriteAt(), want 0", kb)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data[100:107]) != "durable" {
+		t.Errorf("file holds %q at 100, want %q", data[100:107], "durable")
+	}
+
+	if _, err := mf.DurableWriteAt([]byte("x"), 5000); err != ErrInvalidOffset {
+		t.Errorf("DurableWriteAt() past EOF error = %v, want ErrInvalidOffset", err)
+	}
+
+	cow, err := New(osFS, &Config{Mode: ModeCopyOnWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer cow.Close()
+	if _, err := cow.(*MappedFile).DurableWriteAt([]byte("x"), 0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("DurableWriteAt() on copy-on-write error = %v, want ErrUnsupported", err)
+	}
+}
+
+// readOnlyMountFS fails every open for writing with EROFS, like a
+// read-only mount.
+type readOnlyMountFS struct {
+	absfs.FileSystem
+}
+
+func (m readOnlyMountFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EROFS}
+	}
+	return m.FileSystem.OpenFile(name, flag, perm)
+}
+
+// TestDowngradeOnReadOnlyFS tests that opens for writing on a read-only
+// mount fail with ErrReadOnlyFS, or are mapped read-only with
+// DowngradeOnReadOnlyFS.
+func TestDowngradeOnReadOnlyFS(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "read-only mount")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+	roFS := readOnlyMountFS{osFS}
+
+	_, err = New(roFS, &Config{Mode: ModeReadWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if !errors.Is(err, ErrReadOnlyFS) || !errors.Is(err, syscall.EROFS) {
+		t.Errorf("OpenFile(O_RDWR) error = %v, want ErrReadOnlyFS wrapping EROFS", err)
+	}
+
+	logger := &recordingLogger{}
+	config := &Config{Mode: ModeReadWrite, MapFullFile: true, DowngradeOnReadOnlyFS: true, Logger: logger}
+	file, err := New(roFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() with DowngradeOnReadOnlyFS failed: %v", err)
+	}
+	defer file.Close()
+
+	mf, ok := file.(*MappedFile)
+	if !ok {
+		t.Fatalf("OpenFile() returned %T, want *MappedFile", file)
+	}
+	if mf.config.Mode != ModeReadOnly || config.Mode != ModeReadWrite {
+		t.Errorf("mode = %v (config %v), want a read-only mapping without changing the config", mf.config.Mode, config.Mode)
+	}
+	if string(mf.Data()) != "read-only mount" {
+		t.Errorf("Data() = %q, want file contents", mf.Data())
+	}
+	if _, err := mf.WriteAt([]byte("x"), 0); err != ErrWriteToReadOnlyMap {
+		t.Errorf("WriteAt() error = %v, want ErrWriteToReadOnlyMap", err)
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "read-only file system") {
+		t.Errorf("Logger got %q, want one read-only file system warning", logger.messages)
+	}
+}
+
+// TestDump tests that Dump reflects the mapping state through a window
+// slide and Close.
+func TestDump(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("d", int(2*windowSize)))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, WindowSize: windowSize}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	mf := file.(*MappedFile)
+
+	if _, err := mf.Seek(windowSize+3, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if _, err := mf.Write([]byte("ab")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	st := mf.Dump()
+	if st.Name != tmpFile || st.Size != 2*windowSize || st.Position != windowSize+5 {
+		t.Errorf("Dump() = %+v, want name, size and position of the file", st)
+	}
+	if st.WindowSize != windowSize || st.WindowOffset != windowSize || st.DataLen != int(windowSize) || st.MmapLen < st.DataLen {
+		t.Errorf("Dump() = %+v, want the second window mapped", st)
+	}
+	if !st.Modified || st.Dirty != 2 || st.Mode != ModeReadWrite || st.SyncMode != SyncNever || st.Closed {
+		t.Errorf("Dump() = %+v, want a modified read-write file with 2 dirty bytes", st)
+	}
+
+	if err := mf.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if st := mf.Dump(); !st.Closed || st.DataLen != 0 || st.MmapLen != 0 {
+		t.Errorf("Dump() after Close = %+v, want closed and unmapped", st)
+	}
+}
+
+// TestFlush tests flushing part of a mapping and the range checks.
+func TestFlush(t *testing.T) {
+	size := 4 * os.Getpagesize()
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("f", size))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, SyncMode: SyncNever, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	off := int64(os.Getpagesize() + 10)
+	if _, err := mf.WriteAt([]byte("flushed"), off); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if err := mf.Flush(off, 7); err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data[off:off+7]) != "flushed" {
+		t.Errorf("file holds %q at %d, want %q", data[off:off+7], off, "flushed")
+	}
+
+	if err := mf.Flush(int64(size)-1, 2); err != ErrInvalidOffset {
+		t.Errorf("Flush() past the end error = %v, want ErrInvalidOffset", err)
+	}
+	if err := mf.Flush(0, 0); err != ErrInvalidOffset {
+		t.Errorf("Flush() of empty range error = %v, want ErrInvalidOffset", err)
+	}
+}
+
+// hookSyncFile is a mapperFile that calls hook before each Sync.
+type hookSyncFile struct {
+	*mapperFile
+	hook func()
+}
+
+func (f *hookSyncFile) Sync() error {
+	f.hook()
+	return f.mapperFile.Sync()
+}
+
+// TestSyncWorkers tests that with SyncWorkers a slow file does not hold
+// back the periodic sync of the others.
+func TestSyncWorkers(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("w", 100))
+	defer cleanup()
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncPeriodic, SyncInterval: time.Hour, MapFullFile: true}
+	sm := newSyncManager(time.Hour, 2)
+	defer sm.stop()
+
+	open := func(hook func()) *MappedFile {
+		osFile, err := os.OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		backend := &hookSyncFile{mapperFile: &mapperFile{File: osFile, buf: make([]byte, 100)}, hook: hook}
+		mf, err := newMappedFile(backend, config, 100, sm)
+		if err != nil {
+			t.Fatalf("newMappedFile() failed: %v", err)
+		}
+		if _, err := mf.WriteAt([]byte("x"), 0); err != nil {
+			t.Fatalf("WriteAt() failed: %v", err)
+		}
+		return mf
+	}
+
+	release := make(chan struct{})
+	slow := open(func() { <-release })
+	defer slow.Close()
+
+	const fastFiles = 3
+	synced := make(chan struct{}, fastFiles)
+	for i := 0; i < fastFiles; i++ {
+		mf := open(func() { synced <- struct{}{} })
+		defer mf.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		sm.syncAll()
+		close(done)
+	}()
+
+	// The fast files finish while the slow one is still syncing
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < fastFiles; i++ {
+		select {
+		case <-synced:
+		case <-timeout:
+			close(release)
+			t.Fatalf("%d of %d fast files synced while a slow sync was in progress", i, fastFiles)
+		}
+	}
+
+	close(release)
+	<-done
+}
+
+// TestShortMapping tests that reads past the end of a mapping shorter than
+// the file, as some special files produce, fail with ErrShortMapping
+// rather than panicking.
+func TestShortMapping(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("s", 100))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadOnly, MapFullFile: true}).Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	// Pretend mmap returned only half of the file
+	mf.mu.Lock()
+	mf.data = mf.data[:50]
+	mf.mu.Unlock()
+
+	buf := make([]byte, 10)
+	if _, err := mf.ReadAt(buf, 10); err != nil {
+		t.Errorf("ReadAt() inside the mapping failed: %v", err)
+	}
+	if _, err := mf.ReadAt(buf, 80); !errors.Is(err, ErrShortMapping) {
+		t.Errorf("ReadAt() past the mapping error = %v, want ErrShortMapping", err)
+	}
+	if _, err := mf.Seek(60, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	if _, err := mf.Read(buf); !errors.Is(err, ErrShortMapping) {
+		t.Errorf("Read() past the mapping error = %v, want ErrShortMapping", err)
+	}
+	if _, err := mf.ReadByte(); !errors.Is(err, ErrShortMapping) {
+		t.Errorf("ReadByte() past the mapping error = %v, want ErrShortMapping", err)
+	}
+	if _, err := mf.ReadNext(0); !errors.Is(err, ErrShortMapping) {
+		t.Errorf("ReadNext() past the mapping error = %v, want ErrShortMapping", err)
+	}
+	if _, err := mf.ReadAll(); !errors.Is(err, ErrShortMapping) {
+		t.Errorf("ReadAll() of a short mapping error = %v, want ErrShortMapping", err)
+	}
+}
+
+// TestNewReadOptimized tests that the read-optimized filesystem maps reads,
+// windows large files and passes writes through unmapped.
+func TestNewReadOptimized(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	small, cleanupSmall := createTestFile(t, "small file")
+	defer cleanupSmall()
+	large, cleanupLarge := createTestFile(t, strings.Repeat("L", int(3*windowSize)))
+	defer cleanupLarge()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := NewReadOptimized(osFS)
+	if mfs.config.Mode != ModeReadOnly || !mfs.config.MapReadsOnly {
+		t.Errorf("NewReadOptimized() config = %+v, want read-only mapping of reads only", mfs.config)
+	}
+
+	// Shrink the thresholds so the test files straddle them
+	mfs.config.WindowAbove = windowSize
+	mfs.config.WindowSize = windowSize
+
+	file, err := mfs.Open(small)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	if mf, ok := file.(*MappedFile); !ok || mf.Stats().WindowSize != 0 {
+		t.Errorf("Open() of small file = %T, want a full-file mapping", file)
+	}
+
+	file, err = mfs.Open(large)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer file.Close()
+	mf, ok := file.(*MappedFile)
+	if !ok || mf.Stats().WindowSize != windowSize {
+		t.Fatalf("Open() of large file = %T, want a windowed mapping", file)
+	}
+	data, err := io.ReadAll(mf)
+	if err != nil || int64(len(data)) != 3*windowSize {
+		t.Errorf("ReadAll() of windowed file = %d bytes, %v, want %d", len(data), err, 3*windowSize)
+	}
+
+	// Writes go to the plain file
+	wfile, err := mfs.OpenFile(small, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile(O_RDWR) failed: %v", err)
+	}
+	if _, ok := wfile.(*MappedFile); ok {
+		t.Error("OpenFile(O_RDWR) returned a MappedFile, want the underlying file")
+	}
+	if _, err := wfile.WriteAt([]byte("SMALL"), 0); err != nil {
+		t.Errorf("WriteAt() through pass-through file failed: %v", err)
+	}
+	wfile.Close()
+
+	got, err := os.ReadFile(small)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(got) != "SMALL file" {
+		t.Errorf("file = %q after write, want %q", got, "SMALL file")
+	}
+}
+
+// TestTruncateGrowInPlace tests that growing a full-file mapping keeps its
+// contents and maps the new tail, whether or not mremap could extend the
+// mapping where it was.
+func TestTruncateGrowInPlace(t *testing.T) {
+	pageSize := os.Getpagesize()
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("g", pageSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, MapFullFile: true}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	addr := mf.Addr()
+	newSize := int64(4 * pageSize)
+	if err := mf.Truncate(newSize); err != nil {
+		t.Fatalf("Truncate() failed: %v", err)
+	}
+	if runtime.GOOS == "linux" && mf.Addr() != addr {
+		t.Logf("mapping moved from %#x to %#x; mremap fell back to remapping", addr, mf.Addr())
+	}
+
+	data := mf.Data()
+	if int64(len(data)) != newSize || mf.Size() != newSize {
+		t.Fatalf("after grow len(Data()) = %d, Size() = %d, want %d", len(data), mf.Size(), newSize)
+	}
+	if string(data[:pageSize]) != strings.Repeat("g", pageSize) {
+		t.Errorf("original contents changed by grow")
+	}
+	if data[newSize-1] != 0 {
+		t.Errorf("new tail = %q, want zeros", data[newSize-1])
+	}
+
+	if _, err := mf.WriteAt([]byte("end"), newSize-3); err != nil {
+		t.Fatalf("WriteAt() into grown tail failed: %v", err)
+	}
+	if err := mf.Sync(); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	onDisk, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if got := string(onDisk[newSize-3:]); got != "end" {
+		t.Errorf("file tail = %q, want %q", got, "end")
+	}
+}
+
+// TestOpenOverlay tests that overlay writes land in the overlay file, leave
+// the base untouched and are read back in preference to the base.
+func TestOpenOverlay(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "0123456789")
+	defer cleanup()
+	overlayPath := tmpFile + ".overlay"
+	defer os.Remove(overlayPath)
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	o, err := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true}).OpenOverlay(tmpFile, overlayPath)
+	if err != nil {
+		t.Fatalf("OpenOverlay() failed: %v", err)
+	}
+	defer o.Close()
+
+	if _, err := o.WriteAt([]byte("ab"), 2); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := o.WriteAt([]byte("cd"), 6); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if _, err := o.WriteAt([]byte("XY"), 4); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+
+	if got := o.Ranges(); len(got) != 1 || got[0] != (OverlayRange{Off: 2, Len: 6}) {
+		t.Errorf("Ranges() = %v, want [{2 6}]", got)
+	}
+
+	buf := make([]byte, 8)
+	n, err := o.ReadAt(buf, 1)
+	if err != nil {
+		t.Fatalf("ReadAt() failed: %v", err)
+	}
+	if got := string(buf[:n]); got != "1abXYcd8" {
+		t.Errorf("ReadAt() = %q, want %q", got, "1abXYcd8")
+	}
+
+	// Writing past the end extends the file with zeros in between
+	if _, err := o.WriteAt([]byte("!"), 12); err != nil {
+		t.Fatalf("WriteAt() past end failed: %v", err)
+	}
+	if o.Size() != 13 {
+		t.Errorf("Size() = %d, want 13", o.Size())
+	}
+	all, err := io.ReadAll(o)
+	if err != nil {
+		t.Fatalf("ReadAll() failed: %v", err)
+	}
+	if got, want := string(all), "01abXYcd89\x00\x00!"; got != want {
+		t.Errorf("contents = %q, want %q", got, want)
+	}
+
+	base, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(base) != "0123456789" {
+		t.Errorf("base modified: %q", base)
+	}
+
+	if err := o.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := o.ReadAt(buf, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ReadAt() after Close() = %v, want os.ErrClosed", err)
+	}
+}
+
+// TestOpenOverlayIsBase tests that an overlay naming the base file, by
+// path or through a hard link, is rejected without touching the base.
+func TestOpenOverlayIsBase(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "0123456789")
+	defer cleanup()
+	linkPath := tmpFile + ".link"
+	if err := os.Link(tmpFile, linkPath); err != nil {
+		t.Fatalf("Link() failed: %v", err)
+	}
+	defer os.Remove(linkPath)
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	mfs := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true})
+	for _, overlay := range []string{tmpFile, linkPath} {
+		if _, err := mfs.OpenOverlay(tmpFile, overlay); !errors.Is(err, ErrOverlayIsBase) {
+			t.Errorf("OpenOverlay(%q) = %v, want ErrOverlayIsBase", overlay, err)
+		}
+	}
+
+	base, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(base) != "0123456789" {
+		t.Errorf("base modified: %q", base)
+	}
+}
+
+// TestSmallFileNotWindowed tests that a file that fits in one window is
+// mapped whole, and switches to windows once it grows past one, whether
+// through Truncate, GrowOnWriteAt or Remap after another writer grew it.
+func TestSmallFileNotWindowed(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	for _, grow := range []struct {
+		name string
+		fn   func(mf *MappedFile, path string) error
+	}{
+		{"Truncate", func(mf *MappedFile, path string) error {
+			return mf.Truncate(3 * windowSize)
+		}},
+		{"GrowOnWriteAt", func(mf *MappedFile, path string) error {
+			_, err := mf.WriteAt([]byte("x"), 3*windowSize-1)
+			return err
+		}},
+		{"Remap", func(mf *MappedFile, path string) error {
+			if err := os.Truncate(path, 3*windowSize); err != nil {
+				return err
+			}
+			return mf.Remap()
+		}},
+	} {
+		tmpFile, cleanup := createTestFile(t, strings.Repeat("s", int(windowSize)))
+		defer cleanup()
+
+		config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, WindowSize: windowSize, GrowOnWriteAt: true}
+		file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() failed: %v", err)
+		}
+		defer file.Close()
+		mf := file.(*MappedFile)
+
+		if st := mf.Dump(); st.WindowSize != 0 {
+			t.Errorf("WindowSize of a one-window file = %d, want 0 (mapped whole)", st.WindowSize)
+		}
+		if got := int64(len(mf.Data())); got != windowSize {
+			t.Errorf("len(Data()) = %d, want %d", got, windowSize)
+		}
+
+		if err := grow.fn(mf, tmpFile); err != nil {
+			t.Fatalf("%s: growing failed: %v", grow.name, err)
+		}
+		if st := mf.Dump(); st.WindowSize != windowSize || int64(st.DataLen) > windowSize {
+			t.Errorf("%s: after growing past one window WindowSize = %d, DataLen = %d, want %d, <= %d", grow.name, st.WindowSize, st.DataLen, windowSize, windowSize)
+		}
+
+		if _, err := mf.WriteAt([]byte("end"), 3*windowSize-3); err != nil {
+			t.Fatalf("%s: WriteAt() in last window failed: %v", grow.name, err)
+		}
+		buf := make([]byte, 1)
+		if _, err := mf.ReadAt(buf, 0); err != nil || buf[0] != 's' {
+			t.Errorf("%s: ReadAt(0) = %q, %v, want %q", grow.name, buf, err, "s")
+		}
+	}
+}
+
+// TestMappedReader tests that Reader reads the mapping like bytes.Reader,
+// pins it until Close, and is refused for windowed files.
+func TestMappedReader(t *testing.T) {
+	content := "hello, mapped world"
+	tmpFile, cleanup := createTestFile(t, content)
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	file, err := New(osFS, &Config{Mode: ModeReadWrite, MapFullFile: true}).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	mr, err := mf.Reader()
+	if err != nil {
+		t.Fatalf("Reader() failed: %v", err)
+	}
+
+	if mr.Size() != int64(len(content)) || mr.Len() != len(content) {
+		t.Errorf("Size() = %d, Len() = %d, want %d", mr.Size(), mr.Len(), len(content))
+	}
+	if b, err := mr.ReadByte(); err != nil || b != 'h' {
+		t.Errorf("ReadByte() = %q, %v, want 'h'", b, err)
+	}
+	if mr.Len() != len(content)-1 {
+		t.Errorf("Len() after ReadByte() = %d, want %d", mr.Len(), len(content)-1)
+	}
+	if _, err := mr.Seek(7, io.SeekStart); err != nil {
+		t.Fatalf("Seek() failed: %v", err)
+	}
+	rest, err := io.ReadAll(mr)
+	if err != nil || string(rest) != "mapped world" {
+		t.Errorf("ReadAll() = %q, %v, want %q", rest, err, "mapped world")
+	}
+	buf := make([]byte, 5)
+	if _, err := mr.ReadAt(buf, 0); err != nil || string(buf) != "hello" {
+		t.Errorf("ReadAt() = %q, %v, want %q", buf, err, "hello")
+	}
+	if mr.BytesReader().Size() != int64(len(content)) {
+		t.Errorf("BytesReader().Size() = %d, want %d", mr.BytesReader().Size(), len(content))
+	}
+
+	// The pin keeps the mapping in place
+	if err := mf.Truncate(4096); !errors.Is(err, ErrPinned) {
+		t.Errorf("Truncate() while reading = %v, want ErrPinned", err)
+	}
+
+	if err := mr.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := mr.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Read() after Close() = %v, want os.ErrClosed", err)
+	}
+	if err := mf.Truncate(4096); err != nil {
+		t.Errorf("Truncate() after Close() failed: %v", err)
+	}
+
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+	bigFile, bigCleanup := createTestFile(t, strings.Repeat("w", int(2*windowSize)))
+	defer bigCleanup()
+	big, err := New(osFS, &Config{WindowSize: windowSize}).Open(bigFile)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer big.Close()
+	if _, err := big.(*MappedFile).Reader(); !errors.Is(err, ErrWindowedMapping) {
+		t.Errorf("Reader() on windowed file = %v, want ErrWindowedMapping", err)
+	}
+}
+
+// flagRecordingFS records the flags of the last OpenFile call.
+type flagRecordingFS struct {
+	absfs.FileSystem
+	flag *int
+}
+
+func (f flagRecordingFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	*f.flag = flag
+	return f.FileSystem.OpenFile(name, flag, perm)
+}
+
+// TestOpenSync tests that Config.OpenSync adds O_SYNC to underlying opens
+// and that files opened with O_SYNC are mapped with SyncImmediate.
+func TestOpenSync(t *testing.T) {
+	tmpFile, cleanup := createTestFile(t, "durable contents")
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	var flag int
+	recFS := flagRecordingFS{FileSystem: osFS, flag: &flag}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, MapFullFile: true, OpenSync: true}
+	file, err := New(recFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+
+	if flag&os.O_SYNC != os.O_SYNC {
+		t.Errorf("underlying flags = %#x, want O_SYNC set", flag)
+	}
+	if got := file.(*MappedFile).config.SyncMode; got != SyncImmediate {
+		t.Errorf("SyncMode with OpenSync = %v, want SyncImmediate", got)
+	}
+	if config.SyncMode != SyncNever {
+		t.Errorf("OpenFile() changed the shared config's SyncMode to %v", config.SyncMode)
+	}
+
+	// An explicit O_SYNC is honored the same way
+	plain, err := New(osFS, &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, MapFullFile: true}).
+		OpenFile(tmpFile, os.O_RDWR|os.O_SYNC, 0)
+	if err != nil {
+		t.Fatalf("OpenFile(O_SYNC) failed: %v", err)
+	}
+	defer plain.Close()
+	mf := plain.(*MappedFile)
+	if got := mf.config.SyncMode; got != SyncImmediate {
+		t.Errorf("SyncMode with O_SYNC = %v, want SyncImmediate", got)
+	}
+
+	if _, err := mf.WriteAt([]byte("DURABLE"), 0); err != nil {
+		t.Fatalf("WriteAt() failed: %v", err)
+	}
+	if mf.IsDirty() {
+		t.Errorf("IsDirty() after WriteAt() on an O_SYNC file = true, want false")
+	}
+}
+
+// TestDrainRemapsEveryAccess tests that atomics, Records, advice and Flush
+// remap a drained file instead of failing with ErrNotMapped, and that a
+// SharedMemory's mapping refuses to drain.
+func TestDrainRemapsEveryAccess(t *testing.T) {
+	pageSize := os.Getpagesize()
+	tmpFile, cleanup := createTestFile(t, strings.Repeat("\x00", pageSize))
+	defer cleanup()
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncLazy, MapFullFile: true}
+	file, err := New(osFS, config).OpenFile(tmpFile, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() failed: %v", err)
+	}
+	defer file.Close()
+	mf := file.(*MappedFile)
+
+	accesses := []struct {
+		name string
+		fn   func() error
+	}{
+		{"StoreUint64", func() error { return mf.StoreUint64(0, 7) }},
+		{"LoadUint64", func() error { _, err := mf.LoadUint64(0); return err }},
+		{"AddUint64", func() error { _, err := mf.AddUint64(0, 1); return err }},
+		{"Records", func() error {
+			records, err := mf.Records(8, false)
+			if err != nil {
+				return err
+			}
+			var recErr error
+			records(func(_ []byte, err error) bool {
+				recErr = err
+				return false
+			})
+			return recErr
+		}},
+		{"AdviseSequential", mf.AdviseSequential},
+		{"AdviseRange", func() error { return mf.AdviseRange(0, 8, 0) }},
+		{"Hint", func() error { return mf.Hint(HintWillNeed) }},
+		{"Flush", func() error { return mf.Flush(0, 8) }},
+	}
+
+	for _, a := range accesses {
+		if err := mf.Drain(); err != nil {
+			t.Fatalf("Drain() failed: %v", err)
+		}
+		if err := a.fn(); err != nil {
+			t.Errorf("%s() after Drain() failed: %v", a.name, err)
+		}
+	}
+
+	if got, err := mf.LoadUint64(0); err != nil || got != 8 {
+		t.Errorf("LoadUint64() = %d, %v, want 8", got, err)
+	}
+
+	sm, err := CreateSharedMemory(&SharedMemoryConfig{
+		Path: filepath.Join(t.TempDir(), "drain.shm"),
+		Size: int64(pageSize),
+	})
+	if err != nil {
+		t.Fatalf("CreateSharedMemory() failed: %v", err)
+	}
+	defer sm.Close()
+
+	if err := sm.MappedFile().Drain(); !errors.Is(err, ErrPinned) {
+		t.Errorf("Drain() of a SharedMemory mapping = %v, want ErrPinned", err)
+	}
+	if err := sm.MappedFile().Truncate(int64(2 * pageSize)); !errors.Is(err, ErrPinned) {
+		t.Errorf("Truncate() of a SharedMemory mapping = %v, want ErrPinned", err)
+	}
+	if err := sm.Notify(0); err != nil {
+		t.Errorf("Notify() after refused Drain() failed: %v", err)
+	}
+}
+
+// TestStreamWriterWindowed tests that a stream writer whose first chunk fits
+// one window switches to windows when MapFullFile is off, so the mapping
+// stays one window long as the output grows past it.
+func TestStreamWriterWindowed(t *testing.T) {
+	windowSize := int64(os.Getpagesize())
+	if runtime.GOOS == "windows" {
+		windowSize = 64 * 1024
+	}
+
+	osFS, err := osfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() failed: %v", err)
+	}
+
+	config := &Config{Mode: ModeReadWrite, SyncMode: SyncNever, WindowSize: windowSize}
+	name := filepath.Join(t.TempDir(), "stream.bin")
+	sw, err := New(osFS, config).CreateStreamWriter(name, windowSize)
+	if err != nil {
+		t.Fatalf("CreateStreamWriter() failed: %v", err)
+	}
+
+	data := bytes.Repeat([]byte("w"), int(3*windowSize))
+	if _, err := sw.Write(data); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if st := sw.mf.Dump(); st.WindowSize != windowSize || int64(st.DataLen) > windowSize {
+		t.Errorf("after writing past one window WindowSize = %d, DataLen = %d, want %d, <= %d", st.WindowSize, st.DataLen, windowSize, windowSize)
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("file holds %d bytes, want %d bytes of %q", len(got), len(data), "w")
+	}
+}
@@ -3,16 +3,42 @@
 package memmapfs
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"reflect"
 	"unsafe"
 
+	"github.com/absfs/absfs"
 	"golang.org/x/sys/unix"
 )
 
+// allocationGranularity returns the alignment required for mapping offsets,
+// which is the page size on Unix systems.
+func allocationGranularity() int {
+	return unix.Getpagesize()
+}
+
+// oNoAtime is the open flag used for Config.NoAtime; BSD has none.
+const oNoAtime = 0
+
+// oDSync is the O_DSYNC open flag; not every BSD has one, so only O_SYNC
+// is honored.
+const oDSync = 0
+
+// syncDirSupported reports whether directories can be fsynced.
+const syncDirSupported = true
+
 // mmap performs the platform-specific memory mapping.
 func (mf *MappedFile) mmap() error {
+	if mf.remote != nil {
+		return mf.mmapRemote()
+	}
+
+	if mf.mapper != nil {
+		prot, flags := mf.getProtectionFlags()
+		return mf.mmapMapper(prot, flags)
+	}
+
 	// Get file descriptor
 	fd, err := getFD(mf.file)
 	if err != nil {
@@ -25,6 +51,13 @@ func (mf *MappedFile) mmap() error {
 	// Determine protection and flags based on mode
 	prot, flags := mf.getProtectionFlags()
 
+	// Catch backends that hand out a read-only descriptor despite O_RDWR
+	if prot&unix.PROT_WRITE != 0 && flags&unix.MAP_SHARED != 0 {
+		if fl, err := unix.FcntlInt(fd, unix.F_GETFL, 0); err == nil && fl&unix.O_ACCMODE == unix.O_RDONLY {
+			return fmt.Errorf("%s: %w", mf.file.Name(), ErrReadOnlyDescriptor)
+		}
+	}
+
 	// Note: PopulatePages and UseHugePages are handled differently on BSD.
 	// FreeBSD has MAP_PREFAULT_READ, other BSDs use madvise.
 	// UseHugePages has no direct equivalent on BSDs.
@@ -52,9 +85,15 @@ func (mf *MappedFile) mmap() error {
 	// Adjust map size to account for alignment
 	adjustedMapSize := mapSize + offsetDiff
 
-	// Perform mmap
-	data, err := unix.Mmap(int(fd), alignedOffset, int(adjustedMapSize), prot, flags)
-	if err != nil {
+	var data []byte
+	mapOnce := func() error {
+		var mapErr error
+		data, mapErr = unix.Mmap(int(fd), alignedOffset, int(adjustedMapSize), prot, flags)
+		return mapErr
+	}
+
+	// Perform mmap, retrying transient ENOMEM if configured
+	if err := mf.retryMmap(mapOnce, mapOnce()); err != nil {
 		return fmt.Errorf("mmap failed: %w", err)
 	}
 
@@ -84,6 +123,10 @@ func (mf *MappedFile) munmap() error {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.munmapMapper()
+	}
+
 	// Unmap the original mmap'd slice, not the adjusted one
 	if err := unix.Munmap(mf.mmapData); err != nil {
 		return fmt.Errorf("munmap failed: %w", err)
@@ -93,12 +136,30 @@ func (mf *MappedFile) munmap() error {
 	return nil
 }
 
+// growInPlaceSupported reports whether growInPlace can succeed.
+const growInPlaceSupported = false
+
+// growInPlace is not implemented on BSD; resizes always remap.
+func (mf *MappedFile) growInPlace(newSize int64) bool {
+	return false
+}
+
+// remapAnonymous is not implemented on BSD; anonymous mappings are grown
+// by copying.
+func remapAnonymous(data []byte, size int) ([]byte, bool) {
+	return nil, false
+}
+
 // msync synchronizes dirty pages to disk.
 func (mf *MappedFile) msync() error {
 	if mf.mmapData == nil {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
 	var flags int
 	switch mf.config.SyncMode {
 	case SyncImmediate:
@@ -117,6 +178,79 @@ func (mf *MappedFile) msync() error {
 	return nil
 }
 
+// msyncRange writes the pages of span, a page-aligned part of the mapping,
+// to disk and waits for them.
+func (mf *MappedFile) msyncRange(span []byte) error {
+	if err := unix.Msync(span, unix.MS_SYNC); err != nil {
+		return fmt.Errorf("msync failed: %w", err)
+	}
+	return nil
+}
+
+// SyncInvalidate synchronously writes back dirty pages and invalidates other
+// cached copies of the mapped range (msync with MS_SYNC|MS_INVALIDATE), so
+// that readers using plain file I/O observe the committed changes.
+func (mf *MappedFile) SyncInvalidate() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.mmapData == nil || mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
+	// Use the original mmap'd slice for msync
+	if err := unix.Msync(mf.mmapData, unix.MS_SYNC|unix.MS_INVALIDATE); err != nil {
+		return fmt.Errorf("msync failed: %w", err)
+	}
+
+	return nil
+}
+
+// isTransientMmapError reports whether a failed mmap may succeed if retried
+// once other mappings have been released.
+func isTransientMmapError(err error) bool {
+	return errors.Is(err, unix.ENOMEM) || errors.Is(err, unix.EAGAIN)
+}
+
+// preallocate grows the file to size bytes, writing zeros over the new
+// region so that its blocks are allocated. x/sys does not expose
+// posix_fallocate on the BSDs. Holes below the current end of file are not
+// filled.
+func preallocate(file absfs.File, size int64) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+
+	if size <= fi.Size() {
+		return nil
+	}
+
+	if err := writeZeros(file, fi.Size(), size); err != nil {
+		return fmt.Errorf("preallocate failed: %w", err)
+	}
+	return nil
+}
+
+// punchHole is not supported on BSD; AdviseRemove zero-fills instead.
+func (mf *MappedFile) punchHole(off, length int64) error {
+	return errors.ErrUnsupported
+}
+
+// protectReadOnly drops write permission from the current mapping, so that
+// stray writes through Data fault.
+func (mf *MappedFile) protectReadOnly() error {
+	if mf.mmapData == nil || mf.mapper != nil {
+		return nil
+	}
+
+	if err := unix.Mprotect(mf.mmapData, unix.PROT_READ); err != nil {
+		return fmt.Errorf("mprotect failed: %w", err)
+	}
+
+	return nil
+}
+
 // preload provides hints to the OS to load pages into memory.
 func (mf *MappedFile) preload() error {
 	if mf.mmapData == nil {
@@ -131,23 +265,48 @@ func (mf *MappedFile) preload() error {
 	return nil
 }
 
+// mincore is not used on BSD; PreloadSync touches the pages without
+// checking residency.
+func mincore(b []byte, vec []byte) error {
+	return errors.ErrUnsupported
+}
+
+// readAheadSupported reports whether readAhead does anything.
+const readAheadSupported = false
+
+// readAhead is not implemented on BSD; Config.ReadAheadWindows is ignored.
+func (mf *MappedFile) readAhead(off, length int64) error {
+	return nil
+}
+
 // getProtectionFlags returns the protection and mapping flags based on the mode.
 func (mf *MappedFile) getProtectionFlags() (prot int, flags int) {
 	switch mf.config.Mode {
 	case ModeReadOnly:
 		prot = unix.PROT_READ
 		flags = unix.MAP_SHARED
+		if mf.config.PrivateReadOnly {
+			flags = unix.MAP_PRIVATE
+		}
 	case ModeReadWrite:
 		prot = unix.PROT_READ | unix.PROT_WRITE
 		flags = unix.MAP_SHARED
 	case ModeCopyOnWrite:
 		prot = unix.PROT_READ | unix.PROT_WRITE
 		flags = unix.MAP_PRIVATE
+	case ModeReadExec:
+		prot = unix.PROT_READ | unix.PROT_EXEC
+		flags = unix.MAP_SHARED
+	case ModeReadWriteExec:
+		prot = unix.PROT_READ | unix.PROT_WRITE | unix.PROT_EXEC
+		flags = unix.MAP_SHARED
 	default:
 		prot = unix.PROT_READ
 		flags = unix.MAP_SHARED
 	}
 
+	flags |= mf.config.ExtraMmapFlags
+
 	return prot, flags
 }
 
@@ -168,48 +327,8 @@ func getFD(file interface{}) (uintptr, error) {
 	}
 
 	// Try to find an embedded or wrapped *os.File using reflection
-	v := reflect.ValueOf(file)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-
-	// Look for a field that might contain the os.File
-	// This includes both exported and unexported fields
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		// For unexported fields, we need to use unsafe to access them
-		if !field.CanInterface() {
-			// Create a new value that can be interfaced
-			field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
-		}
-
-		// Check if this field is an *os.File
-		if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-			if osFile, ok := field.Interface().(*os.File); ok {
-				return osFile.Fd(), nil
-			}
-		}
-
-		// Check if field name suggests it's a file (common naming patterns)
-		fieldName := fieldType.Name
-		if (fieldName == "file" || fieldName == "f" || fieldName == "File") && field.Kind() == reflect.Ptr {
-			// Try to extract Fd from this field
-			if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-				if osFile, ok := field.Interface().(*os.File); ok {
-					return osFile.Fd(), nil
-				}
-			}
-		}
-
-		// Check if this field implements the Fd() method
-		if field.CanInterface() {
-			if fdGetter, ok := field.Interface().(interface{ Fd() uintptr }); ok {
-				return fdGetter.Fd(), nil
-			}
-		}
+	if fd, ok := wrappedFD(file); ok {
+		return fd, nil
 	}
 
 	return 0, fmt.Errorf("unable to extract file descriptor from type %T", file)
@@ -218,13 +337,20 @@ func getFD(file interface{}) (uintptr, error) {
 // Advise provides access pattern hints to the kernel.
 // This is a utility function for advanced use cases.
 func (mf *MappedFile) Advise(advice int) error {
-	mf.mu.RLock()
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
 	defer mf.mu.RUnlock()
 
 	if mf.mmapData == nil {
 		return ErrNotMapped
 	}
 
+	// The memory belongs to the Mmapper, not the kernel
+	if mf.mapper != nil {
+		return nil
+	}
+
 	// Use the original mmap'd slice for madvise
 	if err := unix.Madvise(mf.mmapData, advice); err != nil {
 		return fmt.Errorf("madvise failed: %w", err)
@@ -233,6 +359,32 @@ func (mf *MappedFile) Advise(advice int) error {
 	return nil
 }
 
+// AdviseRange is like Advise but applies to the file range [off,
+// off+length) only, rounded out to whole pages. The range must lie within
+// the mapped region (the current window, if windowed).
+func (mf *MappedFile) AdviseRange(off, length int64, advice int) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	defer mf.mu.RUnlock()
+
+	span, err := mf.adviseSpan(off, length)
+	if err != nil {
+		return err
+	}
+
+	// The memory belongs to the Mmapper, not the kernel
+	if mf.mapper != nil {
+		return nil
+	}
+
+	if err := unix.Madvise(span, advice); err != nil {
+		return fmt.Errorf("madvise failed: %w", err)
+	}
+
+	return nil
+}
+
 // AdviseSequential hints that the file will be accessed sequentially.
 func (mf *MappedFile) AdviseSequential() error {
 	return mf.Advise(unix.MADV_SEQUENTIAL)
@@ -267,20 +419,59 @@ func (mf *MappedFile) AdviseNoHugePage() error {
 	return nil
 }
 
+// AdviseCollapse is a no-op on BSD.
+func (mf *MappedFile) AdviseCollapse() error {
+	return nil
+}
+
+// AdviseCollapseRange is a no-op on BSD, other than checking the range.
+func (mf *MappedFile) AdviseCollapseRange(off, length int64) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	defer mf.mu.RUnlock()
+
+	_, err := mf.adviseSpan(off, length)
+	return err
+}
+
 // AdviseFree hints that the pages can be freed.
 // On BSD, this uses MADV_FREE which is available on most BSD variants.
 func (mf *MappedFile) AdviseFree() error {
 	return mf.Advise(unix.MADV_FREE)
 }
 
-// AdviseRemove is a no-op on BSD as this advice is Linux-specific.
-func (mf *MappedFile) AdviseRemove() error {
-	// No equivalent on BSD, use MADV_DONTNEED as closest alternative
-	return mf.Advise(unix.MADV_DONTNEED)
+// AdviseDontFork is a no-op on BSD as MADV_DONTFORK is Linux-specific.
+func (mf *MappedFile) AdviseDontFork() error {
+	return nil
+}
+
+// AdviseDoFork is a no-op on BSD as MADV_DOFORK is Linux-specific.
+func (mf *MappedFile) AdviseDoFork() error {
+	return nil
+}
+
+// AdvisePopulateRead hints that the pages will be needed soon.
+// MADV_POPULATE_READ is Linux-specific; on BSD this uses MADV_WILLNEED.
+func (mf *MappedFile) AdvisePopulateRead() error {
+	return mf.Advise(unix.MADV_WILLNEED)
+}
+
+// AdvisePopulateWrite hints that the pages will be needed soon.
+// MADV_POPULATE_WRITE is Linux-specific; on BSD this uses MADV_WILLNEED.
+func (mf *MappedFile) AdvisePopulateWrite() error {
+	return mf.Advise(unix.MADV_WILLNEED)
+}
+
+// SetNoCache is a no-op on BSD; F_NOCACHE is macOS-specific.
+func (mf *MappedFile) SetNoCache(noCache bool) error {
+	return nil
 }
 
 // Data returns a direct slice to the mapped memory.
 // Use with caution - this provides direct access to the mapped region.
+// The slice is invalidated by Close, window slides and remaps; use Pin to
+// hold it safely.
 // For read-only mappings, modifications will cause a panic.
 func (mf *MappedFile) Data() []byte {
 	mf.mu.RLock()
@@ -3,16 +3,42 @@
 package memmapfs
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"reflect"
+	"sync"
 	"unsafe"
 
+	"github.com/absfs/absfs"
 	"golang.org/x/sys/unix"
 )
 
+// allocationGranularity returns the alignment required for mapping offsets,
+// which is the page size on Unix systems.
+func allocationGranularity() int {
+	return unix.Getpagesize()
+}
+
+// oNoAtime is the open flag used for Config.NoAtime.
+const oNoAtime = unix.O_NOATIME
+
+// oDSync is the O_DSYNC open flag, honored like O_SYNC by OpenFile.
+const oDSync = unix.O_DSYNC
+
+// syncDirSupported reports whether directories can be fsynced.
+const syncDirSupported = true
+
 // mmap performs the platform-specific memory mapping.
 func (mf *MappedFile) mmap() error {
+	if mf.remote != nil {
+		return mf.mmapRemote()
+	}
+
+	if mf.mapper != nil {
+		prot, flags := mf.getProtectionFlags()
+		return mf.mmapMapper(prot, flags)
+	}
+
 	// Get file descriptor
 	fd, err := getFD(mf.file)
 	if err != nil {
@@ -25,8 +51,16 @@ func (mf *MappedFile) mmap() error {
 	// Determine protection and flags based on mode
 	prot, flags := mf.getProtectionFlags()
 
+	// Catch backends that hand out a read-only descriptor despite O_RDWR
+	if prot&unix.PROT_WRITE != 0 && flags&unix.MAP_SHARED != 0 {
+		if fl, err := unix.FcntlInt(fd, unix.F_GETFL, 0); err == nil && fl&unix.O_ACCMODE == unix.O_RDONLY {
+			return fmt.Errorf("%s: %w", mf.file.Name(), ErrReadOnlyDescriptor)
+		}
+	}
+
 	// Add Linux-specific optimization flags if requested
-	if mf.config.PopulatePages {
+	populateAdvice := mf.config.PopulatePages && populateAdviceSupported()
+	if mf.config.PopulatePages && !populateAdvice {
 		// MAP_POPULATE: Populate (prefault) page tables
 		// This loads the file into RAM immediately, avoiding future page faults
 		flags |= unix.MAP_POPULATE
@@ -37,6 +71,11 @@ func (mf *MappedFile) mmap() error {
 		// Requires huge pages to be configured on the system
 		// Falls back to normal pages if huge pages unavailable
 		flags |= unix.MAP_HUGETLB
+
+		// Select a specific huge page size (e.g. 21 for 2MB, 30 for 1GB)
+		if mf.config.HugePageSizeLog2 > 0 {
+			flags |= (mf.config.HugePageSizeLog2 & unix.MAP_HUGE_MASK) << unix.MAP_HUGE_SHIFT
+		}
 	}
 
 	// Calculate map size based on windowing
@@ -62,19 +101,61 @@ func (mf *MappedFile) mmap() error {
 	// Adjust map size to account for alignment
 	adjustedMapSize := mapSize + offsetDiff
 
+	var data []byte
+	mapOnce := func() error {
+		var mapErr error
+		data, mapErr = unix.Mmap(int(fd), alignedOffset, int(adjustedMapSize), prot, flags)
+		return mapErr
+	}
+
 	// Perform mmap
-	data, err := unix.Mmap(int(fd), alignedOffset, int(adjustedMapSize), prot, flags)
+	err = mapOnce()
+	var hugeErr error
+	if err != nil && mf.config.UseHugePages && mf.config.HugePageSizeLog2 > 0 {
+		// If the requested huge page size failed, retry with the default size
+		mf.config.warn("memmapfs: huge page size unavailable, using default huge pages",
+			"name", mf.file.Name(), "log2", mf.config.HugePageSizeLog2, "error", err)
+		flags &^= unix.MAP_HUGE_MASK << unix.MAP_HUGE_SHIFT
+		err = mapOnce()
+	}
+	if err != nil && mf.config.UseHugePages {
+		// If huge pages failed, retry without them. Keep the huge page
+		// error so a failed retry still explains why huge pages didn't engage.
+		mf.config.warn("memmapfs: huge pages unavailable, mapping without them",
+			"name", mf.file.Name(), "error", err)
+		hugeErr = err
+		flags &^= unix.MAP_HUGETLB
+		err = mapOnce()
+	}
 	if err != nil {
-		// If huge pages failed, retry without them
-		if mf.config.UseHugePages {
-			flags &^= unix.MAP_HUGETLB
-			data, err = unix.Mmap(int(fd), alignedOffset, int(adjustedMapSize), prot, flags)
-		}
-		if err != nil {
+		// Retry transient ENOMEM if configured
+		if err = mf.retryMmap(mapOnce, err); err != nil {
+			if hugeErr != nil {
+				return fmt.Errorf("mmap failed without huge pages: %w (huge page attempt: %w)", err, hugeErr)
+			}
 			return fmt.Errorf("mmap failed: %w", err)
 		}
 	}
 
+	// Keep forked children from inheriting the mapping if requested
+	if mf.config.DontFork {
+		if err := unix.Madvise(data, unix.MADV_DONTFORK); err != nil {
+			unix.Munmap(data)
+			return fmt.Errorf("madvise(MADV_DONTFORK) failed: %w", err)
+		}
+	}
+
+	// Prefer MADV_POPULATE_READ (Linux 5.14+) to MAP_POPULATE: it reports
+	// failures instead of silently skipping pages, and never breaks
+	// copy-on-write. Like MAP_POPULATE it is only a hint here, so a failure
+	// is logged and the mapping kept.
+	if populateAdvice {
+		if err := unix.Madvise(data, unix.MADV_POPULATE_READ); err != nil {
+			mf.config.warn("memmapfs: populating mapping failed, pages will fault in on access",
+				"name", mf.file.Name(), "error", err)
+		}
+	}
+
 	// Store the original mmap'd slice for munmap
 	mf.mmapData = data
 
@@ -88,12 +169,28 @@ func (mf *MappedFile) mmap() error {
 	return nil
 }
 
+// populateAdviceSupported reports whether the kernel understands
+// MADV_POPULATE_READ, probing once with an anonymous page.
+var populateAdviceSupported = sync.OnceValue(func() bool {
+	page, err := unix.Mmap(-1, 0, unix.Getpagesize(), unix.PROT_READ, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return false
+	}
+	defer unix.Munmap(page)
+
+	return unix.Madvise(page, unix.MADV_POPULATE_READ) == nil
+})
+
 // munmap unmaps the memory region.
 func (mf *MappedFile) munmap() error {
 	if mf.mmapData == nil {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.munmapMapper()
+	}
+
 	// Unmap the original mmap'd slice, not the adjusted one
 	if err := unix.Munmap(mf.mmapData); err != nil {
 		return fmt.Errorf("munmap failed: %w", err)
@@ -103,12 +200,41 @@ func (mf *MappedFile) munmap() error {
 	return nil
 }
 
+// growInPlaceSupported reports whether growInPlace can succeed.
+const growInPlaceSupported = true
+
+// remapAnonymous grows a mapping from mapPrivateAnonymous to size bytes
+// with mremap, moving it if the pages past it are taken, so its contents
+// are not copied.
+func remapAnonymous(data []byte, size int) ([]byte, bool) {
+	grown, err := unix.Mremap(data, size, unix.MREMAP_MAYMOVE)
+	return grown, err == nil
+}
+
+// growInPlace extends a full-file mapping to newSize with mremap, without
+// MREMAP_MAYMOVE, so the mapping keeps its address. It reports false, leaving
+// the mapping untouched, when the pages past the mapping are taken.
+func (mf *MappedFile) growInPlace(newSize int64) bool {
+	data, err := unix.Mremap(mf.mmapData, int(newSize), 0)
+	if err != nil {
+		return false
+	}
+
+	mf.mmapData = data
+	mf.data = data
+	return true
+}
+
 // msync synchronizes dirty pages to disk.
 func (mf *MappedFile) msync() error {
 	if mf.mmapData == nil {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
 	var flags int
 	switch mf.config.SyncMode {
 	case SyncImmediate:
@@ -127,6 +253,78 @@ func (mf *MappedFile) msync() error {
 	return nil
 }
 
+// msyncRange writes the pages of span, a page-aligned part of the mapping,
+// to disk and waits for them.
+func (mf *MappedFile) msyncRange(span []byte) error {
+	if err := unix.Msync(span, unix.MS_SYNC); err != nil {
+		return fmt.Errorf("msync failed: %w", err)
+	}
+	return nil
+}
+
+// SyncInvalidate synchronously writes back dirty pages and invalidates other
+// cached copies of the mapped range (msync with MS_SYNC|MS_INVALIDATE), so
+// that readers using plain file I/O observe the committed changes.
+func (mf *MappedFile) SyncInvalidate() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.mmapData == nil || mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
+	// Use the original mmap'd slice for msync
+	if err := unix.Msync(mf.mmapData, unix.MS_SYNC|unix.MS_INVALIDATE); err != nil {
+		return fmt.Errorf("msync failed: %w", err)
+	}
+
+	return nil
+}
+
+// isTransientMmapError reports whether a failed mmap may succeed if retried
+// once other mappings have been released.
+func isTransientMmapError(err error) bool {
+	return errors.Is(err, unix.ENOMEM) || errors.Is(err, unix.EAGAIN)
+}
+
+// preallocate reserves disk blocks for the first size bytes of the file,
+// growing it if necessary, using fallocate.
+func preallocate(file absfs.File, size int64) error {
+	fd, err := getFD(file)
+	if err != nil {
+		return fmt.Errorf("failed to get file descriptor: %w", err)
+	}
+
+	if err := unix.Fallocate(int(fd), 0, 0, size); err != nil {
+		return fmt.Errorf("fallocate failed: %w", err)
+	}
+
+	return nil
+}
+
+// punchHole deallocates [off, off+length) of the file with fallocate. The
+// range reads back as zeros, including through shared mappings.
+func (mf *MappedFile) punchHole(off, length int64) error {
+	if err := unix.Fallocate(int(mf.fd), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, off, length); err != nil {
+		return fmt.Errorf("fallocate failed: %w", err)
+	}
+	return nil
+}
+
+// protectReadOnly drops write permission from the current mapping, so that
+// stray writes through Data fault.
+func (mf *MappedFile) protectReadOnly() error {
+	if mf.mmapData == nil || mf.mapper != nil {
+		return nil
+	}
+
+	if err := unix.Mprotect(mf.mmapData, unix.PROT_READ); err != nil {
+		return fmt.Errorf("mprotect failed: %w", err)
+	}
+
+	return nil
+}
+
 // preload provides hints to the OS to load pages into memory.
 func (mf *MappedFile) preload() error {
 	if mf.mmapData == nil {
@@ -147,23 +345,61 @@ func (mf *MappedFile) preload() error {
 	return nil
 }
 
+// mincore reports in vec, one byte per page, whether each page of b is
+// resident; the low bit is set for resident pages.
+func mincore(b []byte, vec []byte) error {
+	_, _, errno := unix.Syscall(unix.SYS_MINCORE, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(unsafe.Pointer(&vec[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// readAheadSupported reports whether readAhead does anything.
+const readAheadSupported = true
+
+// readAhead starts reading [off, off+length) of the file into the page
+// cache without waiting for it.
+func (mf *MappedFile) readAhead(off, length int64) error {
+	if err := unix.Fadvise(int(mf.fd), off, length, unix.FADV_WILLNEED); err != nil {
+		return fmt.Errorf("fadvise failed: %w", err)
+	}
+	return nil
+}
+
 // getProtectionFlags returns the protection and mapping flags based on the mode.
 func (mf *MappedFile) getProtectionFlags() (prot int, flags int) {
 	switch mf.config.Mode {
 	case ModeReadOnly:
 		prot = unix.PROT_READ
 		flags = unix.MAP_SHARED
+		if mf.config.PrivateReadOnly {
+			flags = unix.MAP_PRIVATE
+		}
 	case ModeReadWrite:
 		prot = unix.PROT_READ | unix.PROT_WRITE
 		flags = unix.MAP_SHARED
 	case ModeCopyOnWrite:
 		prot = unix.PROT_READ | unix.PROT_WRITE
 		flags = unix.MAP_PRIVATE
+	case ModeReadExec:
+		prot = unix.PROT_READ | unix.PROT_EXEC
+		flags = unix.MAP_SHARED
+	case ModeReadWriteExec:
+		prot = unix.PROT_READ | unix.PROT_WRITE | unix.PROT_EXEC
+		flags = unix.MAP_SHARED
 	default:
 		prot = unix.PROT_READ
 		flags = unix.MAP_SHARED
 	}
 
+	// Have the kernel reject flags it does not understand (Linux 4.15+)
+	if mf.config.ValidateFlags && flags&unix.MAP_SHARED != 0 {
+		flags = flags&^unix.MAP_SHARED | unix.MAP_SHARED_VALIDATE
+	}
+
+	flags |= mf.config.ExtraMmapFlags
+
 	return prot, flags
 }
 
@@ -184,48 +420,8 @@ func getFD(file interface{}) (uintptr, error) {
 	}
 
 	// Try to find an embedded or wrapped *os.File using reflection
-	v := reflect.ValueOf(file)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-
-	// Look for a field that might contain the os.File
-	// This includes both exported and unexported fields
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		// For unexported fields, we need to use unsafe to access them
-		if !field.CanInterface() {
-			// Create a new value that can be interfaced
-			field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
-		}
-
-		// Check if this field is an *os.File
-		if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-			if osFile, ok := field.Interface().(*os.File); ok {
-				return osFile.Fd(), nil
-			}
-		}
-
-		// Check if field name suggests it's a file (common naming patterns)
-		fieldName := fieldType.Name
-		if (fieldName == "file" || fieldName == "f" || fieldName == "File") && field.Kind() == reflect.Ptr {
-			// Try to extract Fd from this field
-			if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-				if osFile, ok := field.Interface().(*os.File); ok {
-					return osFile.Fd(), nil
-				}
-			}
-		}
-
-		// Check if this field implements the Fd() method
-		if field.CanInterface() {
-			if fdGetter, ok := field.Interface().(interface{ Fd() uintptr }); ok {
-				return fdGetter.Fd(), nil
-			}
-		}
+	if fd, ok := wrappedFD(file); ok {
+		return fd, nil
 	}
 
 	return 0, fmt.Errorf("unable to extract file descriptor from type %T", file)
@@ -234,13 +430,20 @@ func getFD(file interface{}) (uintptr, error) {
 // Advise provides access pattern hints to the kernel.
 // This is a utility function for advanced use cases.
 func (mf *MappedFile) Advise(advice int) error {
-	mf.mu.RLock()
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
 	defer mf.mu.RUnlock()
 
 	if mf.mmapData == nil {
 		return ErrNotMapped
 	}
 
+	// The memory belongs to the Mmapper, not the kernel
+	if mf.mapper != nil {
+		return nil
+	}
+
 	// Use the original mmap'd slice for madvise
 	if err := unix.Madvise(mf.mmapData, advice); err != nil {
 		return fmt.Errorf("madvise failed: %w", err)
@@ -249,6 +452,32 @@ func (mf *MappedFile) Advise(advice int) error {
 	return nil
 }
 
+// AdviseRange is like Advise but applies to the file range [off,
+// off+length) only, rounded out to whole pages. The range must lie within
+// the mapped region (the current window, if windowed).
+func (mf *MappedFile) AdviseRange(off, length int64, advice int) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	defer mf.mu.RUnlock()
+
+	span, err := mf.adviseSpan(off, length)
+	if err != nil {
+		return err
+	}
+
+	// The memory belongs to the Mmapper, not the kernel
+	if mf.mapper != nil {
+		return nil
+	}
+
+	if err := unix.Madvise(span, advice); err != nil {
+		return fmt.Errorf("madvise failed: %w", err)
+	}
+
+	return nil
+}
+
 // AdviseSequential hints that the file will be accessed sequentially.
 func (mf *MappedFile) AdviseSequential() error {
 	return mf.Advise(unix.MADV_SEQUENTIAL)
@@ -281,6 +510,21 @@ func (mf *MappedFile) AdviseNoHugePage() error {
 	return mf.Advise(unix.MADV_NOHUGEPAGE)
 }
 
+// AdviseCollapse synchronously collapses the mapping into transparent huge
+// pages (MADV_COLLAPSE, Linux 6.1+; older kernels fail with EINVAL). Unlike
+// AdviseHugePage it acts on pages already populated, so a region can be
+// opted into huge pages once it is known to be hot. It fails with EAGAIN
+// or ENOMEM if the kernel cannot find huge pages for the range.
+func (mf *MappedFile) AdviseCollapse() error {
+	return mf.Advise(unix.MADV_COLLAPSE)
+}
+
+// AdviseCollapseRange is AdviseCollapse for the file range [off,
+// off+length) only. See AdviseRange.
+func (mf *MappedFile) AdviseCollapseRange(off, length int64) error {
+	return mf.AdviseRange(off, length, unix.MADV_COLLAPSE)
+}
+
 // AdviseFree hints that the pages can be freed (Linux 4.5+).
 // This allows the kernel to reclaim memory without writing dirty pages.
 // Use with caution - data will be lost!
@@ -288,16 +532,41 @@ func (mf *MappedFile) AdviseFree() error {
 	return mf.Advise(unix.MADV_FREE)
 }
 
-// AdviseRemove hints that pages will not be accessed in the near future (Linux).
-// Similar to DontNeed but doesn't free immediately.
-func (mf *MappedFile) AdviseRemove() error {
-	// MADV_REMOVE is Linux-specific
-	const MADV_REMOVE = 9
-	return mf.Advise(MADV_REMOVE)
+// AdviseDontFork hints that the mapping should not be inherited by child
+// processes created by fork, avoiding copy-on-write overhead in the child.
+func (mf *MappedFile) AdviseDontFork() error {
+	return mf.Advise(unix.MADV_DONTFORK)
+}
+
+// AdviseDoFork undoes AdviseDontFork so that children inherit the mapping again.
+func (mf *MappedFile) AdviseDoFork() error {
+	return mf.Advise(unix.MADV_DOFORK)
+}
+
+// AdvisePopulateRead prefaults the mapped pages readable, reading them
+// in from the file without the side effects of MAP_POPULATE (Linux 5.14+;
+// older kernels fail with EINVAL). Unlike AdviseWillNeed it blocks until
+// the pages are resident and reports I/O errors.
+func (mf *MappedFile) AdvisePopulateRead() error {
+	return mf.Advise(unix.MADV_POPULATE_READ)
+}
+
+// AdvisePopulateWrite prefaults the mapped pages writable, as if each had
+// been written to (Linux 5.14+). For shared mappings this does not change
+// the data but may mark the pages dirty; it fails on read-only mappings.
+func (mf *MappedFile) AdvisePopulateWrite() error {
+	return mf.Advise(unix.MADV_POPULATE_WRITE)
+}
+
+// SetNoCache is a no-op on Linux; F_NOCACHE is macOS-specific.
+func (mf *MappedFile) SetNoCache(noCache bool) error {
+	return nil
 }
 
 // Data returns a direct slice to the mapped memory.
 // Use with caution - this provides direct access to the mapped region.
+// The slice is invalidated by Close, window slides and remaps; use Pin to
+// hold it safely.
 // For read-only mappings, modifications will cause a panic.
 func (mf *MappedFile) Data() []byte {
 	mf.mu.RLock()
@@ -3,17 +3,48 @@
 package memmapfs
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"reflect"
 	"syscall"
 	"unsafe"
 
+	"github.com/absfs/absfs"
 	"golang.org/x/sys/windows"
 )
 
+// Windows requires view offsets to be aligned to the allocation granularity.
+// Use a fixed 64KB allocation granularity which is standard for Windows
+// This avoids needing platform-specific syscalls for GetSystemInfo
+const allocGranularity = 64 * 1024 // 64KB
+
+// allocationGranularity returns the alignment required for view offsets.
+func allocationGranularity() int {
+	return allocGranularity
+}
+
+// oNoAtime is the open flag used for Config.NoAtime; Windows has none.
+const oNoAtime = 0
+
+// oDSync is the O_DSYNC open flag; Windows has none, and O_SYNC maps to
+// FILE_FLAG_WRITE_THROUGH.
+const oDSync = 0
+
+// syncDirSupported reports whether directories can be fsynced; Windows
+// cannot open a directory for syncing.
+const syncDirSupported = false
+
 // mmap performs the platform-specific memory mapping using Windows API.
 func (mf *MappedFile) mmap() error {
+	if mf.remote != nil {
+		return mf.mmapRemote()
+	}
+
+	if mf.mapper != nil {
+		protect, access := mf.getProtectionFlags()
+		return mf.mmapMapper(int(protect), int(access))
+	}
+
 	// Get file handle
 	handle, err := getHandle(mf.file)
 	if err != nil {
@@ -41,11 +72,6 @@ func (mf *MappedFile) mmap() error {
 		}
 	}
 
-	// Windows requires page alignment (typically 64KB allocation granularity)
-	// Use a fixed 64KB allocation granularity which is standard for Windows
-	// This avoids needing platform-specific syscalls for GetSystemInfo
-	const allocGranularity = 64 * 1024 // 64KB
-
 	// Align offset to allocation granularity
 	alignedOffset := (mapOffset / allocGranularity) * allocGranularity
 	offsetDiff := mapOffset - alignedOffset
@@ -75,14 +101,21 @@ func (mf *MappedFile) mmap() error {
 	offsetHigh := uint32(alignedOffset >> 32)
 	offsetLow := uint32(alignedOffset)
 
-	addr, err := windows.MapViewOfFile(
-		mappingHandle,
-		access,
-		offsetHigh,
-		offsetLow,
-		uintptr(adjustedMapSize),
-	)
-	if err != nil {
+	var addr uintptr
+	mapOnce := func() error {
+		var mapErr error
+		addr, mapErr = windows.MapViewOfFile(
+			mappingHandle,
+			access,
+			offsetHigh,
+			offsetLow,
+			uintptr(adjustedMapSize),
+		)
+		return mapErr
+	}
+
+	// Retry transient out-of-memory failures if configured
+	if err := mf.retryMmap(mapOnce, mapOnce()); err != nil {
 		windows.CloseHandle(mappingHandle)
 		return fmt.Errorf("MapViewOfFile failed: %w", err)
 	}
@@ -90,8 +123,9 @@ func (mf *MappedFile) mmap() error {
 	// Close mapping handle (the view keeps the mapping alive)
 	windows.CloseHandle(mappingHandle)
 
-	// Convert to byte slice
-	data := unsafe.Slice((*byte)(unsafe.Pointer(addr)), adjustedMapSize)
+	// Convert to byte slice. The view is outside the Go heap, so offset
+	// from nil rather than convert the uintptr directly
+	data := unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(nil), addr)), adjustedMapSize)
 
 	// Store the original mapped slice for unmapping
 	mf.mmapData = data
@@ -112,6 +146,10 @@ func (mf *MappedFile) munmap() error {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.munmapMapper()
+	}
+
 	// Unmap the view
 	addr := uintptr(unsafe.Pointer(&mf.mmapData[0]))
 	if err := windows.UnmapViewOfFile(addr); err != nil {
@@ -123,12 +161,30 @@ func (mf *MappedFile) munmap() error {
 	return nil
 }
 
+// growInPlaceSupported reports whether growInPlace can succeed.
+const growInPlaceSupported = false
+
+// growInPlace is not implemented on Windows; resizes always remap.
+func (mf *MappedFile) growInPlace(newSize int64) bool {
+	return false
+}
+
+// remapAnonymous is not implemented on Windows; anonymous mappings are grown
+// by copying.
+func remapAnonymous(data []byte, size int) ([]byte, bool) {
+	return nil, false
+}
+
 // msync synchronizes dirty pages to disk.
 func (mf *MappedFile) msync() error {
 	if mf.mmapData == nil {
 		return nil
 	}
 
+	if mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
 	// For SyncNever mode, skip sync
 	if mf.config.SyncMode == SyncNever {
 		return nil
@@ -152,6 +208,104 @@ func (mf *MappedFile) msync() error {
 	return nil
 }
 
+// msyncRange writes the pages of span, a page-aligned part of the view,
+// to disk. FlushViewOfFile only queues the range, so the file's buffers
+// are flushed as well; FlushFileBuffers has no range form.
+func (mf *MappedFile) msyncRange(span []byte) error {
+	addr := uintptr(unsafe.Pointer(&span[0]))
+	if err := windows.FlushViewOfFile(addr, uintptr(len(span))); err != nil {
+		return fmt.Errorf("FlushViewOfFile failed: %w", err)
+	}
+	if err := windows.FlushFileBuffers(windows.Handle(mf.fd)); err != nil {
+		return fmt.Errorf("FlushFileBuffers failed: %w", err)
+	}
+	return nil
+}
+
+// SyncInvalidate synchronously writes back dirty pages of the view.
+// Views are always coherent with file I/O on Windows, so flushing the view and
+// the file buffers is sufficient for readers to observe the committed changes.
+func (mf *MappedFile) SyncInvalidate() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.mmapData == nil || mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
+	addr := uintptr(unsafe.Pointer(&mf.mmapData[0]))
+	if err := windows.FlushViewOfFile(addr, uintptr(len(mf.mmapData))); err != nil {
+		return fmt.Errorf("FlushViewOfFile failed: %w", err)
+	}
+
+	if err := windows.FlushFileBuffers(windows.Handle(mf.fd)); err != nil {
+		return fmt.Errorf("FlushFileBuffers failed: %w", err)
+	}
+
+	return nil
+}
+
+// isTransientMmapError reports whether a failed mapping may succeed if retried
+// once other views have been released.
+func isTransientMmapError(err error) bool {
+	return errors.Is(err, windows.ERROR_NOT_ENOUGH_MEMORY) ||
+		errors.Is(err, windows.ERROR_COMMITMENT_LIMIT)
+}
+
+// preallocate grows the file to size bytes, writing zeros over the new
+// region so that its clusters are allocated even on sparse files and
+// filesystems that extend lazily. SetFileValidData would avoid the writes
+// but requires SE_MANAGE_VOLUME_NAME. Holes below the current end of file
+// are not filled.
+func preallocate(file absfs.File, size int64) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+
+	if size <= fi.Size() {
+		return nil
+	}
+
+	if err := writeZeros(file, fi.Size(), size); err != nil {
+		return fmt.Errorf("preallocate failed: %w", err)
+	}
+	return nil
+}
+
+// punchHole zeroes [off, off+length) of the file with FSCTL_SET_ZERO_DATA,
+// which also deallocates the range when the file is sparse.
+func (mf *MappedFile) punchHole(off, length int64) error {
+	zero := struct {
+		FileOffset      int64
+		BeyondFinalZero int64
+	}{off, off + length}
+
+	var returned uint32
+	err := windows.DeviceIoControl(windows.Handle(mf.fd), windows.FSCTL_SET_ZERO_DATA,
+		(*byte)(unsafe.Pointer(&zero)), uint32(unsafe.Sizeof(zero)), nil, 0, &returned, nil)
+	if err != nil {
+		return fmt.Errorf("FSCTL_SET_ZERO_DATA failed: %w", err)
+	}
+	return nil
+}
+
+// protectReadOnly drops write permission from the current view, so that
+// stray writes through Data fault.
+func (mf *MappedFile) protectReadOnly() error {
+	if mf.mmapData == nil || mf.mapper != nil {
+		return nil
+	}
+
+	var old uint32
+	addr := uintptr(unsafe.Pointer(&mf.mmapData[0]))
+	if err := windows.VirtualProtect(addr, uintptr(len(mf.mmapData)), windows.PAGE_READONLY, &old); err != nil {
+		return fmt.Errorf("VirtualProtect failed: %w", err)
+	}
+
+	return nil
+}
+
 // preload provides hints to the OS to load pages into memory.
 // On Windows, this uses PrefetchVirtualMemory if available (Windows 8+).
 func (mf *MappedFile) preload() error {
@@ -167,6 +321,20 @@ func (mf *MappedFile) preload() error {
 	return nil
 }
 
+// mincore is not used on Windows; PreloadSync touches the pages without
+// checking residency.
+func mincore(b []byte, vec []byte) error {
+	return errors.ErrUnsupported
+}
+
+// readAheadSupported reports whether readAhead does anything.
+const readAheadSupported = false
+
+// readAhead is not implemented on Windows; Config.ReadAheadWindows is ignored.
+func (mf *MappedFile) readAhead(off, length int64) error {
+	return nil
+}
+
 // getProtectionFlags returns the protection and access flags for Windows mapping.
 func (mf *MappedFile) getProtectionFlags() (protect uint32, access uint32) {
 	switch mf.config.Mode {
@@ -179,6 +347,14 @@ func (mf *MappedFile) getProtectionFlags() (protect uint32, access uint32) {
 	case ModeCopyOnWrite:
 		protect = windows.PAGE_WRITECOPY
 		access = windows.FILE_MAP_COPY
+	case ModeReadExec:
+		// Requires the file handle to have been opened with execute access
+		protect = windows.PAGE_EXECUTE_READ
+		access = windows.FILE_MAP_READ | windows.FILE_MAP_EXECUTE
+	case ModeReadWriteExec:
+		// Requires the file handle to have been opened with execute access
+		protect = windows.PAGE_EXECUTE_READWRITE
+		access = windows.FILE_MAP_WRITE | windows.FILE_MAP_EXECUTE
 	default:
 		protect = windows.PAGE_READONLY
 		access = windows.FILE_MAP_READ
@@ -204,47 +380,8 @@ func getHandle(file interface{}) (syscall.Handle, error) {
 	}
 
 	// Try to find an embedded or wrapped *os.File using reflection
-	v := reflect.ValueOf(file)
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-	}
-
-	// Look for a field that might contain the os.File
-	t := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		// For unexported fields, we need to use unsafe to access them
-		if !field.CanInterface() {
-			// Create a new value that can be interfaced
-			field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
-		}
-
-		// Check if this field is an *os.File
-		if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-			if osFile, ok := field.Interface().(*os.File); ok {
-				return syscall.Handle(osFile.Fd()), nil
-			}
-		}
-
-		// Check if field name suggests it's a file (common naming patterns)
-		fieldName := fieldType.Name
-		if (fieldName == "file" || fieldName == "f" || fieldName == "File") && field.Kind() == reflect.Ptr {
-			// Try to extract Fd from this field
-			if field.Type() == reflect.TypeOf((*os.File)(nil)) {
-				if osFile, ok := field.Interface().(*os.File); ok {
-					return syscall.Handle(osFile.Fd()), nil
-				}
-			}
-		}
-
-		// Check if this field implements the Fd() method
-		if field.CanInterface() {
-			if fdGetter, ok := field.Interface().(interface{ Fd() uintptr }); ok {
-				return syscall.Handle(fdGetter.Fd()), nil
-			}
-		}
+	if fd, ok := wrappedFD(file); ok {
+		return syscall.Handle(fd), nil
 	}
 
 	return 0, fmt.Errorf("unable to extract file handle from type %T", file)
@@ -253,18 +390,38 @@ func getHandle(file interface{}) (syscall.Handle, error) {
 // Advise provides access pattern hints to the kernel.
 // Windows doesn't have a direct equivalent to madvise, so this is mostly a no-op.
 func (mf *MappedFile) Advise(advice int) error {
-	mf.mu.RLock()
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
 	defer mf.mu.RUnlock()
 
 	if mf.mmapData == nil {
 		return ErrNotMapped
 	}
 
+	// The memory belongs to the Mmapper, not the kernel
+	if mf.mapper != nil {
+		return nil
+	}
+
 	// Windows doesn't have madvise equivalent
 	// Most hints are handled automatically by the OS
 	return nil
 }
 
+// AdviseRange is like Advise but applies to the file range [off,
+// off+length) only. The range must lie within the mapped region; the
+// advice itself is a no-op on Windows.
+func (mf *MappedFile) AdviseRange(off, length int64, advice int) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	defer mf.mu.RUnlock()
+
+	_, err := mf.adviseSpan(off, length)
+	return err
+}
+
 // AdviseSequential hints that the file will be accessed sequentially.
 // This is a no-op on Windows.
 func (mf *MappedFile) AdviseSequential() error {
@@ -301,20 +458,61 @@ func (mf *MappedFile) AdviseNoHugePage() error {
 	return nil
 }
 
+// AdviseCollapse is a no-op on Windows.
+func (mf *MappedFile) AdviseCollapse() error {
+	return nil
+}
+
+// AdviseCollapseRange is a no-op on Windows, other than checking the range.
+func (mf *MappedFile) AdviseCollapseRange(off, length int64) error {
+	if err := mf.rlockMapped(); err != nil {
+		return err
+	}
+	defer mf.mu.RUnlock()
+
+	_, err := mf.adviseSpan(off, length)
+	return err
+}
+
 // AdviseFree hints that the pages can be freed.
 // This is a no-op on Windows.
 func (mf *MappedFile) AdviseFree() error {
 	return nil
 }
 
-// AdviseRemove hints that pages will not be accessed in the near future.
+// AdviseDontFork hints that child processes should not inherit the mapping.
+// This is a no-op on Windows (there is no fork).
+func (mf *MappedFile) AdviseDontFork() error {
+	return nil
+}
+
+// AdviseDoFork hints that child processes should inherit the mapping.
+// This is a no-op on Windows (there is no fork).
+func (mf *MappedFile) AdviseDoFork() error {
+	return nil
+}
+
+// AdvisePopulateRead prefaults the mapped pages readable.
 // This is a no-op on Windows.
-func (mf *MappedFile) AdviseRemove() error {
+func (mf *MappedFile) AdvisePopulateRead() error {
+	return nil
+}
+
+// AdvisePopulateWrite prefaults the mapped pages writable.
+// This is a no-op on Windows.
+func (mf *MappedFile) AdvisePopulateWrite() error {
+	return nil
+}
+
+// SetNoCache is a no-op on Windows; F_NOCACHE is macOS-specific.
+func (mf *MappedFile) SetNoCache(noCache bool) error {
 	return nil
 }
 
 // Data returns a direct slice to the mapped memory.
 // Use with caution - this provides direct access to the mapped region.
+// The slice is invalidated by Close, window slides and remaps; use Pin to
+// hold it safely.
 func (mf *MappedFile) Data() []byte {
 	mf.mu.RLock()
 	defer mf.mu.RUnlock()
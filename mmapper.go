@@ -0,0 +1,61 @@
+package memmapfs
+
+import (
+	"fmt"
+)
+
+// Mmapper can be implemented by the files of an underlying filesystem that
+// can map their contents without an operating system descriptor, such as
+// in-memory backends. When the opened file implements it, memmapfs calls it
+// instead of mmap(2), so windowing, Remap and the read/write paths work as
+// usual. prot and flags are the values the platform would use (PROT_* and
+// MAP_* on Unix; the CreateFileMapping protection and MapViewOfFile access
+// on Windows). Any offset may be requested; no alignment is applied.
+//
+// Kernel advice and descriptor operations (Advise, AdviseRemove hole
+// punching, SetNoCache) are skipped for such files, and syncing calls the
+// file's Sync.
+type Mmapper interface {
+	Mmap(off, length int64, prot, flags int) ([]byte, error)
+	Munmap(b []byte) error
+}
+
+// mmapMapper maps the current window through the file's Mmapper.
+func (mf *MappedFile) mmapMapper(prot, flags int) error {
+	mapSize := mf.size
+	mapOffset := int64(0)
+
+	if mf.windowSize > 0 {
+		mapOffset = mf.windowOffset
+		mapSize = mf.windowSize
+
+		// Don't map beyond end of file
+		if mapOffset+mapSize > mf.size {
+			mapSize = mf.size - mapOffset
+		}
+	}
+
+	data, err := mf.mapper.Mmap(mapOffset, mapSize, prot, flags)
+	if err != nil {
+		return fmt.Errorf("mmap failed: %w", err)
+	}
+	if int64(len(data)) != mapSize {
+		mf.mapper.Munmap(data)
+		return fmt.Errorf("mmap failed: Mmapper returned %d bytes, want %d", len(data), mapSize)
+	}
+
+	mf.mmapData = data
+	mf.data = data
+	return nil
+}
+
+// munmapMapper releases a mapping made by mmapMapper.
+func (mf *MappedFile) munmapMapper() error {
+	if err := mf.mapper.Munmap(mf.mmapData); err != nil {
+		return fmt.Errorf("munmap failed: %w", err)
+	}
+
+	mf.mmapData = nil
+	mf.data = nil
+	return nil
+}
@@ -0,0 +1,193 @@
+package memmapfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// MultiMappedFile presents several files as one contiguous, read-only
+// logical file. Offsets are routed to the segment that contains them, so a
+// dataset that is physically split into chunks can be read as a whole.
+type MultiMappedFile struct {
+	files   []absfs.File // Segments in logical order
+	offsets []int64      // Logical offset where each segment starts
+	sizes   []int64      // Size of each segment
+	size    int64        // Sum of all segment sizes
+
+	mu       sync.RWMutex // Protects the fields below
+	position int64
+	closed   bool
+}
+
+// OpenMulti opens and maps each named file and returns a MultiMappedFile
+// over their concatenation, in the order given.
+func (mfs *MemMapFS) OpenMulti(names []string) (*MultiMappedFile, error) {
+	m := &MultiMappedFile{
+		files:   make([]absfs.File, 0, len(names)),
+		offsets: make([]int64, 0, len(names)),
+		sizes:   make([]int64, 0, len(names)),
+	}
+
+	for _, name := range names {
+		file, err := mfs.Open(name)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+
+		fi, err := file.Stat()
+		if err != nil {
+			file.Close()
+			m.Close()
+			return nil, fmt.Errorf("stat %s failed: %w", name, err)
+		}
+
+		m.files = append(m.files, file)
+		m.offsets = append(m.offsets, m.size)
+		m.sizes = append(m.sizes, fi.Size())
+		m.size += fi.Size()
+	}
+
+	return m, nil
+}
+
+// Size returns the combined size of all segments.
+func (m *MultiMappedFile) Size() int64 {
+	return m.size
+}
+
+// segmentFor returns the index of the segment containing off.
+// Empty segments are never selected.
+func (m *MultiMappedFile) segmentFor(off int64) int {
+	return sort.Search(len(m.offsets), func(i int) bool {
+		return m.offsets[i]+m.sizes[i] > off
+	})
+}
+
+// ReadAt reads len(p) bytes at the logical offset off, spanning segment
+// boundaries as needed. It does not change the read position.
+func (m *MultiMappedFile) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	return m.readAtLocked(p, off)
+}
+
+// readAtLocked implements ReadAt. The caller must hold m.mu.
+func (m *MultiMappedFile) readAtLocked(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	n := 0
+	for n < len(p) && off < m.size {
+		i := m.segmentFor(off)
+		segOff := off - m.offsets[i]
+
+		buf := p[n:]
+		if remaining := m.sizes[i] - segOff; int64(len(buf)) > remaining {
+			buf = buf[:remaining]
+		}
+
+		k, err := m.files[i].ReadAt(buf, segOff)
+		n += k
+		off += int64(k)
+
+		if err != nil && !(err == io.EOF && k == len(buf)) {
+			return n, err
+		}
+		if k < len(buf) {
+			return n, io.ErrUnexpectedEOF
+		}
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Read reads from the current position and advances it.
+func (m *MultiMappedFile) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	if m.position >= m.size {
+		return 0, io.EOF
+	}
+
+	n, err := m.readAtLocked(p, m.position)
+	m.position += int64(n)
+
+	// EOF will be returned on the next call when position >= size
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// Seek sets the logical position for the next Read.
+func (m *MultiMappedFile) Seek(offset int64, whence int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var newPos int64
+
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = m.position + offset
+	case io.SeekEnd:
+		newPos = m.size + offset
+	default:
+		return 0, ErrInvalidWhence
+	}
+
+	if newPos < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	m.position = newPos
+	return newPos, nil
+}
+
+// Close closes every segment and returns the first error encountered.
+func (m *MultiMappedFile) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return os.ErrClosed
+	}
+	m.closed = true
+
+	var err error
+	for _, f := range m.files {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	m.files = nil
+	return err
+}
+
+// Ensure MultiMappedFile implements the standard reader interfaces
+var (
+	_ io.ReaderAt   = (*MultiMappedFile)(nil)
+	_ io.ReadSeeker = (*MultiMappedFile)(nil)
+	_ io.Closer     = (*MultiMappedFile)(nil)
+)
@@ -0,0 +1,339 @@
+package memmapfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// OverlayFile is a writable view of a file that is never modified: the base
+// file is mapped read-only and every write goes to a separate overlay file
+// at the same offset instead. Reads return overlay bytes wherever a write
+// has landed and base bytes everywhere else, which is what a union or
+// overlay mount needs from its lower layer.
+//
+// The overlay file is sparse where the filesystem supports it: only the
+// written ranges are allocated. Which ranges hold overlay data is tracked in
+// memory for the life of the OverlayFile and is not recorded in the overlay
+// file itself; use Ranges to persist it if the overlay must be reopened.
+//
+// OverlayFile is a type of its own rather than a Mode of MappedFile, since
+// its reads and writes go to two files. The base is always read as the raw
+// file: Config.Decompressor is not applied to it.
+type OverlayFile struct {
+	base     absfs.File // Read-only mapping of the base file
+	baseSize int64
+	upper    absfs.File // Overlay file receiving writes
+
+	mu       sync.RWMutex // Protects the fields below
+	ranges   []OverlayRange
+	size     int64
+	position int64
+	closed   bool
+}
+
+// OverlayRange is a range of an OverlayFile whose contents come from the
+// overlay file rather than the base.
+type OverlayRange struct {
+	Off int64
+	Len int64
+}
+
+// OpenOverlay maps the named base file read-only and creates (or
+// truncates) the overlay file, returning an OverlayFile whose writes go to
+// the overlay. The base file is opened with the filesystem's configuration
+// except that Mode is ModeReadOnly, so it is never written, and without a
+// Decompressor. An overlay that is the base file itself, by the same path,
+// a symlink or a hard link, is rejected with ErrOverlayIsBase.
+func (mfs *MemMapFS) OpenOverlay(name, overlay string) (*OverlayFile, error) {
+	config := *mfs.config
+	config.Mode = ModeReadOnly
+	config.Decompressor = nil
+
+	ro := &MemMapFS{underlying: mfs.underlying, config: &config, open: mfs.open}
+	base, err := ro.openFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := base.Stat()
+	if err == nil && fi.IsDir() {
+		err = fmt.Errorf("%s is a directory", name)
+	}
+	if err != nil {
+		base.Close()
+		return nil, err
+	}
+
+	// Truncating the overlay must not destroy the base
+	if ofi, err := mfs.underlying.Stat(overlay); err == nil && os.SameFile(fi, ofi) {
+		base.Close()
+		return nil, fmt.Errorf("%s: %w", overlay, ErrOverlayIsBase)
+	}
+
+	upper, err := mfs.openUnderlyingFlags(overlay, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		base.Close()
+		return nil, err
+	}
+
+	// Extend without writing, so unwritten ranges stay holes
+	if err := upper.Truncate(fi.Size()); err != nil {
+		upper.Close()
+		base.Close()
+		return nil, fmt.Errorf("%s: grow failed: %w", overlay, err)
+	}
+
+	return &OverlayFile{base: base, baseSize: fi.Size(), upper: upper, size: fi.Size()}, nil
+}
+
+// Name returns the name of the base file.
+func (o *OverlayFile) Name() string {
+	return o.base.Name()
+}
+
+// Size returns the size of the file, which grows past the base size when a
+// write extends it.
+func (o *OverlayFile) Size() int64 {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.size
+}
+
+// Ranges returns the ranges written so far, in offset order. Adjacent and
+// overlapping writes are merged.
+func (o *OverlayFile) Ranges() []OverlayRange {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return append([]OverlayRange(nil), o.ranges...)
+}
+
+// ReadAt reads len(p) bytes at off, taking each byte from the overlay if it
+// has been written and from the base otherwise. Bytes past the end of the
+// base that were never written, left by a write beyond it, read as zeros.
+func (o *OverlayFile) ReadAt(p []byte, off int64) (int, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.closed {
+		return 0, os.ErrClosed
+	}
+	return o.readAtLocked(p, off)
+}
+
+// readAtLocked implements ReadAt. The caller must hold o.mu.
+func (o *OverlayFile) readAtLocked(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if off >= o.size {
+		return 0, io.EOF
+	}
+
+	n := int(min(int64(len(p)), o.size-off))
+	end := off + int64(n)
+
+	// First range that ends after off
+	i := sort.Search(len(o.ranges), func(i int) bool {
+		r := o.ranges[i]
+		return r.Off+r.Len > off
+	})
+
+	for pos := off; pos < end; {
+		from, to := end, end
+		if i < len(o.ranges) && o.ranges[i].Off < end {
+			from = max(o.ranges[i].Off, pos)
+			to = min(o.ranges[i].Off+o.ranges[i].Len, end)
+		}
+
+		if pos < from {
+			if err := o.readBase(p[pos-off:from-off], pos); err != nil {
+				return int(pos - off), err
+			}
+		}
+		if from < to {
+			if err := readFull(o.upper, p[from-off:to-off], from); err != nil {
+				return int(from - off), err
+			}
+		}
+
+		pos = to
+		i++
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readBase fills p from the base file at off, with zeros past its end.
+func (o *OverlayFile) readBase(p []byte, off int64) error {
+	k := int(max(0, min(int64(len(p)), o.baseSize-off)))
+	clear(p[k:])
+	if k == 0 {
+		return nil
+	}
+	return readFull(o.base, p[:k], off)
+}
+
+// readFull reads exactly len(p) bytes from f at off.
+func readFull(f io.ReaderAt, p []byte, off int64) error {
+	k, err := f.ReadAt(p, off)
+	if k == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Read reads from the current position and advances it.
+func (o *OverlayFile) Read(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, os.ErrClosed
+	}
+	if o.position >= o.size {
+		return 0, io.EOF
+	}
+
+	n, err := o.readAtLocked(p, o.position)
+	o.position += int64(n)
+
+	// EOF will be returned on the next call when position >= size
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// WriteAt writes p to the overlay file at off and records the range as
+// overlay data. The base file is not touched. Writing past the end extends
+// the file.
+func (o *OverlayFile) WriteAt(p []byte, off int64) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, os.ErrClosed
+	}
+	return o.writeAtLocked(p, off)
+}
+
+// writeAtLocked implements WriteAt. The caller must hold o.mu.
+func (o *OverlayFile) writeAtLocked(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	n, err := o.upper.WriteAt(p, off)
+	if n > 0 {
+		o.addRange(off, int64(n))
+		o.size = max(o.size, off+int64(n))
+	}
+	return n, err
+}
+
+// addRange records [off, off+length) as overlay data, merging it with any
+// ranges it overlaps or touches. The caller must hold o.mu.
+func (o *OverlayFile) addRange(off, length int64) {
+	end := off + length
+
+	// Ranges [i, j) overlap or touch the new one
+	i := sort.Search(len(o.ranges), func(i int) bool {
+		r := o.ranges[i]
+		return r.Off+r.Len >= off
+	})
+	j := i
+	for j < len(o.ranges) && o.ranges[j].Off <= end {
+		off = min(off, o.ranges[j].Off)
+		end = max(end, o.ranges[j].Off+o.ranges[j].Len)
+		j++
+	}
+
+	merged := OverlayRange{Off: off, Len: end - off}
+	o.ranges = append(o.ranges[:i], append([]OverlayRange{merged}, o.ranges[j:]...)...)
+}
+
+// Write writes at the current position and advances it.
+func (o *OverlayFile) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err := o.writeAtLocked(p, o.position)
+	o.position += int64(n)
+	return n, err
+}
+
+// Seek sets the position for the next Read or Write.
+func (o *OverlayFile) Seek(offset int64, whence int) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var newPos int64
+
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = o.position + offset
+	case io.SeekEnd:
+		newPos = o.size + offset
+	default:
+		return 0, ErrInvalidWhence
+	}
+
+	if newPos < 0 {
+		return 0, ErrInvalidOffset
+	}
+
+	o.position = newPos
+	return newPos, nil
+}
+
+// Sync flushes the overlay file to stable storage.
+func (o *OverlayFile) Sync() error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.closed {
+		return os.ErrClosed
+	}
+	return o.upper.Sync()
+}
+
+// Ensure OverlayFile implements the io interfaces it is used through
+var (
+	_ io.ReadWriteSeeker = (*OverlayFile)(nil)
+	_ io.ReaderAt        = (*OverlayFile)(nil)
+	_ io.WriterAt        = (*OverlayFile)(nil)
+	_ io.Closer          = (*OverlayFile)(nil)
+)
+
+// Close closes the overlay file and unmaps the base. The overlay is not
+// synced; call Sync first if it must be durable.
+func (o *OverlayFile) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return os.ErrClosed
+	}
+	o.closed = true
+
+	return errors.Join(o.upper.Close(), o.base.Close())
+}
@@ -0,0 +1,40 @@
+package memmapfs
+
+// Pin returns the currently mapped region, like Data, and guarantees that
+// it stays mapped until a matching Unpin: while any pin is outstanding the
+// window does not slide, Remap, Drain and growth fail with ErrPinned, and
+// Close blocks. For windowed mappings this means accesses outside the
+// pinned window fail with ErrPinned, so keep pins short-lived.
+//
+// Every successful Pin must be matched by exactly one Unpin.
+func (mf *MappedFile) Pin() ([]byte, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return nil, err
+	}
+
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	mf.pins++
+	return mf.data, nil
+}
+
+// Unpin releases a slice obtained from Pin. The slice must not be used
+// afterwards.
+func (mf *MappedFile) Unpin() {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.pins == 0 {
+		panic("memmapfs: Unpin without matching Pin")
+	}
+
+	mf.pins--
+	if mf.pins == 0 {
+		mf.unpinned.Broadcast()
+	}
+}
@@ -0,0 +1,76 @@
+package memmapfs
+
+import (
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// pooledBuffers holds copy buffers for ReadAtPooled, one pool per
+// power-of-two capacity class.
+var pooledBuffers [bits.UintSize]sync.Pool
+
+// getPooledBuffer returns a buffer of length n from the pool of the
+// smallest class that fits it. Buffers in the top class, whose capacity
+// would not fit in an int, get exactly n bytes.
+func getPooledBuffer(n int) *[]byte {
+	class := bits.Len(uint(n - 1))
+	if b, ok := pooledBuffers[class].Get().(*[]byte); ok && cap(*b) >= n {
+		*b = (*b)[:n]
+		return b
+	}
+
+	capacity := n
+	if class < bits.UintSize-1 {
+		capacity = 1 << class
+	}
+	buf := make([]byte, n, capacity)
+	return &buf
+}
+
+// putPooledBuffer returns a buffer from getPooledBuffer to its pool.
+func putPooledBuffer(b *[]byte) {
+	pooledBuffers[bits.Len(uint(cap(*b)-1))].Put(b)
+}
+
+// ReadAtPooled copies length bytes at offset off into a buffer taken from
+// an internal sync.Pool and returns it with a release function. Unlike Data
+// the copy is mutable and independent of the mapping; unlike ReadAt into a
+// fresh slice it does not allocate in steady state. Call release exactly
+// once when done; the buffer must not be used afterwards.
+//
+// If the range extends past the end of the file, the available bytes are
+// returned together with io.EOF; the buffer is only as long as they are.
+// Offsets at or past the end return io.EOF and no buffer.
+func (mf *MappedFile) ReadAtPooled(off, length int64) ([]byte, func(), error) {
+	if length < 0 || off < 0 {
+		return nil, nil, ErrInvalidOffset
+	}
+	if length == 0 {
+		return []byte{}, func() {}, nil
+	}
+
+	size := mf.Size()
+	if off >= size {
+		return nil, nil, io.EOF
+	}
+	var eof error
+	if length > size-off {
+		length = size - off
+		eof = io.EOF
+	}
+
+	b := getPooledBuffer(int(length))
+	release := func() { putPooledBuffer(b) }
+
+	n, err := mf.ReadAtv([][]byte{*b}, off)
+	if err != nil && !(err == io.EOF && n > 0) {
+		release()
+		return nil, nil, err
+	}
+	if err == nil {
+		err = eof
+	}
+
+	return (*b)[:n], release, err
+}
@@ -0,0 +1,153 @@
+package memmapfs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MappedReader reads a mapped file the way bytes.Reader reads a slice,
+// directly from the mapping and without copying it first, for code written
+// against bytes.Reader. It holds a pin on the mapping until Close, so the
+// file cannot be resized, remapped or closed under it.
+//
+// As with bytes.Reader, only ReadAt may be called concurrently. Close must
+// not overlap any other call.
+type MappedReader struct {
+	mf     *MappedFile
+	r      *bytes.Reader
+	closed bool
+}
+
+// Reader returns a MappedReader over the whole file, starting at offset 0
+// independently of the file position. It fails with ErrNotMapped if the
+// file is not mapped and with ErrWindowedMapping for a windowed file,
+// whose mapping does not hold the whole file.
+func (mf *MappedFile) Reader() (*MappedReader, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if err := mf.undrainLocked(); err != nil {
+		return nil, err
+	}
+
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+	if mf.windowSize > 0 {
+		return nil, fmt.Errorf("%s: %w", mf.file.Name(), ErrWindowedMapping)
+	}
+
+	mf.pins++
+	return &MappedReader{mf: mf, r: bytes.NewReader(mf.data)}, nil
+}
+
+// Len returns the number of bytes of the unread portion of the file.
+func (mr *MappedReader) Len() int {
+	if mr.closed {
+		return 0
+	}
+	return mr.r.Len()
+}
+
+// Size returns the size of the file. It is the number of bytes available
+// for reading via ReadAt and is not affected by other methods.
+func (mr *MappedReader) Size() int64 {
+	return mr.r.Size()
+}
+
+// Read reads up to len(p) bytes from the mapping into p.
+func (mr *MappedReader) Read(p []byte) (int, error) {
+	if mr.closed {
+		return 0, os.ErrClosed
+	}
+	return mr.r.Read(p)
+}
+
+// ReadAt reads len(p) bytes from the mapping at off. It does not change
+// the read position.
+func (mr *MappedReader) ReadAt(p []byte, off int64) (int, error) {
+	if mr.closed {
+		return 0, os.ErrClosed
+	}
+	return mr.r.ReadAt(p, off)
+}
+
+// ReadByte reads and returns the next byte.
+func (mr *MappedReader) ReadByte() (byte, error) {
+	if mr.closed {
+		return 0, os.ErrClosed
+	}
+	return mr.r.ReadByte()
+}
+
+// UnreadByte steps the read position back by one byte.
+func (mr *MappedReader) UnreadByte() error {
+	if mr.closed {
+		return os.ErrClosed
+	}
+	return mr.r.UnreadByte()
+}
+
+// ReadRune reads and returns the next UTF-8 encoded rune and its size.
+func (mr *MappedReader) ReadRune() (rune, int, error) {
+	if mr.closed {
+		return 0, 0, os.ErrClosed
+	}
+	return mr.r.ReadRune()
+}
+
+// UnreadRune steps the read position back over the rune last read by
+// ReadRune.
+func (mr *MappedReader) UnreadRune() error {
+	if mr.closed {
+		return os.ErrClosed
+	}
+	return mr.r.UnreadRune()
+}
+
+// Seek sets the read position.
+func (mr *MappedReader) Seek(offset int64, whence int) (int64, error) {
+	if mr.closed {
+		return 0, os.ErrClosed
+	}
+	return mr.r.Seek(offset, whence)
+}
+
+// WriteTo writes the unread portion of the file to w straight from the
+// mapping.
+func (mr *MappedReader) WriteTo(w io.Writer) (int64, error) {
+	if mr.closed {
+		return 0, os.ErrClosed
+	}
+	return mr.r.WriteTo(w)
+}
+
+// BytesReader returns the *bytes.Reader over the mapping that mr reads
+// through, for code that type-asserts for one. It shares mr's read
+// position and must not be used after Close.
+func (mr *MappedReader) BytesReader() *bytes.Reader {
+	return mr.r
+}
+
+// Ensure MappedReader implements the interfaces bytes.Reader does, and io.Closer
+var (
+	_ io.ReadSeeker  = (*MappedReader)(nil)
+	_ io.ReaderAt    = (*MappedReader)(nil)
+	_ io.ByteScanner = (*MappedReader)(nil)
+	_ io.RuneScanner = (*MappedReader)(nil)
+	_ io.WriterTo    = (*MappedReader)(nil)
+	_ io.Closer      = (*MappedReader)(nil)
+)
+
+// Close releases the reader's pin on the mapping. The file itself stays
+// open.
+func (mr *MappedReader) Close() error {
+	if mr.closed {
+		return os.ErrClosed
+	}
+	mr.closed = true
+	mr.mf.Unpin()
+	return nil
+}
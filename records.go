@@ -0,0 +1,97 @@
+package memmapfs
+
+import "fmt"
+
+// Records returns an iterator over the file as consecutive fixed-size
+// records, for fixed-width binary formats. Each record is yielded with a nil
+// error; if a record cannot be read the iterator yields the error and stops.
+// The iterator has the shape of iter.Seq2, so with Go 1.23+ it can be used
+// directly in a range statement.
+//
+// Records are zero-copy aliases into the mapping, valid until the next
+// record is requested or the window slides. A record that straddles a
+// window boundary is copied into a buffer reused for such records instead;
+// choose a WindowSize that is a multiple of recordSize to avoid copies.
+// Like Data, aliases into read-only mappings must not be modified.
+//
+// If the file size is not a multiple of recordSize, Records returns
+// ErrPartialRecord unless allowShort is set, in which case the final record
+// is shorter.
+func (mf *MappedFile) Records(recordSize int, allowShort bool) (func(yield func([]byte, error) bool), error) {
+	if recordSize <= 0 {
+		return nil, fmt.Errorf("invalid record size %d", recordSize)
+	}
+
+	if err := mf.rlockMapped(); err != nil {
+		return nil, err
+	}
+	size := mf.size
+	mapped := mf.data != nil
+	mf.mu.RUnlock()
+
+	if !mapped {
+		return nil, ErrNotMapped
+	}
+
+	if size%int64(recordSize) != 0 && !allowShort {
+		return nil, ErrPartialRecord
+	}
+
+	return func(yield func([]byte, error) bool) {
+		var scratch []byte
+		for off := int64(0); off < size; off += int64(recordSize) {
+			length := int64(recordSize)
+			if off+length > size {
+				length = size - off
+			}
+
+			rec, err := mf.record(off, length)
+			if err == nil && rec == nil {
+				// Straddles a window boundary
+				if scratch == nil {
+					scratch = make([]byte, recordSize)
+				}
+				rec = scratch[:length]
+				_, err = mf.ReadAtv([][]byte{rec}, off)
+			}
+
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(rec, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// record returns an alias of [off, off+length) in the mapping, or nil if
+// the range does not fit in a single window.
+func (mf *MappedFile) record(off, length int64) ([]byte, error) {
+	// For windowing, we need write lock to potentially slide window
+	exclusive, err := mf.lockForAccess()
+	if err != nil {
+		return nil, err
+	}
+	defer mf.unlockAccess(exclusive)
+
+	if mf.data == nil {
+		return nil, ErrNotMapped
+	}
+
+	// For windowed mapping, ensure window contains offset
+	if mf.windowSize > 0 {
+		if err := mf.ensureInWindow(off); err != nil {
+			return nil, err
+		}
+	}
+
+	windowOff := mf.fileOffsetToWindowOffset(off)
+	if windowOff+length > int64(len(mf.data)) {
+		return nil, nil
+	}
+
+	return mf.data[windowOff : windowOff+length : windowOff+length], nil
+}
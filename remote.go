@@ -0,0 +1,121 @@
+package memmapfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// RemoteBackend serves byte ranges of a file that has no local descriptor,
+// for example over HTTP Range requests.
+type RemoteBackend interface {
+	// Size returns the total size of the remote file.
+	Size() (int64, error)
+
+	// ReadRange returns a reader over length bytes starting at off.
+	// The reader is closed once the range has been consumed.
+	ReadRange(off, length int64) (io.ReadCloser, error)
+}
+
+// OpenRemote returns a read-only MappedFile over backend. Each window is
+// fetched with a single ReadRange call into anonymous memory when the
+// window slides to it, so the usual windowing configuration (MapFullFile,
+// WindowSize, AdaptiveWindow) controls the request size. The file must be
+// opened with ModeReadOnly. Writes fail with ErrWriteToReadOnlyMap.
+func (mfs *MemMapFS) OpenRemote(name string, backend RemoteBackend) (*MappedFile, error) {
+	if mfs.config.Mode != ModeReadOnly {
+		return nil, ErrRemoteMode
+	}
+
+	size, err := backend.Size()
+	if err != nil {
+		return nil, fmt.Errorf("remote size failed: %w", err)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("remote file %s is empty: %w", name, ErrNotMapped)
+	}
+	if err := mfs.checkFileSize(name, size); err != nil {
+		return nil, err
+	}
+
+	file := &detachedFile{
+		name: name,
+		info: remoteFileInfo{name: path.Base(name), size: size},
+	}
+
+	mf := allocMappedFile(file, mfs.config, size, nil)
+	mf.remote = backend
+
+	if err := mf.mmap(); err != nil {
+		return nil, err
+	}
+	mfs.open.add(mf)
+
+	return mf, nil
+}
+
+// mmapRemote fetches the current window from the remote backend into a
+// fresh anonymous mapping, which is then made read-only.
+func (mf *MappedFile) mmapRemote() error {
+	mapSize := mf.size
+	mapOffset := int64(0)
+
+	if mf.windowSize > 0 {
+		mapOffset = mf.windowOffset
+		mapSize = mf.windowSize
+
+		// Don't fetch beyond end of file
+		if mapOffset+mapSize > mf.size {
+			mapSize = mf.size - mapOffset
+		}
+	}
+
+	data, err := mapAnonymous(int(mapSize))
+	if err != nil {
+		return fmt.Errorf("anonymous mmap failed: %w", err)
+	}
+
+	if err := fetchRange(mf.remote, data, mapOffset); err != nil {
+		unmapAnonymous(data)
+		return err
+	}
+
+	if err := protectReadOnly(data); err != nil {
+		unmapAnonymous(data)
+		return fmt.Errorf("mprotect failed: %w", err)
+	}
+
+	mf.mmapData = data
+	mf.data = data
+	return nil
+}
+
+// fetchRange fills p with the bytes of backend starting at off.
+func fetchRange(backend RemoteBackend, p []byte, off int64) error {
+	rc, err := backend.ReadRange(off, int64(len(p)))
+	if err != nil {
+		return fmt.Errorf("remote read at %d failed: %w", off, err)
+	}
+	defer rc.Close()
+
+	if _, err := io.ReadFull(rc, p); err != nil {
+		return fmt.Errorf("remote read at %d failed: %w", off, err)
+	}
+
+	return nil
+}
+
+// remoteFileInfo describes a remote file for Stat.
+type remoteFileInfo struct {
+	name string
+	size int64
+}
+
+func (fi remoteFileInfo) Name() string       { return fi.name }
+func (fi remoteFileInfo) Size() int64        { return fi.size }
+func (fi remoteFileInfo) Mode() fs.FileMode  { return 0444 }
+func (fi remoteFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi remoteFileInfo) IsDir() bool        { return false }
+func (fi remoteFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package memmapfs
+
+import "golang.org/x/sys/unix"
+
+// mapAnonymous returns size bytes of zeroed, writable anonymous memory.
+func mapAnonymous(size int) ([]byte, error) {
+	return unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_SHARED)
+}
+
+// mapPrivateAnonymous returns size bytes of zeroed, writable anonymous
+// memory private to the process. Unlike a shared mapping's, its size is not
+// fixed, so remapAnonymous can grow it.
+func mapPrivateAnonymous(size int) ([]byte, error) {
+	return unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+}
+
+// protectReadOnly makes an anonymous mapping read-only.
+func protectReadOnly(data []byte) error {
+	return unix.Mprotect(data, unix.PROT_READ)
+}
+
+// unmapAnonymous releases memory from mapAnonymous.
+func unmapAnonymous(data []byte) error {
+	return unix.Munmap(data)
+}
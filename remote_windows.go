@@ -0,0 +1,54 @@
+//go:build windows
+
+package memmapfs
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// mapAnonymous returns size bytes of zeroed, writable memory backed by the
+// paging file.
+func mapAnonymous(size int) ([]byte, error) {
+	mappingHandle, err := windows.CreateFileMapping(
+		windows.InvalidHandle,
+		nil,
+		windows.PAGE_READWRITE,
+		uint32(uint64(size)>>32),
+		uint32(size),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The view keeps the mapping alive
+	defer windows.CloseHandle(mappingHandle)
+
+	addr, err := windows.MapViewOfFile(mappingHandle, windows.FILE_MAP_WRITE, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, err
+	}
+
+	// The view is outside the Go heap; offset from nil rather than convert
+	// the uintptr directly, as mmap does
+	return unsafe.Slice((*byte)(unsafe.Add(unsafe.Pointer(nil), addr)), size), nil
+}
+
+// mapPrivateAnonymous returns size bytes of zeroed, writable memory. Paging
+// file memory is only shared if its mapping is, so this is mapAnonymous.
+func mapPrivateAnonymous(size int) ([]byte, error) {
+	return mapAnonymous(size)
+}
+
+// protectReadOnly makes an anonymous mapping read-only.
+func protectReadOnly(data []byte) error {
+	var old uint32
+	return windows.VirtualProtect(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), windows.PAGE_READONLY, &old)
+}
+
+// unmapAnonymous releases memory from mapAnonymous.
+func unmapAnonymous(data []byte) error {
+	return windows.UnmapViewOfFile(uintptr(unsafe.Pointer(&data[0])))
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !freebsd && !darwin
+
+package memmapfs
+
+import (
+	"errors"
+)
+
+// SeekData is not supported on this platform and returns
+// errors.ErrUnsupported.
+func (mf *MappedFile) SeekData(off int64) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
+
+// SeekHole is not supported on this platform and returns
+// errors.ErrUnsupported.
+func (mf *MappedFile) SeekHole(off int64) (int64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,63 @@
+//go:build linux || freebsd || darwin
+
+package memmapfs
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// seekExtent runs lseek(2) with whence (SEEK_DATA or SEEK_HOLE) on the
+// descriptor, restoring the descriptor's offset afterwards. The offset is
+// shared with other queries and with unmapped reads and writes, so this
+// takes the write lock.
+func (mf *MappedFile) seekExtent(off int64, whence int) (int64, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if off < 0 {
+		return 0, ErrInvalidOffset
+	}
+	if _, ok := mf.file.(*detachedFile); ok {
+		return 0, os.ErrClosed
+	}
+	if err := mf.undrainLocked(); err != nil {
+		return 0, err
+	}
+	if mf.mapper != nil || mf.remote != nil || mf.mmapData == nil {
+		return 0, errors.ErrUnsupported
+	}
+
+	fd := int(mf.fd)
+	saved, err := unix.Seek(fd, 0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Seek(fd, saved, io.SeekStart)
+
+	pos, err := unix.Seek(fd, off, whence)
+	if errors.Is(err, unix.ENXIO) {
+		// No data (or off is at or past EOF)
+		return 0, io.EOF
+	}
+	return pos, err
+}
+
+// SeekData returns the offset of the first allocated data at or after off,
+// using lseek(SEEK_DATA), so that sparse files can be processed without
+// faulting in their holes. It returns io.EOF if there is no data after off.
+// Neither the file position nor the descriptor offset changes.
+func (mf *MappedFile) SeekData(off int64) (int64, error) {
+	return mf.seekExtent(off, unix.SEEK_DATA)
+}
+
+// SeekHole returns the offset of the first hole at or after off, using
+// lseek(SEEK_HOLE). The end of the file counts as a hole. It returns io.EOF
+// if off is at or past the end. Neither the file position nor the
+// descriptor offset changes.
+func (mf *MappedFile) SeekHole(off int64) (int64, error) {
+	return mf.seekExtent(off, unix.SEEK_HOLE)
+}
@@ -1,9 +1,12 @@
 package memmapfs
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/absfs/absfs"
 	"github.com/absfs/osfs"
@@ -12,11 +15,17 @@ import (
 // SharedMemory provides utilities for inter-process communication
 // via memory-mapped files.
 type SharedMemory struct {
-	path string
-	size int64
-	mfs  *MemMapFS
-	file absfs.File
-	data []byte
+	path   string
+	offset int64 // File offset of the mapped segment
+	size   int64 // Size of the mapped segment
+	mfs    *MemMapFS
+	file   absfs.File
+	data   []byte
+	pinned bool // Holds a pin on the mapping, released by Close
+
+	// Serializes Lock within this process; the file lock alone does not
+	// exclude other goroutines using the same descriptor
+	lockMu sync.Mutex
 }
 
 // SharedMemoryConfig configures shared memory creation.
@@ -41,10 +50,50 @@ type SharedMemoryConfig struct {
 
 	// PopulatePages eagerly loads pages
 	PopulatePages bool
+
+	// Offset is the file offset where the mapped segment starts (default: 0).
+	// Processes can map disjoint segments of one large shared file.
+	Offset int64
+
+	// Length is the size of the mapped segment (default: Size - Offset)
+	Length int64
+
+	// FailIfExists creates the file with O_EXCL, so that creation fails
+	// with an error wrapping os.ErrExist instead of reusing and resizing a
+	// region another process may already be using.
+	FailIfExists bool
+}
+
+// SharedMemoryAttachConfig configures attaching to an existing shared
+// memory region, typically by a process joining after its creator.
+type SharedMemoryAttachConfig struct {
+	// Path to the shared file (required)
+	Path string
+
+	// Mode for the memory mapping (default: ModeReadWrite)
+	Mode MappingMode
+
+	// SyncMode controls when changes are written to disk
+	SyncMode SyncMode
+
+	// PopulatePages eagerly loads pages
+	PopulatePages bool
+
+	// Offset is the file offset where the mapped segment starts (default: 0)
+	Offset int64
+
+	// Length is the size of the mapped segment (default: file size - Offset)
+	Length int64
+
+	// Header, if set, must match the first bytes of the mapped segment,
+	// e.g. a magic number and version written by the creator. Attaching
+	// fails with ErrHeaderMismatch otherwise.
+	Header []byte
 }
 
 // CreateSharedMemory creates a new shared memory region.
-// The file will be created if it doesn't exist.
+// The file will be created if it doesn't exist. An existing file is reused
+// and resized to Size unless FailIfExists is set.
 func CreateSharedMemory(config *SharedMemoryConfig) (*SharedMemory, error) {
 	if config.Path == "" {
 		return nil, fmt.Errorf("path is required")
@@ -54,6 +103,14 @@ func CreateSharedMemory(config *SharedMemoryConfig) (*SharedMemory, error) {
 		return nil, fmt.Errorf("size must be positive")
 	}
 
+	length := config.Length
+	if length == 0 {
+		length = config.Size - config.Offset
+	}
+	if config.Offset < 0 || length <= 0 || config.Offset+length > config.Size {
+		return nil, fmt.Errorf("segment [%d, %d) is outside the shared region", config.Offset, config.Offset+length)
+	}
+
 	// Set default permissions
 	if config.Permissions == 0 {
 		config.Permissions = 0644
@@ -66,7 +123,14 @@ func CreateSharedMemory(config *SharedMemoryConfig) (*SharedMemory, error) {
 	}
 
 	// Create or open the file
-	f, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE, config.Permissions)
+	flag := os.O_RDWR | os.O_CREATE
+	if config.FailIfExists {
+		flag |= os.O_EXCL
+	}
+	f, err := os.OpenFile(config.Path, flag, config.Permissions)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("shared memory %s already exists: %w", config.Path, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
@@ -95,10 +159,28 @@ func CreateSharedMemory(config *SharedMemoryConfig) (*SharedMemory, error) {
 		mmapConfig.Mode = ModeReadWrite
 	}
 
-	mfs := New(osFS, mmapConfig)
+	if config.Offset == 0 && length == config.Size {
+		// Whole region
+		length = 0
+	}
+
+	return openSharedMemory(osFS, mmapConfig, config.Path, os.O_RDWR, config.Offset, length)
+}
+
+// openSharedMemory maps [offset, offset+length) of path. A length of 0 maps
+// the whole file.
+func openSharedMemory(fs absfs.FileSystem, mmapConfig *Config, path string, flag int, offset, length int64) (*SharedMemory, error) {
+	segment := offset != 0 || length != 0
+	if segment {
+		// Map the segment as a single fixed window
+		mmapConfig.MapFullFile = false
+		mmapConfig.WindowSize = length
+	}
+
+	mfs := New(fs, mmapConfig)
 
 	// Open with mmap
-	file, err := mfs.OpenFile(config.Path, os.O_RDWR, config.Permissions)
+	file, err := mfs.OpenFile(path, flag, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to mmap file: %w", err)
 	}
@@ -110,67 +192,116 @@ func CreateSharedMemory(config *SharedMemoryConfig) (*SharedMemory, error) {
 		return nil, fmt.Errorf("file is not a MappedFile")
 	}
 
+	if segment {
+		if err := mf.mapSegment(offset, length); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to map segment: %w", err)
+		}
+	} else {
+		length = mf.Size()
+	}
+
+	// SharedMemory keeps the mapped slice, so pin the mapping until Close
+	data, err := mf.Pin()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
 	return &SharedMemory{
-		path: config.Path,
-		size: config.Size,
-		mfs:  mfs,
-		file: file,
-		data: mf.Data(),
+		path:   path,
+		offset: offset,
+		size:   length,
+		mfs:    mfs,
+		file:   file,
+		data:   data,
+		pinned: true,
 	}, nil
 }
 
 // OpenSharedMemory opens an existing shared memory region.
 func OpenSharedMemory(path string, writable bool) (*SharedMemory, error) {
+	return OpenSharedMemorySegment(path, writable, 0, 0)
+}
+
+// OpenSharedMemorySegment opens only [offset, offset+length) of an existing
+// shared memory file. Data returns just that segment. A length of 0 maps
+// from offset to the end of the file.
+func OpenSharedMemorySegment(path string, writable bool, offset, length int64) (*SharedMemory, error) {
+	mode := ModeReadOnly
+	if writable {
+		mode = ModeReadWrite
+	}
+
+	return attachSharedMemory(&SharedMemoryAttachConfig{
+		Path:     path,
+		Mode:     mode,
+		SyncMode: SyncLazy,
+		Offset:   offset,
+		Length:   length,
+	})
+}
+
+// AttachSharedMemory maps an existing shared memory region with the same
+// control over the mapping that CreateSharedMemory offers its creator.
+// Mode defaults to ModeReadWrite; use OpenSharedMemory for read-only access.
+func AttachSharedMemory(config *SharedMemoryAttachConfig) (*SharedMemory, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	attach := *config
+	if attach.Mode == 0 {
+		attach.Mode = ModeReadWrite
+	}
+
+	return attachSharedMemory(&attach)
+}
+
+// attachSharedMemory implements AttachSharedMemory without defaulting Mode.
+func attachSharedMemory(config *SharedMemoryAttachConfig) (*SharedMemory, error) {
 	// Get file size
-	fi, err := os.Stat(path)
+	fi, err := os.Stat(config.Path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat file: %w", err)
 	}
 
+	offset, length := config.Offset, config.Length
+	if length == 0 && offset != 0 {
+		length = fi.Size() - offset
+	}
+	if offset < 0 || length < 0 || offset+length > fi.Size() {
+		return nil, fmt.Errorf("segment [%d, %d) is outside the shared region", offset, offset+length)
+	}
+
 	osFS, err := osfs.NewFS()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create osfs: %w", err)
 	}
 
-	mode := ModeReadOnly
-	if writable {
-		mode = ModeReadWrite
-	}
-
 	mmapConfig := &Config{
-		Mode:        mode,
-		SyncMode:    SyncLazy,
-		MapFullFile: true,
+		Mode:          config.Mode,
+		SyncMode:      config.SyncMode,
+		PopulatePages: config.PopulatePages,
+		MapFullFile:   true,
 	}
 
-	mfs := New(osFS, mmapConfig)
-
-	// Open file
-	var file absfs.File
-	if writable {
-		file, err = mfs.OpenFile(path, os.O_RDWR, 0)
-	} else {
-		file, err = mfs.Open(path)
+	flag := os.O_RDONLY
+	if config.Mode.isWritable() {
+		flag = os.O_RDWR
 	}
 
+	sm, err := openSharedMemory(osFS, mmapConfig, config.Path, flag, offset, length)
 	if err != nil {
-		return nil, fmt.Errorf("failed to mmap file: %w", err)
+		return nil, err
 	}
 
-	// Get direct access to mapped memory
-	mf, ok := file.(*MappedFile)
-	if !ok {
-		file.Close()
-		return nil, fmt.Errorf("file is not a MappedFile")
+	if len(config.Header) > 0 && !bytes.HasPrefix(sm.data, config.Header) {
+		sm.Close()
+		return nil, fmt.Errorf("%s: %w", config.Path, ErrHeaderMismatch)
 	}
 
-	return &SharedMemory{
-		path: path,
-		size: fi.Size(),
-		mfs:  mfs,
-		file: file,
-		data: mf.Data(),
-	}, nil
+	return sm, nil
 }
 
 // Data returns a direct slice to the shared memory region.
@@ -179,6 +310,11 @@ func (sm *SharedMemory) Data() []byte {
 	return sm.data
 }
 
+// Offset returns the file offset at which the mapped segment starts.
+func (sm *SharedMemory) Offset() int64 {
+	return sm.offset
+}
+
 // Size returns the size of the shared memory region.
 func (sm *SharedMemory) Size() int64 {
 	return sm.size
@@ -201,14 +337,28 @@ func (sm *SharedMemory) Sync() error {
 // The underlying file remains on disk and can be reopened.
 func (sm *SharedMemory) Close() error {
 	if sm.file != nil {
+		sm.unpin()
 		return sm.file.Close()
 	}
 	return nil
 }
 
+// unpin releases the pin taken when the region was mapped, which would
+// otherwise keep the file's Close waiting.
+func (sm *SharedMemory) unpin() {
+	if !sm.pinned {
+		return
+	}
+	sm.pinned = false
+	if mf, ok := sm.file.(*MappedFile); ok {
+		mf.Unpin()
+	}
+}
+
 // Remove closes and deletes the shared memory file.
 func (sm *SharedMemory) Remove() error {
 	if sm.file != nil {
+		sm.unpin()
 		sm.file.Close()
 		sm.file = nil
 	}
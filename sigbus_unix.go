@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 
 	"golang.org/x/sys/unix"
 )
@@ -17,11 +18,12 @@ import (
 // - I/O error occurs reading from disk
 // - Accessing beyond mapped region
 type SIGBUSHandler struct {
-	mu       sync.RWMutex
-	files    map[*MappedFile]bool
-	sigChan  chan os.Signal
-	enabled  bool
-	handlers []func(*MappedFile, error)
+	mu        sync.RWMutex
+	files     map[*MappedFile]bool
+	sigChan   chan os.Signal
+	enabled   bool
+	autoRemap bool
+	handlers  []func(*MappedFile, error)
 }
 
 var (
@@ -107,6 +109,16 @@ func (h *SIGBUSHandler) OnSIGBUS(handler func(*MappedFile, error)) {
 	h.handlers = append(h.handlers, handler)
 }
 
+// SetAutoRemap controls whether a file found truncated on SIGBUS is remapped
+// to its new size, via RemapAfterTruncation, before handlers are called.
+// Accesses within the new size then succeed again instead of faulting.
+func (h *SIGBUSHandler) SetAutoRemap(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.autoRemap = enabled
+}
+
 // handleSignals processes SIGBUS signals.
 func (h *SIGBUSHandler) handleSignals() {
 	for range h.sigChan {
@@ -123,15 +135,24 @@ func (h *SIGBUSHandler) handleSIGBUS() {
 	}
 	handlers := make([]func(*MappedFile, error), len(h.handlers))
 	copy(handlers, h.handlers)
+	autoRemap := h.autoRemap
 	h.mu.RUnlock()
 
-	err := ErrSIGBUS
-
 	// Check each mapped file for potential issues
 	for _, mf := range files {
+		err := ErrSIGBUS
+
 		// Try to detect if this file was truncated
 		if isTruncated, truncErr := mf.checkTruncation(); isTruncated {
 			err = fmt.Errorf("file truncated while mapped: %w", truncErr)
+
+			if autoRemap {
+				if remapErr := mf.RemapAfterTruncation(); remapErr != nil {
+					err = fmt.Errorf("file truncated while mapped: %w (automatic remap failed: %v)", truncErr, remapErr)
+				} else {
+					err = fmt.Errorf("file truncated while mapped, remapped to new size: %w", truncErr)
+				}
+			}
 		}
 
 		// Call registered handlers
@@ -150,7 +171,12 @@ func (mf *MappedFile) checkTruncation() (bool, error) {
 		return false, nil
 	}
 
-	fi, err := mf.file.Stat()
+	// A cached stat may predate the truncation, so it is only trusted
+	// when it already shows one
+	fi, err := mf.StatCached(false)
+	if err == nil && fi.Size() >= mf.size {
+		fi, err = mf.StatCached(true)
+	}
 	if err != nil {
 		return false, fmt.Errorf("stat failed: %w", err)
 	}
@@ -163,6 +189,25 @@ func (mf *MappedFile) checkTruncation() (bool, error) {
 	return false, nil
 }
 
+// backingRemoved reports whether the file backing the mapping has been
+// unlinked. The mapping itself stays valid, but writes no longer reach any path.
+func (mf *MappedFile) backingRemoved() bool {
+	if mf.file == nil {
+		return false
+	}
+
+	fi, err := mf.file.Stat()
+	if err != nil {
+		return false
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Nlink == 0
+	}
+
+	return false
+}
+
 // EnableSIGBUSProtection enables SIGBUS monitoring for a mapped file.
 // This should be called after opening a file if you want protection.
 func (mf *MappedFile) EnableSIGBUSProtection() {
@@ -193,25 +238,9 @@ func (mf *MappedFile) RemapAfterTruncation() error {
 		return nil // File wasn't actually truncated
 	}
 
-	// Unmap current mapping
-	if mf.mmapData != nil {
-		if err := unix.Munmap(mf.mmapData); err != nil {
-			return fmt.Errorf("munmap failed: %w", err)
-		}
-		mf.mmapData = nil
-		mf.data = nil
-	}
-
-	// Remap with new size
-	if newSize == 0 {
-		// File is now empty, no mapping needed
-		mf.size = 0
-		return nil
-	}
-
-	// Perform new mmap with updated size
-	mf.size = newSize
-	if err := mf.mmap(); err != nil {
+	// remapLocked leaves an empty file unmapped and keeps any window
+	// inside the new size
+	if err := mf.remapLocked(newSize); err != nil {
 		return fmt.Errorf("remap failed: %w", err)
 	}
 
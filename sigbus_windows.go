@@ -2,6 +2,10 @@
 
 package memmapfs
 
+import (
+	"fmt"
+)
+
 // SIGBUSHandler is a no-op on Windows (SIGBUS doesn't exist).
 type SIGBUSHandler struct{}
 
@@ -25,19 +29,63 @@ func (h *SIGBUSHandler) Unregister(mf *MappedFile) {}
 // OnSIGBUS is a no-op on Windows.
 func (h *SIGBUSHandler) OnSIGBUS(handler func(*MappedFile, error)) {}
 
+// SetAutoRemap is a no-op on Windows.
+func (h *SIGBUSHandler) SetAutoRemap(enabled bool) {}
+
 // EnableSIGBUSProtection is a no-op on Windows.
 func (mf *MappedFile) EnableSIGBUSProtection() {}
 
 // DisableSIGBUSProtection is a no-op on Windows.
 func (mf *MappedFile) DisableSIGBUSProtection() {}
 
-// RemapAfterTruncation is a no-op on Windows.
+// RemapAfterTruncation remaps the file if it has become smaller than the
+// mapping. Windows refuses to shrink a file with mapped views, so this
+// only matters for files resized while unmapped, e.g. after Drain.
 func (mf *MappedFile) RemapAfterTruncation() error {
-	return nil
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	fi, err := mf.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+
+	if fi.Size() >= mf.size {
+		return nil // File wasn't actually truncated
+	}
+
+	return mf.remapLocked(fi.Size())
 }
 
 // checkTruncation checks if the file has been truncated.
-// This is a no-op on Windows (Phase 1).
 func (mf *MappedFile) checkTruncation() (bool, error) {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	if mf.file == nil {
+		return false, nil
+	}
+
+	// A cached stat may predate the truncation, so it is only trusted
+	// when it already shows one
+	fi, err := mf.StatCached(false)
+	if err == nil && fi.Size() >= mf.size {
+		fi, err = mf.StatCached(true)
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat failed: %w", err)
+	}
+
+	currentSize := fi.Size()
+	if currentSize < mf.size {
+		return true, fmt.Errorf("file size decreased from %d to %d bytes", mf.size, currentSize)
+	}
+
 	return false, nil
 }
+
+// backingRemoved reports whether the file backing the mapping has been removed.
+// Windows refuses to delete files with open mappings, so this is always false.
+func (mf *MappedFile) backingRemoved() bool {
+	return false
+}
@@ -0,0 +1,82 @@
+package memmapfs
+
+// Stats describes the current mapping state of a MappedFile.
+type Stats struct {
+	// Size is the logical file size in bytes
+	Size int64
+
+	// WindowSize is the current window size (0 = full file mapping).
+	// With AdaptiveWindow it may grow beyond the configured size.
+	WindowSize int64
+
+	// WindowOffset is the file offset where the current window starts
+	WindowOffset int64
+
+	// WindowSlides is the number of times the window has been remapped
+	WindowSlides uint64
+
+	// ReadAheadWindows is the number of windows read ahead on sequential
+	// slides (Config.ReadAheadWindows), or 0 where read-ahead does not apply
+	ReadAheadWindows int
+}
+
+// Stats returns a snapshot of the file's mapping state.
+func (mf *MappedFile) Stats() Stats {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return Stats{
+		Size:         mf.size,
+		WindowSize:   mf.windowSize,
+		WindowOffset: mf.windowOffset,
+		WindowSlides: mf.slides,
+
+		ReadAheadWindows: mf.readAheadDepth(),
+	}
+}
+
+// DebugState is a snapshot of a MappedFile's internal state, returned by
+// Dump for bug reports and for asserting invariants in tests. Its fields
+// may change between releases.
+type DebugState struct {
+	Name         string
+	Size         int64       // Logical file size
+	Position     int64       // Current read/write position
+	WindowSize   int64       // Current window size (0 = full file mapping)
+	WindowOffset int64       // File offset of the current window
+	DataLen      int         // Length of the mapped region seen by I/O
+	MmapLen      int         // Length of the underlying mapping, including alignment
+	Modified     bool        // Written since mapped, or since the last window sync
+	Dirty        int64       // Bytes written since the last sync
+	Drained      bool        // Unmapped by Drain until the next access
+	Pins         int         // Outstanding Pin calls
+	Closed       bool        // Close has been called
+	Mode         MappingMode // Mapping mode in effect
+	SyncMode     SyncMode    // Sync mode in effect
+	Fd           uintptr     // Descriptor used for mapping (0 if none)
+}
+
+// Dump returns a snapshot of the file's internal state, e.g. for debugging
+// windowing and lifecycle issues.
+func (mf *MappedFile) Dump() DebugState {
+	mf.mu.RLock()
+	defer mf.mu.RUnlock()
+
+	return DebugState{
+		Name:         mf.file.Name(),
+		Size:         mf.size,
+		Position:     mf.position,
+		WindowSize:   mf.windowSize,
+		WindowOffset: mf.windowOffset,
+		DataLen:      len(mf.data),
+		MmapLen:      len(mf.mmapData),
+		Modified:     mf.modified,
+		Dirty:        mf.dirty,
+		Drained:      mf.drained.Load(),
+		Pins:         mf.pins,
+		Closed:       mf.closed,
+		Mode:         mf.config.Mode,
+		SyncMode:     mf.config.SyncMode,
+		Fd:           mf.fd,
+	}
+}
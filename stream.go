@@ -0,0 +1,107 @@
+package memmapfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DefaultStreamChunk is the default growth increment of CreateStreamWriter.
+const DefaultStreamChunk = 64 << 20 // 64 MB
+
+// StreamWriter writes a file of unknown final size sequentially through a
+// shared writable mapping. The file grows chunk bytes at a time as writes
+// reach its end and is truncated to the bytes actually written on Close.
+type StreamWriter struct {
+	mf     *MappedFile
+	chunk  int64
+	n      int64 // Bytes written so far
+	closed bool
+}
+
+// CreateStreamWriter creates (or truncates) the named file and returns a
+// StreamWriter over it that grows the file chunk bytes at a time. If
+// chunk <= 0, DefaultStreamChunk is used. The filesystem's windowing
+// configuration applies, so with MapFullFile false only the current window
+// is mapped however large the output gets.
+//
+// Until Close the file on disk is up to chunk bytes longer than the data
+// written, with the tail reading as zeros.
+func (mfs *MemMapFS) CreateStreamWriter(name string, chunk int64) (*StreamWriter, error) {
+	if chunk <= 0 {
+		chunk = DefaultStreamChunk
+	}
+
+	config := *mfs.config
+	config.Mode = ModeReadWrite
+	config.GrowOnWriteAt = false
+	config.CloseFdAfterMap = false
+
+	file, err := mfs.openUnderlyingFlags(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfs.config.DurableCreate {
+		if err := mfs.SyncDir(name); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if err := file.Truncate(chunk); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: grow failed: %w", name, err)
+	}
+
+	mf, err := newMappedFile(file, &config, chunk, mfs.syncManager)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	mfs.open.add(mf)
+
+	return &StreamWriter{mf: mf, chunk: chunk}, nil
+}
+
+// Write appends p to the file, growing it by whole chunks when p does not
+// fit. Writes may span windows.
+func (sw *StreamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, os.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if end := sw.n + int64(len(p)); end > sw.mf.Size() {
+		chunks := (end + sw.chunk - 1) / sw.chunk
+		if err := sw.mf.Truncate(chunks * sw.chunk); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := sw.mf.WriteAtv([][]byte{p}, sw.n)
+	sw.n += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written so far.
+func (sw *StreamWriter) Len() int64 {
+	return sw.n
+}
+
+// Ensure StreamWriter implements io.WriteCloser
+var _ io.WriteCloser = (*StreamWriter)(nil)
+
+// Close truncates the file to the bytes written, then syncs and closes it.
+func (sw *StreamWriter) Close() error {
+	if sw.closed {
+		return os.ErrClosed
+	}
+	sw.closed = true
+
+	truncErr := sw.mf.Truncate(sw.n)
+	return errors.Join(truncErr, sw.mf.Close())
+}
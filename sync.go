@@ -1,25 +1,42 @@
 package memmapfs
 
 import (
+	"sort"
 	"sync"
 	"time"
 )
 
+// SyncStatus describes the periodic sync state of one mapped file.
+type SyncStatus struct {
+	Name     string    // File name
+	LastSync time.Time // Last successful sync (zero if never synced)
+	Dirty    int64     // Bytes written since the last sync
+}
+
 // syncManager manages periodic synchronization of mapped files.
 type syncManager struct {
 	files    map[*MappedFile]struct{}
 	mu       sync.RWMutex
 	ticker   *time.Ticker
+	flush    chan *MappedFile // Early flush requests (SyncDirtyThreshold)
 	stopChan chan struct{}
 	stopped  bool
+	workers  int // Files synced concurrently per tick (Config.SyncWorkers)
 }
 
-// newSyncManager creates a new sync manager with the given interval.
-func newSyncManager(interval time.Duration) *syncManager {
+// flushQueueSize is the number of early flush requests that can be queued
+// before further requests are dropped until the next tick.
+const flushQueueSize = 64
+
+// newSyncManager creates a new sync manager with the given interval that
+// syncs up to workers files at once.
+func newSyncManager(interval time.Duration, workers int) *syncManager {
 	sm := &syncManager{
 		files:    make(map[*MappedFile]struct{}),
 		ticker:   time.NewTicker(interval),
+		flush:    make(chan *MappedFile, flushQueueSize),
 		stopChan: make(chan struct{}),
+		workers:  workers,
 	}
 
 	go sm.run()
@@ -32,6 +49,8 @@ func (sm *syncManager) run() {
 		select {
 		case <-sm.ticker.C:
 			sm.syncAll()
+		case f := <-sm.flush:
+			sm.syncOne(f)
 		case <-sm.stopChan:
 			return
 		}
@@ -48,8 +67,65 @@ func (sm *syncManager) syncAll() {
 	sm.mu.RUnlock()
 
 	// Sync each file (without holding the manager lock)
+	workers := min(sm.workers, len(files))
+	if workers <= 1 {
+		for _, f := range files {
+			sm.syncFile(f)
+		}
+		return
+	}
+
+	next := make(chan *MappedFile)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range next {
+				sm.syncFile(f)
+			}
+		}()
+	}
 	for _, f := range files {
-		_ = f.Sync() // Ignore errors during periodic sync
+		next <- f
+	}
+	close(next)
+	wg.Wait()
+}
+
+// syncOne syncs a single file that requested an early flush, if it is
+// still registered.
+func (sm *syncManager) syncOne(f *MappedFile) {
+	sm.mu.RLock()
+	_, ok := sm.files[f]
+	sm.mu.RUnlock()
+
+	if ok {
+		sm.syncFile(f)
+	}
+}
+
+// syncFile syncs f, reporting failures to Config.OnSyncError, or else to
+// Config.Logger. Files closed since they were looked up are skipped.
+func (sm *syncManager) syncFile(f *MappedFile) {
+	open, config, err := f.syncIfOpen()
+	switch {
+	case !open, err == nil:
+	case config.OnSyncError != nil:
+		config.OnSyncError(f, err)
+	default:
+		config.warn("memmapfs: periodic sync failed", "name", f.Name(), "error", err)
+	}
+}
+
+// requestFlush queues f for an early sync without blocking. It reports
+// whether the request was queued.
+func (sm *syncManager) requestFlush(f *MappedFile) bool {
+	select {
+	case sm.flush <- f:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -89,3 +165,29 @@ func (sm *syncManager) stop() {
 	// Clear all files
 	sm.files = make(map[*MappedFile]struct{})
 }
+
+// status returns the sync status of every registered file, sorted by name.
+func (sm *syncManager) status() []SyncStatus {
+	sm.mu.RLock()
+	files := make([]*MappedFile, 0, len(sm.files))
+	for f := range sm.files {
+		files = append(files, f)
+	}
+	sm.mu.RUnlock()
+
+	statuses := make([]SyncStatus, 0, len(files))
+	for _, f := range files {
+		f.mu.RLock()
+		statuses = append(statuses, SyncStatus{
+			Name:     f.file.Name(),
+			LastSync: f.lastSync,
+			Dirty:    f.dirty,
+		})
+		f.mu.RUnlock()
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
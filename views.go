@@ -0,0 +1,105 @@
+package memmapfs
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// sharedFile lets several MappedFiles use one underlying file. The file is
+// closed when the last of them is closed.
+type sharedFile struct {
+	absfs.File
+	fd   uintptr
+	refs *sharedRefs
+}
+
+// sharedRefs counts the open users of a sharedFile.
+type sharedRefs struct {
+	mu sync.Mutex
+	n  int
+}
+
+// Fd returns the descriptor (handle on Windows) of the underlying file.
+func (f *sharedFile) Fd() uintptr {
+	return f.fd
+}
+
+// Close closes the underlying file once every user has closed it.
+func (f *sharedFile) Close() error {
+	f.refs.mu.Lock()
+	defer f.refs.mu.Unlock()
+
+	f.refs.n--
+	if f.refs.n > 0 {
+		return nil
+	}
+	return f.File.Close()
+}
+
+// OpenViews maps name twice over a single descriptor: a read-only view for
+// readers, which cannot write through it by accident, and a read-write view
+// for the writer. Both are MAP_SHARED mappings of the same file, so readers
+// see the writer's stores immediately, without a sync. The views share
+// nothing else: each has its own position, window and lock, and the file is
+// closed once both have been closed. The configuration's Mode is ignored.
+func (mfs *MemMapFS) OpenViews(name string) (reader, writer *MappedFile, err error) {
+	file, err := mfs.openUnderlying(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if fi.Size() == 0 {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s is empty: %w", name, ErrNotMapped)
+	}
+	if err := mfs.checkFileSize(name, fi.Size()); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// The views are mapped through wrappers, so find the descriptor now
+	fd, ok := wrappedFD(file)
+	if fg, isFdGetter := file.(interface{ Fd() uintptr }); isFdGetter {
+		fd, ok = fg.Fd(), true
+	}
+	if !ok {
+		file.Close()
+		return nil, nil, fmt.Errorf("unable to extract file descriptor from type %T", file)
+	}
+
+	refs := &sharedRefs{n: 2}
+
+	readConfig := *mfs.config
+	readConfig.Mode = ModeReadOnly
+	readConfig.PrivateReadOnly = false
+	readConfig.CloseFdAfterMap = false
+
+	reader, err = newMappedFile(&sharedFile{File: file, fd: fd, refs: refs}, &readConfig, fi.Size(), mfs.syncManager)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	writeConfig := readConfig
+	writeConfig.Mode = ModeReadWrite
+
+	writer, err = newMappedFile(&sharedFile{File: file, fd: fd, refs: refs}, &writeConfig, fi.Size(), mfs.syncManager)
+	if err != nil {
+		reader.Close()
+		file.Close()
+		return nil, nil, err
+	}
+
+	mfs.open.add(reader)
+	mfs.open.add(writer)
+
+	return reader, writer, nil
+}
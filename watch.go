@@ -0,0 +1,76 @@
+package memmapfs
+
+import (
+	"sync"
+	"time"
+)
+
+// WatchSize polls the size of the underlying file every interval and, when
+// the file has grown, remaps it and calls fn with the new size. This is the
+// building block for tailing a file that another process appends to.
+//
+// fn is called from the watcher goroutine without the file's lock held.
+// The returned function stops the watcher; Close also stops it. On a
+// closed file no watcher is started and stop does nothing.
+func (mf *MappedFile) WatchSize(interval time.Duration, fn func(newSize int64)) (stop func()) {
+	mf.mu.Lock()
+	if mf.closed {
+		mf.mu.Unlock()
+		return func() {}
+	}
+	if mf.done == nil {
+		mf.done = make(chan struct{})
+	}
+	done := mf.done
+	mf.mu.Unlock()
+
+	stopChan := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if newSize, grown := mf.remapIfGrown(done); grown {
+					fn(newSize)
+				}
+			case <-stopChan:
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopChan) })
+	}
+}
+
+// remapIfGrown remaps the file if it has grown since it was last mapped and
+// reports the new size. It does nothing once done has been closed.
+func (mf *MappedFile) remapIfGrown(done chan struct{}) (int64, bool) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	// File was closed while we waited for the lock
+	select {
+	case <-done:
+		return 0, false
+	default:
+	}
+
+	fi, err := mf.StatCached(false)
+	if err != nil || fi.Size() <= mf.size {
+		return 0, false
+	}
+
+	if err := mf.remapLocked(fi.Size()); err != nil {
+		return 0, false
+	}
+
+	return mf.size, true
+}
@@ -0,0 +1,129 @@
+package memmapfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// writeBackChunk is how much of the mapping is written per WriteAt when
+// recovering from a failed synchronous msync.
+const writeBackChunk = 1 << 20
+
+// PartialSyncError reports a synchronous sync that failed part way, for
+// example with ENOSPC on a full volume. The mapped region starting at
+// Offset is known to be on disk up to, but not including, file offset
+// Durable. It matches ErrPartialSync and the underlying error with
+// errors.Is.
+type PartialSyncError struct {
+	Offset  int64 // File offset of the mapped region
+	Durable int64 // File offset up to which the region is durable
+	Err     error // Error that stopped the write-back
+}
+
+func (e *PartialSyncError) Error() string {
+	return fmt.Sprintf("partial sync: [%d, %d) durable: %v", e.Offset, e.Durable, e.Err)
+}
+
+// Unwrap returns ErrPartialSync and the underlying error.
+func (e *PartialSyncError) Unwrap() []error {
+	return []error{ErrPartialSync, e.Err}
+}
+
+// recoverSync is called when a synchronous msync fails with syncErr. It
+// writes the mapped region back through the underlying file in chunks and
+// syncs it, to find out how much of the region can be made durable. It
+// returns nil if the whole region was persisted, and a *PartialSyncError
+// otherwise. The caller must hold the write lock.
+func (mf *MappedFile) recoverSync(syncErr error) error {
+	var base int64
+	if mf.windowSize > 0 {
+		base = mf.windowOffset
+	}
+
+	var written int64
+	for written < int64(len(mf.data)) {
+		end := min(written+writeBackChunk, int64(len(mf.data)))
+		n, err := mf.file.WriteAt(mf.data[written:end], base+written)
+		written += int64(n)
+		if err != nil {
+			syncErr = err
+			break
+		}
+	}
+
+	// Nothing written back counts until the file itself has been synced
+	if err := mf.file.Sync(); err != nil {
+		return &PartialSyncError{Offset: base, Durable: base, Err: err}
+	}
+
+	if written < int64(len(mf.data)) {
+		return &PartialSyncError{Offset: base, Durable: base + written, Err: syncErr}
+	}
+	return nil
+}
+
+// DurableWriteAt writes p at off like WriteAt and flushes the pages it
+// touched with a synchronous msync (FlushViewOfFile and FlushFileBuffers on
+// Windows) before returning, whatever the SyncMode, so that n counts only
+// bytes known to be on disk. Other dirty ranges are left to the SyncMode.
+// If the msync fails the region is written back through the file as under
+// SyncImmediate; should that fail part way, n is the part of p below
+// PartialSyncError.Durable and err is the *PartialSyncError. Any other
+// sync failure returns n = 0, since nothing is known to be durable.
+// Copy-on-write mappings never reach the file and are not supported.
+func (mf *MappedFile) DurableWriteAt(p []byte, off int64) (int, error) {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	if mf.config.Mode == ModeCopyOnWrite {
+		return 0, &os.PathError{Op: "durablewriteat", Path: mf.file.Name(), Err: errors.ErrUnsupported}
+	}
+
+	wasDirty := mf.dirty > 0
+
+	n, err := mf.writeAtLocked(p, off)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if mf.data != nil && mf.backingRemoved() {
+		return 0, ErrBackingRemoved
+	}
+
+	if err := mf.flushWrittenLocked(off, n); err != nil {
+		var partial *PartialSyncError
+		if errors.As(err, &partial) {
+			return int(min(max(partial.Durable-off, 0), int64(n))), err
+		}
+		return 0, err
+	}
+
+	// Only this write was pending, and it is on disk now
+	if !wasDirty {
+		mf.dirty = 0
+	}
+	return n, nil
+}
+
+// flushWrittenLocked makes the n bytes just written at off durable, falling
+// back to recoverSync if msync fails. The caller must hold the write lock.
+func (mf *MappedFile) flushWrittenLocked(off int64, n int) error {
+	if mf.data == nil || mf.mapper != nil {
+		return mf.file.Sync()
+	}
+
+	span, err := mf.adviseSpan(off, int64(n))
+	if err != nil {
+		return err
+	}
+
+	if err := mf.msyncRange(span); err != nil {
+		// Find out how much can still be made durable, e.g. on ENOSPC
+		return mf.recoverSync(err)
+	}
+	return nil
+}